@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-extractor/jobs"
+	"github.com/ONSdigital/dp-dimension-extractor/service"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+)
+
+// ErrDrainTimeout is returned when the running jobs could not be finished within the drain timeout, so that they were aborted
+var ErrDrainTimeout = errors.New("timed out draining the extraction jobs, the running jobs were aborted")
+
+// Extractor runs the extraction of an input file available event, and reports its failure
+type Extractor interface {
+	Extract(ctx context.Context, event *service.InputFileAvailable) error
+}
+
+// AuthHandler wraps a handler so that it is only called for authenticated requests
+type AuthHandler func(handler http.HandlerFunc) http.Handler
+
+// API serves the admin endpoints of the service, which run extractions without going through kafka,
+// report the progress of the extractions and validate uploaded files
+type API struct {
+	extractor    Extractor
+	validator    Validator
+	jobs         *jobs.Registry
+	running      *sync.WaitGroup
+	jobContext   context.Context
+	abortJobs    context.CancelFunc
+	mutex        *sync.Mutex
+	draining     bool
+	uploadLimits UploadLimits
+	uploads      chan struct{}
+}
+
+// Setup registers the admin endpoints on the router. Every endpoint requires the requests to be authenticated by auth.
+func Setup(router *mux.Router, auth AuthHandler, extractor Extractor, registry *jobs.Registry, validator Validator, uploadLimits UploadLimits) *API {
+	jobContext, abortJobs := context.WithCancel(context.Background())
+	api := &API{
+		extractor:    extractor,
+		validator:    validator,
+		jobs:         registry,
+		running:      &sync.WaitGroup{},
+		jobContext:   jobContext,
+		abortJobs:    abortJobs,
+		mutex:        &sync.Mutex{},
+		uploadLimits: uploadLimits,
+		uploads:      make(chan struct{}, uploadLimits.MaxConcurrent),
+	}
+	router.Handle("/extractions", auth(api.postExtractions)).Methods(http.MethodPost)
+	router.Handle("/extractions", auth(api.getExtractions)).Methods(http.MethodGet)
+	router.Handle("/extractions/{instance_id}", auth(api.getExtraction)).Methods(http.MethodGet)
+	router.Handle("/validate", auth(api.postValidate)).Methods(http.MethodPost)
+	return api
+}
+
+// Drain stops accepting new jobs, and waits for the running jobs to finish. If they cannot finish within drainTimeout,
+// they are aborted and ErrDrainTimeout is returned once they have returned, or ctx is done.
+func (api *API) Drain(ctx context.Context, drainTimeout time.Duration) error {
+	api.mutex.Lock()
+	api.draining = true
+	api.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		api.running.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(drainTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		log.Info(ctx, "extraction jobs drained")
+		return nil
+	case <-timer.C:
+	}
+
+	log.Warn(ctx, "drain timeout exceeded, aborting the running extraction jobs", log.Data{"drain_timeout": drainTimeout.String()})
+	api.abortJobs()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Warn(ctx, "extraction jobs failed to stop after they were aborted", log.FormatErrors([]error{ctx.Err()}))
+	}
+	return ErrDrainTimeout
+}
+
+// startJob runs the job in the background, unless the API is draining. It returns false if the job was not started.
+func (api *API) startJob(job func(ctx context.Context)) bool {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	if api.draining {
+		return false
+	}
+
+	api.running.Add(1)
+	go func() {
+		defer api.running.Done()
+		job(api.jobContext)
+	}()
+	return true
+}
+
+// writeJSON writes the value as the JSON body of the response, with the provided status
+func writeJSON(ctx context.Context, w http.ResponseWriter, status int, value interface{}) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		log.Error(ctx, "failed to marshal response body", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		log.Error(ctx, "failed to write response body", err)
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/ONSdigital/dp-dimension-extractor/jobs"
+	"github.com/ONSdigital/dp-dimension-extractor/schema"
+	"github.com/ONSdigital/dp-dimension-extractor/service"
+	"github.com/ONSdigital/dp-net/v2/request"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+)
+
+// maxExtractionRequestSize is the maximum size of the body of an extraction request
+const maxExtractionRequestSize = 1 << 20
+
+// jobIDSize is the length of the generated job IDs
+const jobIDSize = 16
+
+// ExtractionJob is the response to an extraction request
+type ExtractionJob struct {
+	JobID      string `json:"job_id"`
+	InstanceID string `json:"instance_id"`
+	DryRun     bool   `json:"dry_run"`
+}
+
+// postExtractions starts the extraction of the file of an instance, requested with the same JSON body as an input file
+// available event, and responds with the ID of the job. The job ID is used as the request ID of the extraction,
+// so that it is logged and sent to the dataset API, the import reporter and the dimensions-extracted topic.
+// A dry run, requested with the dry_run query parameter or processing option, only validates the file,
+// and its validation report is returned with the progress of the extraction.
+func (api *API) postExtractions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxExtractionRequestSize))
+	if err != nil {
+		log.Error(ctx, "failed to read extraction request", err)
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+	var event service.InputFileAvailable
+	if err := schema.InputFileAvailableJSONSchema.Unmarshal(body, &event); err != nil {
+		log.Error(ctx, "invalid extraction request", err)
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get(service.ProcessingOptionDryRun)); dryRun {
+		if event.ProcessingOptions == nil {
+			event.ProcessingOptions = make(map[string]string)
+		}
+		event.ProcessingOptions[service.ProcessingOptionDryRun] = "true"
+	}
+
+	job := ExtractionJob{JobID: request.NewRequestID(jobIDSize), InstanceID: event.InstanceID, DryRun: event.DryRun()}
+	logData := log.Data{"job_id": job.JobID, "instance_id": job.InstanceID, "file_url": event.FileURL, "dry_run": job.DryRun}
+	started := api.startJob(func(jobContext context.Context) {
+		jobContext = request.WithRequestId(jobContext, job.JobID)
+		log.Info(jobContext, "extraction job started", logData)
+		if err := api.extractor.Extract(jobContext, &event); err != nil {
+			log.Error(jobContext, "extraction job failed", err, logData)
+			return
+		}
+		log.Info(jobContext, "extraction job finished", logData)
+	})
+	if !started {
+		log.Warn(ctx, "extraction request rejected, the service is shutting down", logData)
+		http.Error(w, "the service is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	log.Info(ctx, "extraction requested", logData)
+	writeJSON(ctx, w, http.StatusAccepted, job)
+}
+
+// Extractions is the response listing the extraction jobs
+type Extractions struct {
+	Items []jobs.Status `json:"items"`
+	Count int           `json:"count"`
+}
+
+// getExtractions responds with the progress of the extractions in progress and of the most recent finished ones,
+// whether they were consumed from kafka or requested through the API, the most recently started first
+func (api *API) getExtractions(w http.ResponseWriter, r *http.Request) {
+	items := api.jobs.List()
+	writeJSON(r.Context(), w, http.StatusOK, Extractions{Items: items, Count: len(items)})
+}
+
+// getExtraction responds with the progress of the latest extraction of the instance
+func (api *API) getExtraction(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instance_id"]
+	status, ok := api.jobs.Get(instanceID)
+	if !ok {
+		log.Info(r.Context(), "no extraction found for the instance", log.Data{"instance_id": instanceID})
+		http.Error(w, "extraction not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(r.Context(), w, http.StatusOK, status)
+}
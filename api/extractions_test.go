@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-extractor/jobs"
+	"github.com/ONSdigital/dp-dimension-extractor/service"
+	"github.com/ONSdigital/dp-net/v2/request"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+)
+
+const validRequest = `{"instance_id": "7b6f5b7a-4c3e-4e2a-9f0a-1b2c3d4e5f60", "file_url": "s3://dp-frontend-florence-file-uploads/input.csv"}`
+
+var ctx = context.Background()
+
+// testExtractor records the events it extracts, and blocks each extraction until release is closed
+type testExtractor struct {
+	mutex    sync.Mutex
+	events   []*service.InputFileAvailable
+	contexts []context.Context
+	release  chan struct{}
+	done     chan struct{}
+	err      error
+}
+
+func newTestExtractor() *testExtractor {
+	return &testExtractor{release: make(chan struct{}), done: make(chan struct{}, 10)}
+}
+
+func (e *testExtractor) Extract(ctx context.Context, event *service.InputFileAvailable) error {
+	e.mutex.Lock()
+	e.events = append(e.events, event)
+	e.contexts = append(e.contexts, ctx)
+	e.mutex.Unlock()
+	defer func() { e.done <- struct{}{} }()
+
+	select {
+	case <-e.release:
+		return e.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// authenticated only calls the handler for requests with an Authorization header
+func authenticated(handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			http.Error(w, "unauthenticated request", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	})
+}
+
+func postExtraction(router *mux.Router, body string, authenticate bool) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/extractions", strings.NewReader(body))
+	if authenticate {
+		r.Header.Set("Authorization", "Bearer token")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func waitExtracted(t *testing.T, extractor *testExtractor) {
+	select {
+	case <-extractor.done:
+	case <-time.After(time.Second):
+		t.Fatal("the extraction did not return")
+	}
+}
+
+func TestPostExtractions(t *testing.T) {
+	Convey("Given the admin API", t, func() {
+		router := mux.NewRouter()
+		extractor := newTestExtractor()
+		registry := jobs.NewRegistry(10)
+		api := Setup(router, authenticated, extractor, registry, &testValidator{}, testUploadLimits)
+
+		Convey("When an authenticated extraction request is posted", func() {
+			w := postExtraction(router, validRequest, true)
+
+			Convey("Then a job ID is returned, and the extraction runs in the background with the job ID as request ID", func() {
+				So(w.Code, ShouldEqual, http.StatusAccepted)
+				var job ExtractionJob
+				So(json.Unmarshal(w.Body.Bytes(), &job), ShouldBeNil)
+				So(job.JobID, ShouldHaveLength, jobIDSize)
+				So(job.InstanceID, ShouldEqual, "7b6f5b7a-4c3e-4e2a-9f0a-1b2c3d4e5f60")
+
+				close(extractor.release)
+				waitExtracted(t, extractor)
+				So(extractor.events, ShouldHaveLength, 1)
+				So(extractor.events[0].FileURL, ShouldEqual, "s3://dp-frontend-florence-file-uploads/input.csv")
+				So(request.GetRequestId(extractor.contexts[0]), ShouldEqual, job.JobID)
+				So(api.Drain(ctx, time.Second), ShouldBeNil)
+			})
+		})
+
+		Convey("When a dry run is requested with the query parameter, the event is extracted with the dry run option", func() {
+			r := httptest.NewRequest(http.MethodPost, "/extractions?dry_run=true", strings.NewReader(validRequest))
+			r.Header.Set("Authorization", "Bearer token")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusAccepted)
+			var job ExtractionJob
+			So(json.Unmarshal(w.Body.Bytes(), &job), ShouldBeNil)
+			So(job.DryRun, ShouldBeTrue)
+
+			close(extractor.release)
+			waitExtracted(t, extractor)
+			So(extractor.events[0].DryRun(), ShouldBeTrue)
+			So(api.Drain(ctx, time.Second), ShouldBeNil)
+		})
+
+		Convey("When an unauthenticated extraction request is posted, it is rejected", func() {
+			w := postExtraction(router, validRequest, false)
+			So(w.Code, ShouldEqual, http.StatusUnauthorized)
+			So(extractor.events, ShouldBeEmpty)
+		})
+
+		Convey("When an extraction request without a file URL is posted, it is rejected", func() {
+			w := postExtraction(router, `{"instance_id": "7b6f5b7a-4c3e-4e2a-9f0a-1b2c3d4e5f60"}`, true)
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+			So(extractor.events, ShouldBeEmpty)
+		})
+
+		Convey("When the API is drained while a job is running", func() {
+			postExtraction(router, validRequest, true)
+			drained := make(chan error, 1)
+			go func() { drained <- api.Drain(ctx, 20*time.Millisecond) }()
+
+			Convey("Then new extraction requests are rejected, and the job is aborted after the drain timeout", func() {
+				So(<-drained, ShouldEqual, ErrDrainTimeout)
+				So(extractor.contexts[0].Err(), ShouldEqual, context.Canceled)
+				So(postExtraction(router, validRequest, true).Code, ShouldEqual, http.StatusServiceUnavailable)
+			})
+		})
+	})
+}
+
+func getExtraction(router *mux.Router, path string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestGetExtractions(t *testing.T) {
+	Convey("Given the admin API, with a finished extraction job and one in progress", t, func() {
+		router := mux.NewRouter()
+		registry := jobs.NewRegistry(10)
+		Setup(router, authenticated, newTestExtractor(), registry, &testValidator{}, testUploadLimits)
+
+		registry.Start("instance-1", "s3://bucket/file-1.csv").Finish(nil)
+		job := registry.Start("instance-2", "s3://bucket/file-2.csv")
+		job.SetPhase(jobs.PhaseScanning)
+		job.RowScanned()
+
+		Convey("When the extractions are listed, both jobs are returned, the most recent first", func() {
+			w := getExtraction(router, "/extractions")
+			So(w.Code, ShouldEqual, http.StatusOK)
+			var extractions Extractions
+			So(json.Unmarshal(w.Body.Bytes(), &extractions), ShouldBeNil)
+			So(extractions.Count, ShouldEqual, 2)
+			So(extractions.Items[0].InstanceID, ShouldEqual, "instance-2")
+			So(extractions.Items[1].Phase, ShouldEqual, jobs.PhaseCompleted)
+		})
+
+		Convey("When the extraction of an instance is requested, its progress is returned", func() {
+			w := getExtraction(router, "/extractions/instance-2")
+			So(w.Code, ShouldEqual, http.StatusOK)
+			var status jobs.Status
+			So(json.Unmarshal(w.Body.Bytes(), &status), ShouldBeNil)
+			So(status.Phase, ShouldEqual, jobs.PhaseScanning)
+			So(status.RowsScanned, ShouldEqual, 1)
+			So(status.FinishedAt, ShouldBeNil)
+		})
+
+		Convey("When the extraction of an unknown instance is requested, it is not found", func() {
+			So(getExtraction(router, "/extractions/instance-3").Code, ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	"github.com/ONSdigital/dp-dimension-extractor/service"
+	"github.com/ONSdigital/log.go/v2/log"
+	"golang.org/x/net/context"
+)
+
+// maxValidateFieldSize is the maximum size of each form field of a multipart validation request, besides the file
+const maxValidateFieldSize = 64 << 10
+
+// responseWriteTimeout is the period of time left to write the response of a validation request once its timeout has expired
+const responseWriteTimeout = 10 * time.Second
+
+// validateFilePart is the name of the form field of the file in a multipart validation request
+const validateFilePart = "file"
+
+// Validator validates a csv file uploaded to the service
+type Validator interface {
+	ValidateUpload(ctx context.Context, file io.Reader, event *service.InputFileAvailable, dimensions []dataset.VersionDimension) (*service.ValidationReport, error)
+}
+
+// UploadLimits bound the resources used to validate uploaded files
+type UploadLimits struct {
+	// MaxSize is the maximum size of a validation request, in bytes
+	MaxSize int64
+	// MaxConcurrent is the maximum number of files validated at the same time
+	MaxConcurrent int
+	// Timeout is the maximum period of time to upload and validate a file
+	Timeout time.Duration
+}
+
+// uploadBody reads the body of a request up to the maximum size, and records whether it was exceeded
+type uploadBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *uploadBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// postValidate validates a csv file streamed in the request body, or sent as the file field of a multipart form, and responds
+// with its validation report. The file is validated against the dimensions parameter or, if it is not provided, against the
+// dimensions of the instance_id instance. The parameters are read from the query string or, for a multipart form,
+// from the form fields preceding the file. Requests are rejected once the maximum number of files are being validated.
+func (api *API) postValidate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	select {
+	case api.uploads <- struct{}{}:
+		defer func() { <-api.uploads }()
+	default:
+		log.Warn(ctx, "validation request rejected, too many files are being validated", log.Data{"max_concurrent": api.uploadLimits.MaxConcurrent})
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "too many files are being validated, retry later", http.StatusTooManyRequests)
+		return
+	}
+
+	if r.ContentLength > api.uploadLimits.MaxSize {
+		http.Error(w, fmt.Sprintf("the request exceeds the maximum size of %d bytes", api.uploadLimits.MaxSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	body := &uploadBody{ReadCloser: http.MaxBytesReader(w, r.Body, api.uploadLimits.MaxSize)}
+	r.Body = body
+
+	ctx, cancel := context.WithTimeout(ctx, api.uploadLimits.Timeout)
+	defer cancel()
+	extendDeadlines(ctx, w, api.uploadLimits.Timeout)
+
+	params := r.URL.Query()
+	var file io.Reader = r.Body
+	fileName := ""
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		part, err := readMultipartParams(r, params)
+		if err != nil {
+			log.Error(ctx, "invalid multipart validation request", err)
+			status := http.StatusBadRequest
+			if body.exceeded {
+				status = http.StatusRequestEntityTooLarge
+			}
+			http.Error(w, "invalid multipart request: "+err.Error(), status)
+			return
+		}
+		file, fileName = part, part.FileName()
+	}
+
+	event, dimensions, err := validationRequest(params, fileName)
+	if err != nil {
+		log.Error(ctx, "invalid validation request", err)
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	logData := log.Data{"instance_id": event.InstanceID, "file_name": fileName, "dimensions": dimensions}
+
+	report, err := api.validator.ValidateUpload(ctx, file, event, dimensions)
+	if body.exceeded {
+		log.Warn(ctx, "uploaded file exceeds the maximum size", log.Data{"max_size": api.uploadLimits.MaxSize})
+		http.Error(w, fmt.Sprintf("the request exceeds the maximum size of %d bytes", api.uploadLimits.MaxSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		log.Error(ctx, "failed to validate uploaded file", err, logData)
+		var responseErr interface{ Code() int }
+		if errors.As(err, &responseErr) && responseErr.Code() == http.StatusNotFound {
+			http.Error(w, "instance not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to validate the file", http.StatusInternalServerError)
+		return
+	}
+
+	logData["valid"] = report.Valid
+	logData["errors"] = report.Errors
+	log.Info(ctx, "uploaded file validated", logData)
+	writeJSON(ctx, w, http.StatusOK, report)
+}
+
+// readMultipartParams adds the form fields preceding the file to params, and returns the part of the file
+func readMultipartParams(r *http.Request, params url.Values) (*multipart.Part, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no %s field found", validateFilePart)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == validateFilePart {
+			return part, nil
+		}
+
+		value, err := io.ReadAll(io.LimitReader(part, maxValidateFieldSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(value) > maxValidateFieldSize {
+			return nil, fmt.Errorf("field %s exceeds the maximum size of %d bytes", part.FormName(), maxValidateFieldSize)
+		}
+		params.Set(part.FormName(), string(value))
+	}
+}
+
+// validationRequest returns the dry run event and the dimensions to validate a file with, from the request parameters
+func validationRequest(params url.Values, fileName string) (*service.InputFileAvailable, []dataset.VersionDimension, error) {
+	event := &service.InputFileAvailable{
+		FileURL:          fileName,
+		InstanceID:       params.Get("instance_id"),
+		ExpectedChecksum: params.Get("checksum"),
+		ContentEncoding:  params.Get("content_encoding"),
+		CSVDialect:       service.CSVDialect{Delimiter: params.Get("delimiter")},
+		ProcessingOptions: map[string]string{
+			service.ProcessingOptionDryRun: "true",
+		},
+	}
+	if strict := params.Get(service.ProcessingOptionStrict); strict != "" {
+		if _, err := strconv.ParseBool(strict); err != nil {
+			return nil, nil, fmt.Errorf("invalid %s parameter: %w", service.ProcessingOptionStrict, err)
+		}
+		event.ProcessingOptions[service.ProcessingOptionStrict] = strict
+	}
+
+	var dimensions []dataset.VersionDimension
+	if value := params.Get("dimensions"); value != "" {
+		var err error
+		if dimensions, err = service.ParseDimensions(value); err != nil {
+			return nil, nil, err
+		}
+	} else if event.InstanceID == "" {
+		return nil, nil, errors.New("either instance_id or dimensions is required")
+	}
+	return event, dimensions, nil
+}
+
+// extendDeadlines extends the read and write deadlines of the connection of the request, so that a file can take longer
+// to be uploaded and validated than the timeouts of the server, which still apply to the other endpoints
+func extendDeadlines(ctx context.Context, w http.ResponseWriter, timeout time.Duration) {
+	rc := http.NewResponseController(w)
+	if err := rc.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		log.Warn(ctx, "failed to extend the read deadline of the validation request, the timeout of the server applies", log.FormatErrors([]error{err}))
+	}
+	if err := rc.SetWriteDeadline(time.Now().Add(timeout + responseWriteTimeout)); err != nil {
+		log.Warn(ctx, "failed to extend the write deadline of the validation request, the timeout of the server applies", log.FormatErrors([]error{err}))
+	}
+}
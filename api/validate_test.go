@@ -0,0 +1,207 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	"github.com/ONSdigital/dp-dimension-extractor/jobs"
+	"github.com/ONSdigital/dp-dimension-extractor/service"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+)
+
+var testUploadLimits = UploadLimits{MaxSize: 1024, MaxConcurrent: 1, Timeout: time.Second}
+
+// testValidator reads the whole file and records it with the event and dimensions, unless it is blocked until release is closed
+type testValidator struct {
+	file       string
+	event      *service.InputFileAvailable
+	dimensions []dataset.VersionDimension
+	started    chan struct{}
+	release    chan struct{}
+	err        error
+}
+
+func (v *testValidator) ValidateUpload(ctx context.Context, file io.Reader, event *service.InputFileAvailable, dimensions []dataset.VersionDimension) (*service.ValidationReport, error) {
+	if v.release != nil {
+		close(v.started)
+		<-v.release
+	}
+	content, err := io.ReadAll(file)
+	v.file, v.event, v.dimensions = string(content), event, dimensions
+	if err != nil {
+		return &service.ValidationReport{Valid: false}, nil
+	}
+	if v.err != nil {
+		return nil, v.err
+	}
+	return &service.ValidationReport{InstanceID: event.InstanceID, FileURL: event.FileURL, Valid: true}, nil
+}
+
+// testResponseError is an error of the dataset API with a response status code
+type testResponseError struct {
+	code int
+}
+
+func (e *testResponseError) Error() string { return "dataset api error" }
+func (e *testResponseError) Code() int     { return e.code }
+
+func postValidate(router *mux.Router, target, contentType string, body io.Reader) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, target, body)
+	r.Header.Set("Authorization", "Bearer token")
+	r.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func multipartBody(fields map[string]string, fileName, content string) (string, *bytes.Buffer) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, value := range fields {
+		writer.WriteField(name, value)
+	}
+	part, _ := writer.CreateFormFile(validateFilePart, fileName)
+	part.Write([]byte(content))
+	writer.Close()
+	return writer.FormDataContentType(), body
+}
+
+func TestPostValidate(t *testing.T) {
+	Convey("Given the admin API", t, func() {
+		router := mux.NewRouter()
+		validator := &testValidator{}
+		Setup(router, authenticated, newTestExtractor(), jobs.NewRegistry(10), validator, testUploadLimits)
+
+		Convey("When a csv file is streamed with the dimensions in the query string, its report is returned", func() {
+			w := postValidate(router, "/validate?dimensions=time=mmm-yy,geography=uk-only&strict=true", "text/csv", strings.NewReader("V4_0,Time,Time\n"))
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			var report service.ValidationReport
+			So(json.Unmarshal(w.Body.Bytes(), &report), ShouldBeNil)
+			So(report.Valid, ShouldBeTrue)
+			So(validator.file, ShouldEqual, "V4_0,Time,Time\n")
+			So(validator.dimensions, ShouldResemble, []dataset.VersionDimension{{Name: "time", ID: "mmm-yy"}, {Name: "geography", ID: "uk-only"}})
+			So(validator.event.DryRun(), ShouldBeTrue)
+			So(validator.event.Strict(), ShouldBeTrue)
+		})
+
+		Convey("When a csv file is sent in a multipart form after the instance ID, it is validated against the instance", func() {
+			contentType, body := multipartBody(map[string]string{"instance_id": "123", "delimiter": ";"}, "input.csv", "V4_0;Time;Time\n")
+			w := postValidate(router, "/validate", contentType, body)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(validator.file, ShouldEqual, "V4_0;Time;Time\n")
+			So(validator.dimensions, ShouldBeEmpty)
+			So(validator.event.InstanceID, ShouldEqual, "123")
+			So(validator.event.FileURL, ShouldEqual, "input.csv")
+			So(validator.event.CSVDialect.Delimiter, ShouldEqual, ";")
+		})
+
+		Convey("When a multipart form without a file is sent, it is rejected", func() {
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			writer.WriteField("instance_id", "123")
+			writer.Close()
+
+			So(postValidate(router, "/validate", writer.FormDataContentType(), body).Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("When a file is sent without an instance ID nor dimensions, it is rejected", func() {
+			So(postValidate(router, "/validate", "text/csv", strings.NewReader("V4_0\n")).Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("When a file larger than the maximum size is streamed, it is rejected", func() {
+			// the content length is unknown, so the size is only checked as the file is read
+			w := postValidate(router, "/validate?instance_id=123", "text/csv", io.MultiReader(strings.NewReader(strings.Repeat("a", 2048))))
+			So(w.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+		})
+
+		Convey("When the instance does not exist, the request is not found", func() {
+			validator.err = &service.ClassifiedError{Class: service.ErrorClassDatasetAPI, Err: &testResponseError{code: http.StatusNotFound}}
+			So(postValidate(router, "/validate?instance_id=123", "text/csv", strings.NewReader("V4_0\n")).Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("When the file cannot be validated, an internal error is returned", func() {
+			validator.err = errors.New("dataset api unavailable")
+			So(postValidate(router, "/validate?instance_id=123", "text/csv", strings.NewReader("V4_0\n")).Code, ShouldEqual, http.StatusInternalServerError)
+		})
+
+		Convey("When the maximum number of files are being validated, further requests are rejected", func() {
+			validator.started, validator.release = make(chan struct{}), make(chan struct{})
+			first := make(chan int)
+			go func() {
+				first <- postValidate(router, "/validate?instance_id=123", "text/csv", strings.NewReader("V4_0\n")).Code
+			}()
+			<-validator.started
+
+			w := postValidate(router, "/validate?instance_id=123", "text/csv", strings.NewReader("V4_0\n"))
+			So(w.Code, ShouldEqual, http.StatusTooManyRequests)
+			So(w.Header().Get("Retry-After"), ShouldEqual, "1")
+
+			close(validator.release)
+			So(<-first, ShouldEqual, http.StatusOK)
+		})
+	})
+}
+
+func TestPostValidateDeadlines(t *testing.T) {
+	Convey("Given the admin API served with read and write timeouts shorter than the upload timeout", t, func() {
+		router := mux.NewRouter()
+		validator := &testValidator{}
+		Setup(router, authenticated, newTestExtractor(), jobs.NewRegistry(10), validator, testUploadLimits)
+		srv := httptest.NewUnstartedServer(router)
+		srv.Config.ReadTimeout = 100 * time.Millisecond
+		srv.Config.WriteTimeout = 100 * time.Millisecond
+		srv.Start()
+		defer srv.Close()
+
+		Convey("When a csv file is uploaded more slowly than the timeouts of the server allow, it is still validated", func() {
+			body, upload := io.Pipe()
+			go func() {
+				upload.Write([]byte("V4_0,Time,"))
+				time.Sleep(300 * time.Millisecond)
+				upload.Write([]byte("Time\n"))
+				upload.Close()
+			}()
+			r, err := http.NewRequest(http.MethodPost, srv.URL+"/validate?dimensions=time=mmm-yy", body)
+			So(err, ShouldBeNil)
+			r.Header.Set("Authorization", "Bearer token")
+			r.Header.Set("Content-Type", "text/csv")
+			resp, err := srv.Client().Do(r)
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(validator.file, ShouldEqual, "V4_0,Time,Time\n")
+		})
+
+		Convey("When another endpoint is requested more slowly than the timeouts of the server allow, the request fails", func() {
+			body, upload := io.Pipe()
+			go func() {
+				upload.Write([]byte(`{"file_url":`))
+				time.Sleep(300 * time.Millisecond)
+				upload.Write([]byte(`"s3://bucket/file.csv","instance_id":"123"}`))
+				upload.Close()
+			}()
+			r, err := http.NewRequest(http.MethodPost, srv.URL+"/extractions", body)
+			So(err, ShouldBeNil)
+			r.Header.Set("Authorization", "Bearer token")
+			resp, err := srv.Client().Do(r)
+			if err == nil {
+				defer resp.Body.Close()
+				So(resp.StatusCode, ShouldNotEqual, http.StatusAccepted)
+			}
+		})
+	})
+}
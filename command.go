@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	"github.com/ONSdigital/dp-dimension-extractor/config"
+	"github.com/ONSdigital/dp-dimension-extractor/initialise"
+	"github.com/ONSdigital/dp-dimension-extractor/service"
+	"github.com/ONSdigital/log.go/v2/log"
+	"golang.org/x/net/context"
+)
+
+const (
+	// commandResetOffsets is the admin command resetting the offsets of the consumer group, e.g. to replay a window of imports
+	commandResetOffsets = "reset-offsets"
+	// commandValidate validates a local csv file, without S3 nor the dataset API
+	commandValidate = "validate"
+	// commandExtract validates a local csv file and lists the dimension options extracted from it
+	commandExtract = "extract"
+)
+
+// Output formats of the validate and extract commands
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// errInvalidFile is returned by the validate and extract commands when the file is not valid, once its errors are written
+var errInvalidFile = errors.New("the csv file is not valid")
+
+// runCommand runs the command given by the command line arguments, writing its output to w
+func runCommand(ctx context.Context, cfg *config.Config, args []string, w io.Writer) error {
+	switch args[0] {
+	case commandResetOffsets:
+		return resetOffsets(ctx, cfg, args[1:], w)
+	case commandValidate, commandExtract:
+		return validateFile(ctx, args[0], args[1:], w)
+	default:
+		return fmt.Errorf("unknown command %q, the supported commands are %s", args[0], strings.Join([]string{commandResetOffsets, commandValidate, commandExtract}, ", "))
+	}
+}
+
+// resetOffsets resets the offsets of INPUT_FILE_AVAILABLE_GROUP for INPUT_FILE_AVAILABLE_TOPIC to the provided timestamp,
+// and writes the previous and new offset of each partition
+func resetOffsets(ctx context.Context, cfg *config.Config, args []string, w io.Writer) error {
+	flags := flag.NewFlagSet(commandResetOffsets, flag.ContinueOnError)
+	flags.SetOutput(w)
+	to := flags.String("to", "", "the RFC 3339 timestamp to reset the offsets to, e.g. 2024-01-31T09:00:00Z")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *to == "" {
+		return errors.New("the -to flag is required")
+	}
+	toTime, err := time.Parse(time.RFC3339, *to)
+	if err != nil {
+		return fmt.Errorf("invalid -to timestamp: %w", err)
+	}
+
+	offsets, err := initialise.ResetConsumerOffsets(ctx, &cfg.KafkaConfig, toTime)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "reset offsets of group %s for topic %s to %s\n", cfg.KafkaConfig.InputFileAvailableGroup, cfg.KafkaConfig.InputFileAvailableTopic, toTime.Format(time.RFC3339))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PARTITION\tPREVIOUS\tOFFSET")
+	for _, offset := range offsets {
+		previous := "none"
+		if offset.Previous >= 0 {
+			previous = fmt.Sprint(offset.Previous)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\n", offset.Partition, previous, offset.Offset)
+	}
+	return tw.Flush()
+}
+
+// extractOutput is the JSON output of the extract command
+type extractOutput struct {
+	Report  *service.ValidationReport `json:"report"`
+	Options []dataset.OptionPost      `json:"options"`
+}
+
+// validateFile validates a local csv file against the dimensions given on the command line, as a dry run would,
+// and writes its validation report, along with the extracted dimension options for the extract command
+func validateFile(ctx context.Context, command string, args []string, w io.Writer) error {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(w)
+	flags.Usage = func() {
+		fmt.Fprintf(w, "usage: dp-dimension-extractor %s [flags] <csv file>\n", command)
+		flags.PrintDefaults()
+	}
+	keyFile := flags.String("key-file", "", "the file of the hex encoded pre-shared key the csv file is encrypted with, if it is encrypted")
+	dimensions := flags.String("dimensions", "", "the code list of each dimension of the instance, e.g. time=mmm-yy,geography=uk-only")
+	dimensionsFile := flags.String("dimensions-file", "", "a JSON file with the instance, as returned by the dataset API, or with its list of dimensions")
+	format := flags.String("format", formatTable, "the output format, table or json")
+	contentEncoding := flags.String("content-encoding", "", "the content encoding of the csv file, identity or gzip")
+	delimiter := flags.String("delimiter", "", "the field delimiter of the csv file, a comma by default")
+	checksum := flags.String("checksum", "", "the expected SHA-256 checksum of the (decrypted) csv file")
+	strict := flags.Bool("strict", false, "reject the file if it has no observations or no column for a dimension of the instance")
+	verbose := flags.Bool("verbose", false, "write the logs of the extraction to stderr")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("a single csv file is required")
+	}
+	if *format != formatTable && *format != formatJSON {
+		return fmt.Errorf("invalid -format %q, must be %s or %s", *format, formatTable, formatJSON)
+	}
+	path := flags.Arg(0)
+
+	instance, err := readInstance(*dimensions, *dimensionsFile)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var content io.Reader = file
+	if *keyFile != "" {
+		psk, err := service.ReadPSKFile(*keyFile)
+		if err != nil {
+			return fmt.Errorf("invalid -key-file: %w", err)
+		}
+		if content, err = service.NewDecryptingReader(file, psk); err != nil {
+			return fmt.Errorf("invalid -key-file: %w", err)
+		}
+	}
+
+	event := &service.InputFileAvailable{
+		FileURL:          path,
+		ExpectedChecksum: *checksum,
+		ContentEncoding:  *contentEncoding,
+		CSVDialect:       service.CSVDialect{Delimiter: *delimiter},
+		ProcessingOptions: map[string]string{
+			service.ProcessingOptionDryRun: "true",
+			service.ProcessingOptionStrict: strconv.FormatBool(*strict),
+		},
+	}
+
+	// the service logs every step of the extraction, which is only useful to investigate an unexpected error
+	if !*verbose {
+		log.SetDestination(io.Discard, io.Discard)
+	}
+	report, options, err := service.ValidateFile(ctx, content, event, instance)
+	log.SetDestination(os.Stderr, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	if *format == formatJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if command == commandExtract {
+			err = encoder.Encode(extractOutput{Report: report, Options: options})
+		} else {
+			err = encoder.Encode(report)
+		}
+	} else {
+		err = writeReport(w, report)
+		if err == nil && command == commandExtract && report.Valid {
+			err = writeOptions(w, options)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if !report.Valid {
+		return errInvalidFile
+	}
+	return nil
+}
+
+// readInstance returns an instance with the dimensions given by the -dimensions flag, or read from the -dimensions-file
+func readInstance(dimensions, dimensionsFile string) (dataset.Instance, error) {
+	var instance dataset.Instance
+	switch {
+	case dimensions != "" && dimensionsFile != "":
+		return instance, errors.New("only one of the -dimensions and -dimensions-file flags can be set")
+	case dimensions != "":
+		dimensions, err := service.ParseDimensions(dimensions)
+		if err != nil {
+			return instance, fmt.Errorf("invalid -dimensions: %w", err)
+		}
+		instance.Dimensions = dimensions
+	case dimensionsFile != "":
+		b, err := os.ReadFile(dimensionsFile)
+		if err != nil {
+			return instance, err
+		}
+		if bytes.HasPrefix(bytes.TrimSpace(b), []byte("[")) {
+			err = json.Unmarshal(b, &instance.Dimensions)
+		} else {
+			err = json.Unmarshal(b, &instance)
+		}
+		if err != nil {
+			return instance, fmt.Errorf("invalid -dimensions-file: %w", err)
+		}
+		if len(instance.Dimensions) == 0 {
+			return instance, fmt.Errorf("no dimensions found in %s", dimensionsFile)
+		}
+	default:
+		return instance, errors.New("the dimensions of the instance are required, with the -dimensions or -dimensions-file flag")
+	}
+	return instance, nil
+}
+
+// writeReport writes the validation report as a summary followed by tables of the dimensions, conflicts and errors
+func writeReport(w io.Writer, report *service.ValidationReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "file:\t%s\n", report.FileURL)
+	fmt.Fprintf(tw, "valid:\t%t\n", report.Valid)
+	fmt.Fprintf(tw, "checksum:\t%s\n", report.FileChecksum)
+	fmt.Fprintf(tw, "observations:\t%d\n", report.NumberOfObservations)
+	fmt.Fprintf(tw, "blank observations:\t%d\n", report.Statistics.BlankObservations)
+	fmt.Fprintf(tw, "dimension options:\t%d\n", report.Statistics.DimensionOptions)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(report.DimensionOptionCounts) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(tw, "DIMENSION\tOPTIONS")
+		for _, name := range slices.Sorted(maps.Keys(report.DimensionOptionCounts)) {
+			fmt.Fprintf(tw, "%s\t%d\n", name, report.DimensionOptionCounts[name])
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(report.Conflicts) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(tw, "DIMENSION\tCODE\tLABELS")
+		for _, conflict := range report.Conflicts {
+			fmt.Fprintf(tw, "%s\t%s\t%q\n", conflict.Dimension, conflict.Code, conflict.Labels)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(report.Errors) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(tw, "ERROR\tROW\tMESSAGE")
+		for _, validationErr := range report.Errors {
+			row := "-"
+			if validationErr.Row > 0 {
+				row = strconv.Itoa(validationErr.Row)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", validationErr.Class, row, validationErr.Message)
+		}
+	}
+	return tw.Flush()
+}
+
+// writeOptions writes a table of the extracted dimension options
+func writeOptions(w io.Writer, options []dataset.OptionPost) error {
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DIMENSION\tCODE\tLABEL\tCODE LIST")
+	for _, option := range options {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", option.Name, option.Code, option.Label, option.CodeList)
+	}
+	return tw.Flush()
+}
@@ -12,6 +12,50 @@ import (
 // KafkaTLSProtocolFlag informs service to use TLS protocol for kafka
 const KafkaTLSProtocolFlag = "TLS"
 
+// Possible values of KAFKA_CONSUMER_INITIAL_OFFSET, besides an RFC 3339 timestamp
+const (
+	// KafkaInitialOffsetOldest consumes from the oldest message, for partitions without a committed offset
+	KafkaInitialOffsetOldest = "oldest"
+	// KafkaInitialOffsetNewest consumes from the messages produced after the consumer has joined the group, for partitions without a committed offset
+	KafkaInitialOffsetNewest = "newest"
+)
+
+// Possible values of OTEL_TRACES_EXPORTER
+const (
+	// OTelExporterOTLP exports spans to an OTLP collector over HTTP
+	OTelExporterOTLP = "otlp"
+	// OTelExporterStdout writes spans to stdout, intended for local testing
+	OTelExporterStdout = "stdout"
+)
+
+// Possible values of DUPLICATE_EVENT_STORE
+const (
+	// DuplicateEventStoreMemory records processed events in an in-memory LRU cache
+	DuplicateEventStoreMemory = "memory"
+	// DuplicateEventStoreBolt records processed events in an embedded database file, which survives restarts
+	DuplicateEventStoreBolt = "bolt"
+	// DuplicateEventStoreNone disables the suppression of duplicate events
+	DuplicateEventStoreNone = "none"
+)
+
+// Possible values of MESSAGE_ENCODING
+const (
+	// MessageEncodingAvro encodes messages with Avro
+	MessageEncodingAvro = "avro"
+	// MessageEncodingJSON encodes messages as JSON, validated against a JSON Schema
+	MessageEncodingJSON = "json"
+)
+
+// Possible values of FILE_SOURCES
+const (
+	// FileSourceS3 retrieves files from s3:// URLs, and path-style https:// URLs of S3
+	FileSourceS3 = "s3"
+	// FileSourceFile retrieves local files from file:// URLs, intended for local development and tests
+	FileSourceFile = "file"
+	// FileSourceHTTPS retrieves files from https:// URLs
+	FileSourceHTTPS = "https"
+)
+
 var cfg *Config
 
 // Config is the filing resource handler config
@@ -19,15 +63,47 @@ type Config struct {
 	AWSRegion                  string        `envconfig:"AWS_REGION"`
 	BindAddr                   string        `envconfig:"BIND_ADDR"`
 	DatasetAPIURL              string        `envconfig:"DATASET_API_URL"`
+	DrainTimeout               time.Duration `envconfig:"DRAIN_TIMEOUT"`
+	DuplicateEventCacheSize    int           `envconfig:"DUPLICATE_EVENT_CACHE_SIZE"`
+	DuplicateEventStore        string        `envconfig:"DUPLICATE_EVENT_STORE"`
+	DuplicateEventStorePath    string        `envconfig:"DUPLICATE_EVENT_STORE_PATH"`
+	DuplicateEventTTL          time.Duration `envconfig:"DUPLICATE_EVENT_TTL"`
 	EncryptionDisabled         bool          `envconfig:"ENCRYPTION_DISABLED"`
+	ExtractionJobsRetained     int           `envconfig:"EXTRACTION_JOBS_RETAINED"`
+	FileSourceDir              string        `envconfig:"FILE_SOURCE_DIR"`
+	FileSourceHTTPSTimeout     time.Duration `envconfig:"FILE_SOURCE_HTTPS_TIMEOUT"`
+	FileSources                []string      `envconfig:"FILE_SOURCES"`
 	GracefulShutdownTimeout    time.Duration `envconfig:"GRACEFUL_SHUTDOWN_TIMEOUT"`
+	HandlerMaxRetries          int           `envconfig:"HANDLER_MAX_RETRIES"`
+	HandlerRetryBackoff        time.Duration `envconfig:"HANDLER_RETRY_BACKOFF"`
+	InputAllowedContentTypes   []string      `envconfig:"INPUT_ALLOWED_CONTENT_TYPES"`
+	InputMaxSize               int64         `envconfig:"INPUT_MAX_SIZE"`
+	InputRejectEmpty           bool          `envconfig:"INPUT_REJECT_EMPTY"`
+	InputRequiredMetadata      []string      `envconfig:"INPUT_REQUIRED_METADATA"`
 	KafkaConfig                KafkaConfig
 	LocalstackHost             string        `envconfig:"LOCALSTACK_HOST"`
+	MarkInstanceFailed         bool          `envconfig:"MARK_INSTANCE_FAILED"`
 	MaxRetries                 int           `envconfig:"REQUEST_MAX_RETRIES"`
+	MessageEncoding            string        `envconfig:"MESSAGE_ENCODING"`
+	OTelEnabled                bool          `envconfig:"OTEL_ENABLED"`
+	OTelExporter               string        `envconfig:"OTEL_TRACES_EXPORTER"`
+	OTelExporterOtlpEndpoint   string        `envconfig:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	OTelServiceName            string        `envconfig:"OTEL_SERVICE_NAME"`
+	S3ChecksumMetadataKey      string        `envconfig:"S3_CHECKSUM_METADATA_KEY"`
+	S3DownloadConcurrency      int           `envconfig:"S3_DOWNLOAD_CONCURRENCY"`
+	S3DownloadDir              string        `envconfig:"S3_DOWNLOAD_DIR"`
+	S3DownloadPartSize         int64         `envconfig:"S3_DOWNLOAD_PART_SIZE"`
+	S3ReadMaxRetries           int           `envconfig:"S3_READ_MAX_RETRIES"`
+	S3ReadRetryBackoff         time.Duration `envconfig:"S3_READ_RETRY_BACKOFF"`
+	S3VerifyETag               bool          `envconfig:"S3_VERIFY_ETAG"`
+	SchemaRegistryURL          string        `envconfig:"SCHEMA_REGISTRY_URL"`
 	VaultAddr                  string        `envconfig:"VAULT_ADDR"`
 	VaultToken                 string        `envconfig:"VAULT_TOKEN"                    json:"-"`
 	VaultPath                  string        `envconfig:"VAULT_PATH"`
 	ServiceAuthToken           string        `envconfig:"SERVICE_AUTH_TOKEN"             json:"-"`
+	ValidateMaxConcurrent      int           `envconfig:"VALIDATE_MAX_CONCURRENT"`
+	ValidateMaxUploadSize      int64         `envconfig:"VALIDATE_MAX_UPLOAD_SIZE"`
+	ValidateTimeout            time.Duration `envconfig:"VALIDATE_TIMEOUT"`
 	ZebedeeURL                 string        `envconfig:"ZEBEDEE_URL"`
 	HealthCheckInterval        time.Duration `envconfig:"HEALTHCHECK_INTERVAL"`
 	HealthCheckCriticalTimeout time.Duration `envconfig:"HEALTHCHECK_CRITICAL_TIMEOUT"`
@@ -36,18 +112,22 @@ type Config struct {
 
 // KafkaConfig contains the config required to connect to Kafka
 type KafkaConfig struct {
-	BindAddr                 []string `envconfig:"KAFKA_ADDR"                            json:"-"`
-	MaxBytes                 string   `envconfig:"KAFKA_MAX_BYTES"`
-	Version                  string   `envconfig:"KAFKA_VERSION"`
-	SecProtocol              string   `envconfig:"KAFKA_SEC_PROTO"`
-	SecCACerts               string   `envconfig:"KAFKA_SEC_CA_CERTS"`
-	SecClientCert            string   `envconfig:"KAFKA_SEC_CLIENT_CERT"`
-	SecClientKey             string   `envconfig:"KAFKA_SEC_CLIENT_KEY"                  json:"-"`
-	SecSkipVerify            bool     `envconfig:"KAFKA_SEC_SKIP_VERIFY"`
-	DimensionsExtractedTopic string   `envconfig:"DIMENSIONS_EXTRACTED_TOPIC"`
-	EventReporterTopic       string   `envconfig:"EVENT_REPORTER_TOPIC"`
-	InputFileAvailableGroup  string   `envconfig:"INPUT_FILE_AVAILABLE_GROUP"`
-	InputFileAvailableTopic  string   `envconfig:"INPUT_FILE_AVAILABLE_TOPIC"`
+	BindAddr                 []string      `envconfig:"KAFKA_ADDR"                            json:"-"`
+	MaxBytes                 string        `envconfig:"KAFKA_MAX_BYTES"`
+	Version                  string        `envconfig:"KAFKA_VERSION"`
+	SecProtocol              string        `envconfig:"KAFKA_SEC_PROTO"`
+	SecCACerts               string        `envconfig:"KAFKA_SEC_CA_CERTS"`
+	SecClientCert            string        `envconfig:"KAFKA_SEC_CLIENT_CERT"`
+	SecClientKey             string        `envconfig:"KAFKA_SEC_CLIENT_KEY"                  json:"-"`
+	SecSkipVerify            bool          `envconfig:"KAFKA_SEC_SKIP_VERIFY"`
+	DeliveryTimeout          time.Duration `envconfig:"KAFKA_PRODUCER_DELIVERY_TIMEOUT"`
+	ProducerMaxRetries       int           `envconfig:"KAFKA_PRODUCER_MAX_RETRIES"`
+	ProducerRetryBackoff     time.Duration `envconfig:"KAFKA_PRODUCER_RETRY_BACKOFF"`
+	InitialOffset            string        `envconfig:"KAFKA_CONSUMER_INITIAL_OFFSET"`
+	DimensionsExtractedTopic string        `envconfig:"DIMENSIONS_EXTRACTED_TOPIC"`
+	EventReporterTopic       string        `envconfig:"EVENT_REPORTER_TOPIC"`
+	InputFileAvailableGroup  string        `envconfig:"INPUT_FILE_AVAILABLE_GROUP"`
+	InputFileAvailableTopic  string        `envconfig:"INPUT_FILE_AVAILABLE_TOPIC"`
 }
 
 func getDefaultConfig() *Config {
@@ -55,8 +135,21 @@ func getDefaultConfig() *Config {
 		AWSRegion:               "eu-west-1",
 		BindAddr:                ":21400",
 		DatasetAPIURL:           "http://localhost:22000",
+		DrainTimeout:            20 * time.Second,
+		DuplicateEventCacheSize: 1000,
+		DuplicateEventStore:     DuplicateEventStoreMemory,
+		DuplicateEventStorePath: "processed-events.db",
+		DuplicateEventTTL:       time.Hour,
 		EncryptionDisabled:      false,
+		ExtractionJobsRetained:  100,
+		FileSourceDir:           "",
+		FileSourceHTTPSTimeout:  5 * time.Minute,
+		FileSources:             []string{FileSourceS3},
 		GracefulShutdownTimeout: 5 * time.Second,
+		HandlerMaxRetries:       3,
+		HandlerRetryBackoff:     5 * time.Second,
+		InputMaxSize:            0,
+		InputRejectEmpty:        true,
 		KafkaConfig: KafkaConfig{
 			BindAddr:                 []string{"localhost:9092", "localhost:9093", "localhost:9094"},
 			MaxBytes:                 "2000000",
@@ -66,16 +159,37 @@ func getDefaultConfig() *Config {
 			SecClientCert:            "",
 			SecClientKey:             "",
 			SecSkipVerify:            false,
+			DeliveryTimeout:          10 * time.Second,
+			ProducerMaxRetries:       3,
+			ProducerRetryBackoff:     5 * time.Second,
+			InitialOffset:            KafkaInitialOffsetOldest,
 			DimensionsExtractedTopic: "dimensions-extracted",
 			EventReporterTopic:       "report-events",
 			InputFileAvailableTopic:  "input-file-available",
 			InputFileAvailableGroup:  "input-file-available",
 		},
+		MarkInstanceFailed:         false,
 		MaxRetries:                 3,
+		MessageEncoding:            MessageEncodingAvro,
+		OTelEnabled:                false,
+		OTelExporter:               "otlp",
+		OTelExporterOtlpEndpoint:   "localhost:4318",
+		OTelServiceName:            "dp-dimension-extractor",
+		S3ChecksumMetadataKey:      "sha256",
+		S3DownloadConcurrency:      0,
+		S3DownloadDir:              "",
+		S3DownloadPartSize:         16 << 20,
+		S3ReadMaxRetries:           3,
+		S3ReadRetryBackoff:         500 * time.Millisecond,
+		S3VerifyETag:               false,
+		SchemaRegistryURL:          "",
 		VaultAddr:                  "http://localhost:8200",
 		VaultToken:                 "",
 		VaultPath:                  "secret/shared/psk",
 		ServiceAuthToken:           "E45F9BFC-3854-46AE-8187-11326A4E00F4",
+		ValidateMaxConcurrent:      2,
+		ValidateMaxUploadSize:      100 << 20,
+		ValidateTimeout:            5 * time.Minute,
 		ZebedeeURL:                 "http://localhost:8082",
 		HealthCheckInterval:        30 * time.Second,
 		HealthCheckCriticalTimeout: 90 * time.Second,
@@ -101,9 +215,46 @@ func Get() (*Config, error) {
 		return nil, fmt.Errorf("kafka config validation errors: %v", strings.Join(errs, ", "))
 	}
 
+	if errs := cfg.validateOTelValues(); len(errs) != 0 {
+		return nil, fmt.Errorf("otel config validation errors: %v", strings.Join(errs, ", "))
+	}
+
+	if errs := cfg.validateDuplicateEventValues(); len(errs) != 0 {
+		return nil, fmt.Errorf("duplicate event config validation errors: %v", strings.Join(errs, ", "))
+	}
+
+	if errs := cfg.validateMessageEncodingValues(); len(errs) != 0 {
+		return nil, fmt.Errorf("message encoding config validation errors: %v", strings.Join(errs, ", "))
+	}
+
+	if errs := cfg.validateFileSourceValues(); len(errs) != 0 {
+		return nil, fmt.Errorf("file source config validation errors: %v", strings.Join(errs, ", "))
+	}
+
+	if errs := cfg.validateInputValues(); len(errs) != 0 {
+		return nil, fmt.Errorf("input file config validation errors: %v", strings.Join(errs, ", "))
+	}
+
+	if errs := cfg.validateValidateValues(); len(errs) != 0 {
+		return nil, fmt.Errorf("validate config validation errors: %v", strings.Join(errs, ", "))
+	}
+
 	return cfg, nil
 }
 
+// InitialOffsetTime returns the time of the initial offset, if it is a timestamp rather than the oldest or newest offset
+func (kafkaConfig KafkaConfig) InitialOffsetTime() (initialTime time.Time, isTimestamp bool, err error) {
+	switch kafkaConfig.InitialOffset {
+	case KafkaInitialOffsetOldest, KafkaInitialOffsetNewest:
+		return time.Time{}, false, nil
+	}
+	initialTime, err = time.Parse(time.RFC3339, kafkaConfig.InitialOffset)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return initialTime, true, nil
+}
+
 // String is implemented to prevent sensitive fields being logged.
 // The config is returned as JSON with sensitive fields omitted.
 func (config Config) String() string {
@@ -24,25 +24,61 @@ func TestGet(t *testing.T) {
 					So(cfg.AWSRegion, ShouldEqual, "eu-west-1")
 					So(cfg.BindAddr, ShouldEqual, ":21400")
 					So(cfg.DatasetAPIURL, ShouldEqual, "http://localhost:22000")
+					So(cfg.DuplicateEventCacheSize, ShouldEqual, 1000)
+					So(cfg.DuplicateEventStore, ShouldEqual, "memory")
+					So(cfg.DuplicateEventStorePath, ShouldEqual, "processed-events.db")
+					So(cfg.DuplicateEventTTL, ShouldEqual, time.Hour)
 					So(cfg.EncryptionDisabled, ShouldEqual, false)
 					So(cfg.GracefulShutdownTimeout, ShouldEqual, 5*time.Second)
+					So(cfg.HandlerMaxRetries, ShouldEqual, 3)
+					So(cfg.HandlerRetryBackoff, ShouldEqual, 5*time.Second)
 					So(cfg.KafkaConfig.BindAddr, ShouldResemble, []string{"localhost:9092", "localhost:9093", "localhost:9094"})
 					So(cfg.KafkaConfig.MaxBytes, ShouldEqual, "2000000")
 					So(cfg.KafkaConfig.Version, ShouldEqual, "1.0.2")
 					So(cfg.KafkaConfig.SecProtocol, ShouldEqual, "")
 					So(cfg.KafkaConfig.SecCACerts, ShouldEqual, "")
+					So(cfg.InputAllowedContentTypes, ShouldBeEmpty)
+					So(cfg.InputMaxSize, ShouldEqual, 0)
+					So(cfg.InputRejectEmpty, ShouldBeTrue)
+					So(cfg.InputRequiredMetadata, ShouldBeEmpty)
 					So(cfg.KafkaConfig.SecClientCert, ShouldEqual, "")
 					So(cfg.KafkaConfig.SecClientKey, ShouldEqual, "")
 					So(cfg.KafkaConfig.SecSkipVerify, ShouldEqual, false)
+					So(cfg.KafkaConfig.DeliveryTimeout, ShouldEqual, 10*time.Second)
+					So(cfg.KafkaConfig.ProducerMaxRetries, ShouldEqual, 3)
+					So(cfg.KafkaConfig.ProducerRetryBackoff, ShouldEqual, 5*time.Second)
+					So(cfg.KafkaConfig.InitialOffset, ShouldEqual, "oldest")
 					So(cfg.KafkaConfig.DimensionsExtractedTopic, ShouldEqual, "dimensions-extracted")
 					So(cfg.KafkaConfig.EventReporterTopic, ShouldEqual, "report-events")
 					So(cfg.KafkaConfig.InputFileAvailableGroup, ShouldEqual, "input-file-available")
 					So(cfg.KafkaConfig.InputFileAvailableTopic, ShouldEqual, "input-file-available")
+					So(cfg.DrainTimeout, ShouldEqual, 20*time.Second)
+					So(cfg.ExtractionJobsRetained, ShouldEqual, 100)
+					So(cfg.FileSourceDir, ShouldEqual, "")
+					So(cfg.FileSourceHTTPSTimeout, ShouldEqual, 5*time.Minute)
+					So(cfg.FileSources, ShouldResemble, []string{"s3"})
+					So(cfg.MarkInstanceFailed, ShouldEqual, false)
 					So(cfg.MaxRetries, ShouldEqual, 3)
+					So(cfg.MessageEncoding, ShouldEqual, "avro")
+					So(cfg.OTelEnabled, ShouldEqual, false)
+					So(cfg.OTelExporter, ShouldEqual, "otlp")
+					So(cfg.OTelExporterOtlpEndpoint, ShouldEqual, "localhost:4318")
+					So(cfg.OTelServiceName, ShouldEqual, "dp-dimension-extractor")
+					So(cfg.S3ChecksumMetadataKey, ShouldEqual, "sha256")
+					So(cfg.S3DownloadConcurrency, ShouldEqual, 0)
+					So(cfg.S3DownloadDir, ShouldEqual, "")
+					So(cfg.S3DownloadPartSize, ShouldEqual, 16<<20)
+					So(cfg.S3ReadMaxRetries, ShouldEqual, 3)
+					So(cfg.S3ReadRetryBackoff, ShouldEqual, 500*time.Millisecond)
+					So(cfg.S3VerifyETag, ShouldBeFalse)
+					So(cfg.SchemaRegistryURL, ShouldEqual, "")
 					So(cfg.VaultAddr, ShouldEqual, "http://localhost:8200")
 					So(cfg.VaultPath, ShouldEqual, "secret/shared/psk")
 					So(cfg.VaultToken, ShouldEqual, "")
 					So(cfg.ServiceAuthToken, ShouldEqual, "Bearer E45F9BFC-3854-46AE-8187-11326A4E00F4")
+					So(cfg.ValidateMaxConcurrent, ShouldEqual, 2)
+					So(cfg.ValidateMaxUploadSize, ShouldEqual, 100<<20)
+					So(cfg.ValidateTimeout, ShouldEqual, 5*time.Minute)
 					So(cfg.ZebedeeURL, ShouldEqual, "http://localhost:8082")
 					So(cfg.HealthCheckInterval, ShouldEqual, 30*time.Second)
 					So(cfg.HealthCheckCriticalTimeout, ShouldEqual, 90*time.Second)
@@ -62,6 +98,18 @@ func TestGet(t *testing.T) {
 				So(err, ShouldResemble, errors.New("kafka config validation errors: KAFKA_SEC_PROTO has invalid value"))
 			})
 		})
+
+		Convey("When configuration is called with tracing enabled and an invalid exporter", func() {
+			defer os.Clearenv()
+			os.Setenv("OTEL_ENABLED", "true")
+			os.Setenv("OTEL_TRACES_EXPORTER", "zipkin")
+			cfg, err := Get()
+
+			Convey("Then an error should be returned", func() {
+				So(cfg, ShouldBeNil)
+				So(err, ShouldResemble, errors.New("otel config validation errors: OTEL_TRACES_EXPORTER has invalid value"))
+			})
+		})
 	})
 }
 
@@ -85,8 +133,14 @@ func TestString(t *testing.T) {
 					So(cfgStr, ShouldContainSubstring, "BindAddr")
 					So(cfgStr, ShouldContainSubstring, "DatasetAPIURL")
 					So(cfgStr, ShouldContainSubstring, "DimensionsExtractedTopic")
+					So(cfgStr, ShouldContainSubstring, "DuplicateEventCacheSize")
+					So(cfgStr, ShouldContainSubstring, "DuplicateEventStore")
+					So(cfgStr, ShouldContainSubstring, "DuplicateEventStorePath")
+					So(cfgStr, ShouldContainSubstring, "DuplicateEventTTL")
 					So(cfgStr, ShouldContainSubstring, "EncryptionDisabled")
 					So(cfgStr, ShouldContainSubstring, "GracefulShutdownTimeout")
+					So(cfgStr, ShouldContainSubstring, "HandlerMaxRetries")
+					So(cfgStr, ShouldContainSubstring, "HandlerRetryBackoff")
 					So(cfgStr, ShouldContainSubstring, "InputFileAvailableGroup")
 					So(cfgStr, ShouldContainSubstring, "InputFileAvailableTopic")
 
@@ -97,8 +151,18 @@ func TestString(t *testing.T) {
 					So(cfgStr, ShouldContainSubstring, "SecCACerts")
 					So(cfgStr, ShouldContainSubstring, "SecClientCert")
 					So(cfgStr, ShouldContainSubstring, "SecSkipVerify")
+					So(cfgStr, ShouldContainSubstring, "DeliveryTimeout")
+					So(cfgStr, ShouldContainSubstring, "ProducerMaxRetries")
+					So(cfgStr, ShouldContainSubstring, "ProducerRetryBackoff")
 
+					So(cfgStr, ShouldContainSubstring, "MarkInstanceFailed")
 					So(cfgStr, ShouldContainSubstring, "MaxRetries")
+					So(cfgStr, ShouldContainSubstring, "MessageEncoding")
+					So(cfgStr, ShouldContainSubstring, "OTelEnabled")
+					So(cfgStr, ShouldContainSubstring, "OTelExporter")
+					So(cfgStr, ShouldContainSubstring, "OTelExporterOtlpEndpoint")
+					So(cfgStr, ShouldContainSubstring, "OTelServiceName")
+					So(cfgStr, ShouldContainSubstring, "SchemaRegistryURL")
 					So(cfgStr, ShouldContainSubstring, "VaultAddr")
 					So(cfgStr, ShouldContainSubstring, "VaultPath")
 					So(cfgStr, ShouldContainSubstring, "ZebedeeURL")
@@ -109,3 +173,21 @@ func TestString(t *testing.T) {
 		})
 	})
 }
+
+func TestInitialOffsetTime(t *testing.T) {
+	Convey("Given the oldest or newest initial offset, no time is returned", t, func() {
+		for _, offset := range []string{KafkaInitialOffsetOldest, KafkaInitialOffsetNewest} {
+			initialTime, isTimestamp, err := KafkaConfig{InitialOffset: offset}.InitialOffsetTime()
+			So(err, ShouldBeNil)
+			So(isTimestamp, ShouldBeFalse)
+			So(initialTime.IsZero(), ShouldBeTrue)
+		}
+	})
+
+	Convey("Given a timestamp initial offset, its time is returned", t, func() {
+		initialTime, isTimestamp, err := KafkaConfig{InitialOffset: "2024-01-31T09:00:00Z"}.InitialOffsetTime()
+		So(err, ShouldBeNil)
+		So(isTimestamp, ShouldBeTrue)
+		So(initialTime, ShouldEqual, time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC))
+	})
+}
@@ -1,5 +1,7 @@
 package config
 
+import "mime"
+
 func (kafkaConfig KafkaConfig) validateKafkaValues() []string {
 	errs := []string{}
 
@@ -19,6 +21,10 @@ func (kafkaConfig KafkaConfig) validateKafkaValues() []string {
 		errs = append(errs, "KAFKA_SEC_PROTO has invalid value")
 	}
 
+	if _, _, err := kafkaConfig.InitialOffsetTime(); err != nil {
+		errs = append(errs, "KAFKA_CONSUMER_INITIAL_OFFSET must be oldest, newest or an RFC 3339 timestamp")
+	}
+
 	isKafkaClientCertSet := len(kafkaConfig.SecClientCert) != 0
 	isKafkaClientKeySet := len(kafkaConfig.SecClientKey) != 0
 	if isKafkaClientKeySet && !isKafkaClientCertSet {
@@ -30,3 +36,125 @@ func (kafkaConfig KafkaConfig) validateKafkaValues() []string {
 
 	return errs
 }
+
+func (config Config) validateOTelValues() []string {
+	errs := []string{}
+
+	if !config.OTelEnabled {
+		return errs
+	}
+
+	if config.OTelExporter != OTelExporterOTLP && config.OTelExporter != OTelExporterStdout {
+		errs = append(errs, "OTEL_TRACES_EXPORTER has invalid value")
+	}
+
+	if config.OTelExporter == OTelExporterOTLP && len(config.OTelExporterOtlpEndpoint) == 0 {
+		errs = append(errs, "no OTEL_EXPORTER_OTLP_ENDPOINT given")
+	}
+
+	return errs
+}
+
+func (config Config) validateDuplicateEventValues() []string {
+	errs := []string{}
+
+	switch config.DuplicateEventStore {
+	case DuplicateEventStoreNone:
+		return errs
+	case DuplicateEventStoreMemory:
+		if config.DuplicateEventCacheSize <= 0 {
+			errs = append(errs, "DUPLICATE_EVENT_CACHE_SIZE must be greater than 0")
+		}
+	case DuplicateEventStoreBolt:
+		if len(config.DuplicateEventStorePath) == 0 {
+			errs = append(errs, "no DUPLICATE_EVENT_STORE_PATH given")
+		}
+	default:
+		errs = append(errs, "DUPLICATE_EVENT_STORE has invalid value")
+	}
+
+	if config.DuplicateEventTTL <= 0 {
+		errs = append(errs, "DUPLICATE_EVENT_TTL must be greater than 0")
+	}
+
+	return errs
+}
+
+func (config Config) validateMessageEncodingValues() []string {
+	errs := []string{}
+
+	if config.MessageEncoding != MessageEncodingAvro && config.MessageEncoding != MessageEncodingJSON {
+		errs = append(errs, "MESSAGE_ENCODING has invalid value")
+	}
+
+	return errs
+}
+
+func (config Config) validateFileSourceValues() []string {
+	errs := []string{}
+
+	if len(config.FileSources) == 0 {
+		errs = append(errs, "FILE_SOURCES must not be empty")
+	}
+
+	for _, source := range config.FileSources {
+		if source != FileSourceS3 && source != FileSourceFile && source != FileSourceHTTPS {
+			errs = append(errs, "FILE_SOURCES has invalid value "+source)
+		}
+		if source == FileSourceFile && config.FileSourceDir == "" {
+			errs = append(errs, "FILE_SOURCE_DIR must be set when FILE_SOURCES includes "+FileSourceFile)
+		}
+	}
+
+	if config.FileSourceHTTPSTimeout <= 0 {
+		errs = append(errs, "FILE_SOURCE_HTTPS_TIMEOUT must be greater than 0")
+	}
+
+	if config.S3ReadMaxRetries < 0 {
+		errs = append(errs, "S3_READ_MAX_RETRIES must not be negative")
+	}
+
+	if config.S3DownloadConcurrency < 0 {
+		errs = append(errs, "S3_DOWNLOAD_CONCURRENCY must not be negative")
+	}
+
+	if config.S3DownloadPartSize <= 0 {
+		errs = append(errs, "S3_DOWNLOAD_PART_SIZE must be greater than 0")
+	}
+
+	return errs
+}
+
+func (config Config) validateInputValues() []string {
+	errs := []string{}
+
+	if config.InputMaxSize < 0 {
+		errs = append(errs, "INPUT_MAX_SIZE must not be negative")
+	}
+
+	for _, contentType := range config.InputAllowedContentTypes {
+		if _, _, err := mime.ParseMediaType(contentType); err != nil {
+			errs = append(errs, "INPUT_ALLOWED_CONTENT_TYPES has invalid value "+contentType)
+		}
+	}
+
+	return errs
+}
+
+func (config Config) validateValidateValues() []string {
+	errs := []string{}
+
+	if config.ValidateMaxConcurrent <= 0 {
+		errs = append(errs, "VALIDATE_MAX_CONCURRENT must be greater than 0")
+	}
+
+	if config.ValidateMaxUploadSize <= 0 {
+		errs = append(errs, "VALIDATE_MAX_UPLOAD_SIZE must be greater than 0")
+	}
+
+	if config.ValidateTimeout <= 0 {
+		errs = append(errs, "VALIDATE_TIMEOUT must be greater than 0")
+	}
+
+	return errs
+}
@@ -75,6 +75,19 @@ func TestValidateKafkaValues(t *testing.T) {
 		})
 	})
 
+	Convey("Given an invalid KAFKA_CONSUMER_INITIAL_OFFSET", t, func() {
+		cfg = getDefaultConfig()
+		cfg.KafkaConfig.InitialOffset = "yesterday"
+
+		Convey("When validateKafkaValues is called", func() {
+			errs := cfg.KafkaConfig.validateKafkaValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"KAFKA_CONSUMER_INITIAL_OFFSET must be oldest, newest or an RFC 3339 timestamp"})
+			})
+		})
+	})
+
 	Convey("Given an empty KAFKA_SEC_CLIENT_CERT", t, func() {
 		cfg = getDefaultConfig()
 		cfg.KafkaConfig.SecClientCert = ""
@@ -126,3 +139,332 @@ func TestValidateKafkaValues(t *testing.T) {
 		})
 	})
 }
+
+func TestValidateOTelValues(t *testing.T) {
+	Convey("Given tracing is disabled with an invalid exporter", t, func() {
+		cfg = getDefaultConfig()
+		cfg.OTelExporter = "invalid"
+
+		Convey("When validateOTelValues is called", func() {
+			errs := cfg.validateOTelValues()
+
+			Convey("Then no error messages should be returned", func() {
+				So(errs, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given tracing is enabled with a valid configuration", t, func() {
+		cfg = getDefaultConfig()
+		cfg.OTelEnabled = true
+
+		Convey("When validateOTelValues is called", func() {
+			errs := cfg.validateOTelValues()
+
+			Convey("Then no error messages should be returned", func() {
+				So(errs, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given tracing is enabled with the otlp exporter and an empty endpoint", t, func() {
+		cfg = getDefaultConfig()
+		cfg.OTelEnabled = true
+		cfg.OTelExporterOtlpEndpoint = ""
+
+		Convey("When validateOTelValues is called", func() {
+			errs := cfg.validateOTelValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"no OTEL_EXPORTER_OTLP_ENDPOINT given"})
+			})
+		})
+	})
+
+	Convey("Given tracing is enabled with an invalid exporter", t, func() {
+		cfg = getDefaultConfig()
+		cfg.OTelEnabled = true
+		cfg.OTelExporter = "invalid"
+
+		Convey("When validateOTelValues is called", func() {
+			errs := cfg.validateOTelValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"OTEL_TRACES_EXPORTER has invalid value"})
+			})
+		})
+	})
+}
+
+func TestValidateDuplicateEventValues(t *testing.T) {
+	Convey("Given the default duplicate event configuration", t, func() {
+		cfg = getDefaultConfig()
+
+		Convey("When validateDuplicateEventValues is called", func() {
+			errs := cfg.validateDuplicateEventValues()
+
+			Convey("Then no error messages should be returned", func() {
+				So(errs, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given duplicate event suppression is disabled with an invalid TTL", t, func() {
+		cfg = getDefaultConfig()
+		cfg.DuplicateEventStore = "none"
+		cfg.DuplicateEventTTL = 0
+
+		Convey("When validateDuplicateEventValues is called", func() {
+			errs := cfg.validateDuplicateEventValues()
+
+			Convey("Then no error messages should be returned", func() {
+				So(errs, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given an in-memory duplicate event store with an invalid size and TTL", t, func() {
+		cfg = getDefaultConfig()
+		cfg.DuplicateEventCacheSize = 0
+		cfg.DuplicateEventTTL = 0
+
+		Convey("When validateDuplicateEventValues is called", func() {
+			errs := cfg.validateDuplicateEventValues()
+
+			Convey("Then error messages should be returned", func() {
+				So(errs, ShouldResemble, []string{"DUPLICATE_EVENT_CACHE_SIZE must be greater than 0", "DUPLICATE_EVENT_TTL must be greater than 0"})
+			})
+		})
+	})
+
+	Convey("Given a bolt duplicate event store without a path", t, func() {
+		cfg = getDefaultConfig()
+		cfg.DuplicateEventStore = "bolt"
+		cfg.DuplicateEventStorePath = ""
+
+		Convey("When validateDuplicateEventValues is called", func() {
+			errs := cfg.validateDuplicateEventValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"no DUPLICATE_EVENT_STORE_PATH given"})
+			})
+		})
+	})
+
+	Convey("Given an invalid duplicate event store", t, func() {
+		cfg = getDefaultConfig()
+		cfg.DuplicateEventStore = "redis"
+
+		Convey("When validateDuplicateEventValues is called", func() {
+			errs := cfg.validateDuplicateEventValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"DUPLICATE_EVENT_STORE has invalid value"})
+			})
+		})
+	})
+}
+
+func TestValidateMessageEncodingValues(t *testing.T) {
+	Convey("Given a JSON message encoding", t, func() {
+		cfg = getDefaultConfig()
+		cfg.MessageEncoding = MessageEncodingJSON
+
+		Convey("When validateMessageEncodingValues is called", func() {
+			errs := cfg.validateMessageEncodingValues()
+
+			Convey("Then no error messages should be returned", func() {
+				So(errs, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given an unknown message encoding", t, func() {
+		cfg = getDefaultConfig()
+		cfg.MessageEncoding = "protobuf"
+
+		Convey("When validateMessageEncodingValues is called", func() {
+			errs := cfg.validateMessageEncodingValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"MESSAGE_ENCODING has invalid value"})
+			})
+		})
+	})
+}
+
+func TestValidateFileSourceValues(t *testing.T) {
+	Convey("Given all the file sources", t, func() {
+		cfg = getDefaultConfig()
+		cfg.FileSources = []string{FileSourceS3, FileSourceFile, FileSourceHTTPS}
+		cfg.FileSourceDir = "/data/imports"
+
+		Convey("When validateFileSourceValues is called", func() {
+			errs := cfg.validateFileSourceValues()
+
+			Convey("Then no error messages should be returned", func() {
+				So(errs, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given no file sources", t, func() {
+		cfg = getDefaultConfig()
+		cfg.FileSources = nil
+
+		Convey("When validateFileSourceValues is called", func() {
+			errs := cfg.validateFileSourceValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"FILE_SOURCES must not be empty"})
+			})
+		})
+	})
+
+	Convey("Given an unknown file source", t, func() {
+		cfg = getDefaultConfig()
+		cfg.FileSources = []string{FileSourceS3, "ftp"}
+
+		Convey("When validateFileSourceValues is called", func() {
+			errs := cfg.validateFileSourceValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"FILE_SOURCES has invalid value ftp"})
+			})
+		})
+	})
+
+	Convey("Given the file source without a directory", t, func() {
+		cfg = getDefaultConfig()
+		cfg.FileSources = []string{FileSourceS3, FileSourceFile}
+
+		Convey("When validateFileSourceValues is called", func() {
+			errs := cfg.validateFileSourceValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"FILE_SOURCE_DIR must be set when FILE_SOURCES includes file"})
+			})
+		})
+	})
+
+	Convey("Given the file source with a directory", t, func() {
+		cfg = getDefaultConfig()
+		cfg.FileSources = []string{FileSourceFile}
+		cfg.FileSourceDir = "/data/imports"
+
+		Convey("When validateFileSourceValues is called", func() {
+			errs := cfg.validateFileSourceValues()
+
+			Convey("Then no error messages should be returned", func() {
+				So(errs, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given a zero https file source timeout", t, func() {
+		cfg = getDefaultConfig()
+		cfg.FileSourceHTTPSTimeout = 0
+
+		Convey("When validateFileSourceValues is called", func() {
+			errs := cfg.validateFileSourceValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"FILE_SOURCE_HTTPS_TIMEOUT must be greater than 0"})
+			})
+		})
+	})
+
+	Convey("Given a negative number of S3 read retries", t, func() {
+		cfg = getDefaultConfig()
+		cfg.S3ReadMaxRetries = -1
+
+		Convey("When validateFileSourceValues is called", func() {
+			errs := cfg.validateFileSourceValues()
+
+			Convey("Then an error message should be returned", func() {
+				So(errs, ShouldResemble, []string{"S3_READ_MAX_RETRIES must not be negative"})
+			})
+		})
+	})
+
+	Convey("Given a negative download concurrency and no download part size", t, func() {
+		cfg = getDefaultConfig()
+		cfg.S3DownloadConcurrency = -1
+		cfg.S3DownloadPartSize = 0
+
+		Convey("When validateFileSourceValues is called", func() {
+			errs := cfg.validateFileSourceValues()
+
+			Convey("Then an error message should be returned for each of them", func() {
+				So(errs, ShouldResemble, []string{
+					"S3_DOWNLOAD_CONCURRENCY must not be negative",
+					"S3_DOWNLOAD_PART_SIZE must be greater than 0",
+				})
+			})
+		})
+	})
+}
+
+func TestValidateInputValues(t *testing.T) {
+	Convey("Given checks of the input files", t, func() {
+		cfg = getDefaultConfig()
+		cfg.InputMaxSize = 1 << 30
+		cfg.InputAllowedContentTypes = []string{"text/csv", "application/octet-stream"}
+
+		Convey("When validateInputValues is called", func() {
+			errs := cfg.validateInputValues()
+
+			Convey("Then no error messages should be returned", func() {
+				So(errs, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given a negative maximum size and an invalid content type", t, func() {
+		cfg = getDefaultConfig()
+		cfg.InputMaxSize = -1
+		cfg.InputAllowedContentTypes = []string{"text/csv", "csv/"}
+
+		Convey("When validateInputValues is called", func() {
+			errs := cfg.validateInputValues()
+
+			Convey("Then an error message should be returned for each of them", func() {
+				So(errs, ShouldResemble, []string{
+					"INPUT_MAX_SIZE must not be negative",
+					"INPUT_ALLOWED_CONTENT_TYPES has invalid value csv/",
+				})
+			})
+		})
+	})
+}
+
+func TestValidateValidateValues(t *testing.T) {
+	Convey("Given the default limits of the validation of uploaded files", t, func() {
+		cfg = getDefaultConfig()
+
+		Convey("When validateValidateValues is called", func() {
+			errs := cfg.validateValidateValues()
+
+			Convey("Then no error messages should be returned", func() {
+				So(errs, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given no concurrent validation and no upload size", t, func() {
+		cfg = getDefaultConfig()
+		cfg.ValidateMaxConcurrent = 0
+		cfg.ValidateMaxUploadSize = 0
+
+		Convey("When validateValidateValues is called", func() {
+			errs := cfg.validateValidateValues()
+
+			Convey("Then an error message should be returned for each of them", func() {
+				So(errs, ShouldResemble, []string{
+					"VALIDATE_MAX_CONCURRENT must be greater than 0",
+					"VALIDATE_MAX_UPLOAD_SIZE must be greater than 0",
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,50 @@
+package consumer
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-extractor/kafkatls"
+	kafka "github.com/ONSdigital/dp-kafka/v2"
+	"github.com/Shopify/sarama"
+)
+
+// ErrInvalidOffset is returned when the initial offset is neither the oldest nor the newest
+var ErrInvalidOffset = errors.New("offset value incorrect")
+
+// getConfig creates a sarama config for a consumer group, overwriting any values provided in cgConfig,
+// with the same defaults as dp-kafka consumer groups
+func getConfig(cgConfig *kafka.ConsumerGroupConfig) (config *sarama.Config, err error) {
+	config = sarama.NewConfig()
+	config.Consumer.MaxWaitTime = 50 * time.Millisecond
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	config.Consumer.Return.Errors = true
+	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
+	config.Consumer.Group.Session.Timeout = 10 * time.Second
+	if cgConfig != nil {
+		if cgConfig.KafkaVersion != nil {
+			if config.Version, err = sarama.ParseKafkaVersion(*cgConfig.KafkaVersion); err != nil {
+				return nil, err
+			}
+		}
+		if cgConfig.KeepAlive != nil {
+			config.Net.KeepAlive = *cgConfig.KeepAlive
+		}
+		if cgConfig.RetryBackoff != nil {
+			config.Consumer.Retry.Backoff = *cgConfig.RetryBackoff
+		}
+		if cgConfig.RetryBackoffFunc != nil {
+			config.Consumer.Retry.BackoffFunc = *cgConfig.RetryBackoffFunc
+		}
+		if cgConfig.Offset != nil {
+			if *cgConfig.Offset != sarama.OffsetNewest && *cgConfig.Offset != sarama.OffsetOldest {
+				return nil, ErrInvalidOffset
+			}
+			config.Consumer.Offsets.Initial = *cgConfig.Offset
+		}
+		if err = kafkatls.Add(cgConfig.SecurityConfig, config); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
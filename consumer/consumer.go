@@ -0,0 +1,343 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	kafka "github.com/ONSdigital/dp-kafka/v2"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/Shopify/sarama"
+)
+
+// Periods of time to wait before trying to connect to kafka, or to join the consumer group, again.
+// They are doubled after each failed attempt, up to maxRetryPeriod.
+var (
+	initRetryPeriod    = time.Second
+	consumeRetryPeriod = time.Second
+	maxRetryPeriod     = 30 * time.Second
+)
+
+// Consumer consumes the messages of a single kafka topic as a member of a consumer group, and sends them one at a time
+// to its Upstream channel, waiting for each of them to be released before sending the next one. Each message carries
+// the context of the consumer group session it was consumed in, which is done when its partition is revoked by a rebalance.
+type Consumer struct {
+	brokerAddrs   []string
+	topic         string
+	group         string
+	config        *sarama.Config
+	channels      *kafka.ConsumerGroupChannels
+	client        sarama.Client
+	consumerGroup sarama.ConsumerGroup
+	handler       *handler
+	initialTime   time.Time
+	mutex         *sync.Mutex
+	wgClose       *sync.WaitGroup
+}
+
+// New returns a new Consumer for the provided topic and group. If kafka is not reachable, the consumer
+// is returned uninitialised and connection attempts are made in the background until it is closed.
+// If initialTime is not zero, partitions without a committed offset are consumed from the first message produced
+// at or after it, or from the newest offset if there is none, instead of the initial offset of cgConfig.
+func New(ctx context.Context, brokerAddrs []string, topic, group string, cgConfig *kafka.ConsumerGroupConfig, initialTime time.Time) (*Consumer, error) {
+	config, err := getConfig(cgConfig)
+	if err != nil {
+		return nil, err
+	}
+	if !initialTime.IsZero() {
+		config.Consumer.Offsets.Initial = sarama.OffsetNewest
+	}
+
+	// the upstream channel is not buffered, so that every message sent to it is either handled or released
+	channels := kafka.CreateConsumerGroupChannels(0)
+	c := &Consumer{
+		brokerAddrs: brokerAddrs,
+		topic:       topic,
+		group:       group,
+		config:      config,
+		channels:    channels,
+		handler:     &handler{channels: channels, inFlight: &sync.RWMutex{}},
+		initialTime: initialTime,
+		mutex:       &sync.Mutex{},
+		wgClose:     &sync.WaitGroup{},
+	}
+	if !initialTime.IsZero() {
+		c.handler.setup = c.markInitialOffsets
+	}
+
+	if err := c.Initialise(ctx); err != nil {
+		log.Warn(ctx, "kafka consumer group could not be initialised, will retry", log.FormatErrors([]error{err}), log.Data{"topic": topic, "group": group})
+		c.initialiseLoop(ctx)
+	}
+
+	return c, nil
+}
+
+// Channels returns the channels of the consumer
+func (c *Consumer) Channels() *kafka.ConsumerGroupChannels {
+	if c == nil {
+		return nil
+	}
+	return c.channels
+}
+
+// IsInitialised returns true only if a connection to kafka has been established
+func (c *Consumer) IsInitialised() bool {
+	if c == nil {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.consumerGroup != nil
+}
+
+// Initialise connects to kafka and starts consuming, only if the consumer was not already initialised
+func (c *Consumer) Initialise(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.consumerGroup != nil {
+		return nil
+	}
+
+	client, err := sarama.NewClient(c.brokerAddrs, c.config)
+	if err != nil {
+		return err
+	}
+	consumerGroup, err := sarama.NewConsumerGroupFromClient(c.group, client)
+	if err != nil {
+		client.Close()
+		return err
+	}
+
+	c.client = client
+	c.consumerGroup = consumerGroup
+	c.consumeLoop(ctx)
+	c.errorLoop(ctx)
+	log.Info(ctx, "initialised sarama consumer group", log.Data{"topic": c.topic, "group": c.group})
+	return nil
+}
+
+// initialiseLoop tries to initialise the consumer again, until it is initialised or closed
+func (c *Consumer) initialiseLoop(ctx context.Context) {
+	c.wgClose.Add(1)
+	go func() {
+		defer c.wgClose.Done()
+		for attempt := 1; ; attempt++ {
+			select {
+			case <-time.After(retryPeriod(initRetryPeriod, attempt)):
+				if err := c.Initialise(ctx); err != nil {
+					log.Warn(ctx, "failed to initialise kafka consumer group", log.FormatErrors([]error{err}), log.Data{"topic": c.topic, "attempt": attempt})
+					continue
+				}
+				return
+			case <-c.channels.Closer:
+				log.Info(ctx, "closing uninitialised kafka consumer group", log.Data{"topic": c.topic})
+				return
+			}
+		}
+	}()
+}
+
+// consumeLoop joins the consumer group, and joins it again each time its session ends, e.g. after a rebalance,
+// until the consumer stops listening. The session is only left once the message being handled, if any, has been released.
+func (c *Consumer) consumeLoop(ctx context.Context) {
+	consumeContext, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-c.channels.Closer
+		c.handler.waitInFlight()
+		cancel()
+	}()
+
+	c.wgClose.Add(1)
+	go func() {
+		defer c.wgClose.Done()
+		defer cancel()
+		logData := log.Data{"topic": c.topic, "group": c.group}
+		log.Info(ctx, "started kafka consumer listener loop", logData)
+		for attempt := 1; ; {
+			err := c.consumerGroup.Consume(consumeContext, []string{c.topic}, c.handler)
+			if consumeContext.Err() != nil || errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				log.Info(ctx, "closed kafka consumer listener loop", logData)
+				return
+			}
+			if err == nil {
+				attempt = 1
+				continue
+			}
+
+			log.Warn(ctx, "failed to consume from kafka, will retry", log.FormatErrors([]error{err}), log.Data{"topic": c.topic, "attempt": attempt})
+			select {
+			case <-time.After(retryPeriod(consumeRetryPeriod, attempt)):
+				attempt++
+			case <-consumeContext.Done():
+				log.Info(ctx, "closed kafka consumer listener loop", logData)
+				return
+			}
+		}
+	}()
+}
+
+// errorLoop sends the errors of the consumer group to the Errors channel, until the consumer stops listening
+func (c *Consumer) errorLoop(ctx context.Context) {
+	c.wgClose.Add(1)
+	go func() {
+		defer c.wgClose.Done()
+		for {
+			select {
+			case err, ok := <-c.consumerGroup.Errors():
+				if !ok {
+					return
+				}
+				select {
+				case c.channels.Errors <- err:
+				case <-c.channels.Closer:
+					return
+				}
+			case <-c.channels.Closer:
+				return
+			}
+		}
+	}()
+}
+
+// StopListeningToConsumer stops consuming new messages, and waits for the message being handled, if any, to be released.
+// If the context is done before that, its error is returned.
+func (c *Consumer) StopListeningToConsumer(ctx context.Context) error {
+	select {
+	case <-c.channels.Closer:
+	default:
+		close(c.channels.Closer)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		c.wgClose.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		log.Warn(ctx, "StopListeningToConsumer abandoned: context done", log.FormatErrors([]error{ctx.Err()}), log.Data{"topic": c.topic, "group": c.group})
+		return ctx.Err()
+	}
+}
+
+// Close stops consuming new messages and closes the consumer group and its connection to kafka
+func (c *Consumer) Close(ctx context.Context) error {
+	if err := c.StopListeningToConsumer(ctx); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.consumerGroup != nil {
+		if err := c.consumerGroup.Close(); err != nil {
+			log.Error(ctx, "close failed of kafka consumer group", err, log.Data{"topic": c.topic, "group": c.group})
+			return err
+		}
+		if !c.client.Closed() {
+			c.client.Close()
+		}
+	}
+
+	select {
+	case <-c.channels.Closed:
+	default:
+		close(c.channels.Closed)
+	}
+	log.Info(ctx, "successfully closed kafka consumer group", log.Data{"topic": c.topic, "group": c.group})
+	return nil
+}
+
+// handler sends the messages of each claim of a consumer group session to the Upstream channel
+type handler struct {
+	channels *kafka.ConsumerGroupChannels
+	inFlight *sync.RWMutex
+	setup    func(session sarama.ConsumerGroupSession) error
+}
+
+var _ sarama.ConsumerGroupHandler = (*handler)(nil)
+
+// Setup is run at the beginning of a new session, before ConsumeClaim
+func (h *handler) Setup(session sarama.ConsumerGroupSession) error {
+	log.Info(session.Context(), "kafka consumer group session started", log.Data{"member_id": session.MemberID(), "claims": session.Claims()})
+	if h.setup != nil {
+		if err := h.setup(session); err != nil {
+			log.Error(session.Context(), "kafka consumer group session setup failed", err, log.Data{"member_id": session.MemberID()})
+			return err
+		}
+	}
+	select {
+	case <-h.channels.Ready:
+	default:
+		close(h.channels.Ready)
+	}
+	return nil
+}
+
+// Cleanup is run at the end of a session, once every ConsumeClaim has returned
+func (h *handler) Cleanup(session sarama.ConsumerGroupSession) error {
+	log.Info(session.Context(), "kafka consumer group session ended, its partitions have been revoked", log.Data{"member_id": session.MemberID(), "claims": session.Claims()})
+	return nil
+}
+
+// ConsumeClaim sends the messages of the claim to the Upstream channel until the session ends or the consumer stops listening.
+// The first ConsumeClaim to return ends the session, so when the consumer stops listening,
+// it only returns once the message being handled, if any, has been released.
+func (h *handler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if !h.deliver(session, message) {
+				return nil
+			}
+		case <-session.Context().Done():
+			return nil
+		case <-h.channels.Closer:
+			h.waitInFlight()
+			return nil
+		}
+	}
+}
+
+// deliver sends the message to the Upstream channel, and waits for it to be released. When the session ends in the meantime,
+// the message is still waited for, so that the session is only left once it has been aborted.
+// It returns false if the message could not be sent.
+func (h *handler) deliver(session sarama.ConsumerGroupSession, message *sarama.ConsumerMessage) bool {
+	h.inFlight.RLock()
+	defer h.inFlight.RUnlock()
+
+	m := newMessage(message, session)
+	select {
+	case h.channels.Upstream <- m:
+		<-m.UpstreamDone()
+		return true
+	case <-session.Context().Done():
+		return false
+	case <-h.channels.Closer:
+		return false
+	}
+}
+
+// waitInFlight waits for the messages which have been sent to the Upstream channel to be released
+func (h *handler) waitInFlight() {
+	h.inFlight.Lock()
+	defer h.inFlight.Unlock()
+}
+
+// retryPeriod returns the period of time to wait before the provided attempt
+func retryPeriod(initial time.Duration, attempt int) time.Duration {
+	period := initial
+	for i := 1; i < attempt && period < maxRetryPeriod; i++ {
+		period *= 2
+	}
+	return min(period, maxRetryPeriod)
+}
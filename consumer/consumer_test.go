@@ -0,0 +1,158 @@
+package consumer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	kafka "github.com/ONSdigital/dp-kafka/v2"
+	"github.com/ONSdigital/dp-kafka/v2/mock"
+	"github.com/Shopify/sarama"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newTestSession(sessionContext context.Context) *mock.SaramaConsumerGroupSessionMock {
+	return &mock.SaramaConsumerGroupSessionMock{
+		ContextFunc:     func() context.Context { return sessionContext },
+		MarkMessageFunc: func(msg *sarama.ConsumerMessage, metadata string) {},
+		CommitFunc:      func() {},
+	}
+}
+
+func newTestClaim(messages chan *sarama.ConsumerMessage) *mock.SaramaConsumerGroupClaimMock {
+	return &mock.SaramaConsumerGroupClaimMock{
+		MessagesFunc: func() <-chan *sarama.ConsumerMessage { return messages },
+	}
+}
+
+func TestMessage(t *testing.T) {
+	Convey("Given a message consumed within a session", t, func() {
+		sessionContext, revoke := context.WithCancel(ctx)
+		defer revoke()
+		session := newTestSession(sessionContext)
+		m := newMessage(&sarama.ConsumerMessage{
+			Value:     []byte("data"),
+			Partition: 3,
+			Offset:    42,
+			Headers:   []*sarama.RecordHeader{{Key: []byte(kafka.TraceIDHeaderKey), Value: []byte("abcdef123456")}},
+		}, session)
+
+		Convey("Then its data, headers, partition and offset are returned", func() {
+			So(string(m.GetData()), ShouldEqual, "data")
+			So(m.GetHeader(kafka.TraceIDHeaderKey), ShouldEqual, "abcdef123456")
+			So(m.GetHeader("content-type"), ShouldEqual, "")
+			So(m.Partition(), ShouldEqual, 3)
+			So(m.Offset(), ShouldEqual, 42)
+		})
+
+		Convey("When it is committed and released, it is marked and committed in its session, and released once", func() {
+			m.CommitAndRelease()
+			m.Release()
+
+			So(len(session.MarkMessageCalls()), ShouldEqual, 1)
+			So(len(session.CommitCalls()), ShouldEqual, 1)
+			_, open := <-m.UpstreamDone()
+			So(open, ShouldBeFalse)
+		})
+
+		Convey("When its partition is revoked, its session context is done", func() {
+			So(m.SessionContext().Err(), ShouldBeNil)
+			revoke()
+			So(m.SessionContext().Err(), ShouldEqual, context.Canceled)
+		})
+	})
+}
+
+func TestConsumeClaim(t *testing.T) {
+	Convey("Given a handler consuming a claim", t, func() {
+		channels := kafka.CreateConsumerGroupChannels(0)
+		h := &handler{channels: channels, inFlight: &sync.RWMutex{}}
+		sessionContext, revoke := context.WithCancel(ctx)
+		defer revoke()
+		messages := make(chan *sarama.ConsumerMessage, 2)
+		returned := make(chan error, 1)
+		go func() {
+			returned <- h.ConsumeClaim(newTestSession(sessionContext), newTestClaim(messages))
+		}()
+
+		Convey("When a message is consumed, it is sent to the upstream channel and the next one is only sent once it is released", func() {
+			messages <- &sarama.ConsumerMessage{Offset: 1}
+			messages <- &sarama.ConsumerMessage{Offset: 2}
+
+			first := receive(t, channels.Upstream)
+			So(first.Offset(), ShouldEqual, 1)
+			select {
+			case <-channels.Upstream:
+				t.Fatal("the next message was sent before the first one was released")
+			case <-time.After(10 * time.Millisecond):
+			}
+
+			first.Release()
+			So(receive(t, channels.Upstream).Offset(), ShouldEqual, 2)
+		})
+
+		Convey("When the partition is revoked while a message is being handled", func() {
+			messages <- &sarama.ConsumerMessage{Offset: 1}
+			m := receive(t, channels.Upstream)
+			revoke()
+
+			Convey("Then the message carries the end of the session, and the claim is only left once the message is released", func() {
+				So(m.(*Message).SessionContext().Err(), ShouldEqual, context.Canceled)
+				select {
+				case <-returned:
+					t.Fatal("the claim was left before the message was released")
+				case <-time.After(10 * time.Millisecond):
+				}
+
+				m.Release()
+				So(waitReturned(t, returned), ShouldBeNil)
+			})
+		})
+
+		Convey("When the consumer stops listening while a message is being handled", func() {
+			messages <- &sarama.ConsumerMessage{Offset: 1}
+			m := receive(t, channels.Upstream)
+			close(channels.Closer)
+
+			Convey("Then the claim is only left once the message is released", func() {
+				select {
+				case <-returned:
+					t.Fatal("the claim was left before the message was released")
+				case <-time.After(10 * time.Millisecond):
+				}
+
+				m.Release()
+				So(waitReturned(t, returned), ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestRetryPeriod(t *testing.T) {
+	Convey("The retry period is doubled after each attempt, up to the maximum period", t, func() {
+		So(retryPeriod(time.Second, 1), ShouldEqual, time.Second)
+		So(retryPeriod(time.Second, 3), ShouldEqual, 4*time.Second)
+		So(retryPeriod(time.Second, 10), ShouldEqual, maxRetryPeriod)
+	})
+}
+
+func receive(t *testing.T, upstream chan kafka.Message) kafka.Message {
+	select {
+	case m := <-upstream:
+		return m
+	case <-time.After(time.Second):
+		t.Fatal("no message was sent to the upstream channel")
+		return nil
+	}
+}
+
+func waitReturned(t *testing.T, returned chan error) error {
+	select {
+	case err := <-returned:
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("ConsumeClaim did not return")
+		return nil
+	}
+}
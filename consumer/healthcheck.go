@@ -0,0 +1,31 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+const (
+	// MsgHealthyConsumer is the check message returned when the consumer is healthy
+	MsgHealthyConsumer = "kafka consumer is healthy"
+
+	// MsgUninitialisedConsumer is the check message returned when the consumer has not connected to kafka yet
+	MsgUninitialisedConsumer = "kafka consumer is not initialised"
+)
+
+// Checker checks that the topic metadata can be obtained from kafka and updates the provided CheckState accordingly
+func (c *Consumer) Checker(ctx context.Context, state *healthcheck.CheckState) error {
+	if !c.IsInitialised() {
+		return state.Update(healthcheck.StatusWarning, MsgUninitialisedConsumer, 0)
+	}
+
+	if err := c.client.RefreshMetadata(c.topic); err != nil {
+		log.Warn(ctx, "failed to obtain metadata from kafka", log.FormatErrors([]error{err}), log.Data{"topic": c.topic})
+		return state.Update(healthcheck.StatusCritical, fmt.Sprintf("failed to obtain metadata for topic %s: %s", c.topic, err), 0)
+	}
+
+	return state.Update(healthcheck.StatusOK, MsgHealthyConsumer, 0)
+}
@@ -0,0 +1,97 @@
+package consumer
+
+import (
+	"context"
+	"sync"
+
+	kafka "github.com/ONSdigital/dp-kafka/v2"
+	"github.com/Shopify/sarama"
+)
+
+// Message is a kafka message consumed within a consumer group session. It implements kafka.Message,
+// and also exposes the partition it was consumed from and the context of its session.
+type Message struct {
+	message      *sarama.ConsumerMessage
+	session      sarama.ConsumerGroupSession
+	upstreamDone chan struct{}
+	releaseOnce  *sync.Once
+}
+
+var _ kafka.Message = (*Message)(nil)
+
+func newMessage(message *sarama.ConsumerMessage, session sarama.ConsumerGroupSession) *Message {
+	return &Message{
+		message:      message,
+		session:      session,
+		upstreamDone: make(chan struct{}),
+		releaseOnce:  &sync.Once{},
+	}
+}
+
+// GetData returns the message contents
+func (m *Message) GetData() []byte {
+	return m.message.Value
+}
+
+// GetHeader returns the value of the header with the provided key, or an empty string if the message has no such header
+func (m *Message) GetHeader(key string) string {
+	for _, header := range m.message.Headers {
+		if header != nil && string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// Context returns a context with the trace ID header of the message, if any
+func (m *Message) Context() context.Context {
+	ctx := context.Background()
+	if traceID := m.GetHeader(kafka.TraceIDHeaderKey); traceID != "" {
+		ctx = context.WithValue(ctx, kafka.TraceIDHeaderKey, traceID)
+	}
+	return ctx
+}
+
+// SessionContext returns the context of the consumer group session the message was consumed in.
+// It is done when the session ends, i.e. when the partitions of the consumer are revoked by a rebalance,
+// after which the offset of the message can no longer be committed by this consumer.
+func (m *Message) SessionContext() context.Context {
+	return m.session.Context()
+}
+
+// Partition returns the partition the message was consumed from
+func (m *Message) Partition() int32 {
+	return m.message.Partition
+}
+
+// Offset returns the message offset
+func (m *Message) Offset() int64 {
+	return m.message.Offset
+}
+
+// Mark marks the message as consumed, but doesn't commit the offset to the backend
+func (m *Message) Mark() {
+	m.session.MarkMessage(m.message, "")
+}
+
+// Commit marks the message as consumed, and then commits the offset to the backend
+func (m *Message) Commit() {
+	m.session.MarkMessage(m.message, "")
+	m.session.Commit()
+}
+
+// Release closes the UpstreamDone channel, but doesn't mark the message or commit the offset
+func (m *Message) Release() {
+	m.releaseOnce.Do(func() { close(m.upstreamDone) })
+}
+
+// CommitAndRelease marks the message as consumed, commits the offset to the backend and releases the UpstreamDone channel
+func (m *Message) CommitAndRelease() {
+	m.Commit()
+	m.Release()
+}
+
+// UpstreamDone returns the upstreamDone channel. Closing this channel notifies that the message has been consumed (same effect as calling Release)
+func (m *Message) UpstreamDone() chan struct{} {
+	return m.upstreamDone
+}
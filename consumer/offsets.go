@@ -0,0 +1,204 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	kafka "github.com/ONSdigital/dp-kafka/v2"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/Shopify/sarama"
+)
+
+// Consumer group states in which its offsets can be reset, as no member is consuming
+const (
+	groupStateEmpty = "Empty"
+	groupStateDead  = "Dead"
+)
+
+// ErrActiveGroup is returned when the offsets of a consumer group which has active members are reset
+type ErrActiveGroup struct {
+	Group string
+	State string
+}
+
+func (e *ErrActiveGroup) Error() string {
+	return fmt.Sprintf("consumer group %s has active members (state %s), stop its consumers before resetting its offsets", e.Group, e.State)
+}
+
+// PartitionOffset is the offset of a partition, before and after it was reset
+type PartitionOffset struct {
+	Partition int32
+	Previous  int64
+	Offset    int64
+}
+
+// markInitialOffsets marks, for the claimed partitions which have no committed offset yet, the offset of the first message
+// produced at or after the initial time, so that the consumer group starts consuming from there.
+// Partitions without any message produced since then are consumed from the newest offset, which is the initial offset
+// of the config when an initial time is set. It is run in the setup of each session: if it fails, the session is not
+// started and the consumer group is joined again after a retry period.
+func (c *Consumer) markInitialOffsets(session sarama.ConsumerGroupSession) error {
+	// the admin is not closed, as it would close the client of the consumer
+	admin, err := sarama.NewClusterAdminFromClient(c.client)
+	if err != nil {
+		return err
+	}
+	committed, err := admin.ListConsumerGroupOffsets(c.group, session.Claims())
+	if err != nil {
+		return err
+	}
+
+	for topic, partitions := range session.Claims() {
+		for _, partition := range partitions {
+			if block := committed.GetBlock(topic, partition); block != nil && block.Offset >= 0 {
+				continue
+			}
+			offset, err := c.client.GetOffset(topic, partition, c.initialTime.UnixMilli())
+			if err != nil {
+				return err
+			}
+			if offset >= 0 {
+				session.MarkOffset(topic, partition, offset, "")
+				log.Info(session.Context(), "consuming partition without committed offset from the initial time", log.Data{
+					"topic":        topic,
+					"partition":    partition,
+					"offset":       offset,
+					"initial_time": c.initialTime.Format(time.RFC3339),
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// ResetOffsets commits, for every partition of the topic, the offset of the first message produced at or after the provided time,
+// so that the consumer group consumes again the messages produced since then, or skips those produced before.
+// Partitions without any message produced since then are reset to their newest offset.
+// The consumer group must not have any active member, otherwise ErrActiveGroup is returned.
+func ResetOffsets(ctx context.Context, brokerAddrs []string, topic, group string, cgConfig *kafka.ConsumerGroupConfig, to time.Time) ([]PartitionOffset, error) {
+	client, admin, err := newAdmin(brokerAddrs, cgConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer admin.Close()
+
+	if err := checkInactive(admin, group); err != nil {
+		return nil, err
+	}
+
+	partitions, committed, err := committedOffsets(client, admin, topic, group)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make([]PartitionOffset, 0, len(partitions))
+	for _, partition := range partitions {
+		offset, err := client.GetOffset(topic, partition, to.UnixMilli())
+		if err != nil {
+			return nil, err
+		}
+		if offset < 0 {
+			if offset, err = client.GetOffset(topic, partition, sarama.OffsetNewest); err != nil {
+				return nil, err
+			}
+		}
+
+		previous := int64(-1)
+		if block := committed.GetBlock(topic, partition); block != nil {
+			previous = block.Offset
+		}
+		offsets = append(offsets, PartitionOffset{Partition: partition, Previous: previous, Offset: offset})
+	}
+
+	if err := commitOffsets(client, admin, topic, group, offsets); err != nil {
+		return nil, err
+	}
+
+	log.Info(ctx, "reset consumer group offsets", log.Data{"topic": topic, "group": group, "to": to.Format(time.RFC3339), "offsets": offsets})
+	return offsets, nil
+}
+
+// newAdmin returns a client and a cluster admin using it. Closing the admin also closes the client.
+func newAdmin(brokerAddrs []string, cgConfig *kafka.ConsumerGroupConfig) (sarama.Client, sarama.ClusterAdmin, error) {
+	config, err := getConfig(cgConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := sarama.NewClient(brokerAddrs, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, nil, err
+	}
+	return client, admin, nil
+}
+
+// checkInactive returns ErrActiveGroup if the consumer group has active members
+func checkInactive(admin sarama.ClusterAdmin, group string) error {
+	groups, err := admin.DescribeConsumerGroups([]string{group})
+	if err != nil {
+		return err
+	}
+	for _, description := range groups {
+		if description.State != groupStateEmpty && description.State != groupStateDead {
+			return &ErrActiveGroup{Group: group, State: description.State}
+		}
+	}
+	return nil
+}
+
+// committedOffsets returns the sorted partitions of the topic, and the offsets committed by the consumer group for them
+func committedOffsets(client sarama.Client, admin sarama.ClusterAdmin, topic, group string) ([]int32, *sarama.OffsetFetchResponse, error) {
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	committed, err := admin.ListConsumerGroupOffsets(group, map[string][]int32{topic: partitions})
+	if err != nil {
+		return nil, nil, err
+	}
+	return partitions, committed, nil
+}
+
+// commitOffsets commits the offsets of the partitions for the consumer group, moving them backwards if needed
+func commitOffsets(client sarama.Client, admin sarama.ClusterAdmin, topic, group string, offsets []PartitionOffset) error {
+	offsetManager, err := sarama.NewOffsetManagerFromClient(group, client)
+	if err != nil {
+		return err
+	}
+	defer offsetManager.Close()
+
+	partitions := make([]int32, 0, len(offsets))
+	for _, offset := range offsets {
+		partitionOffsetManager, err := offsetManager.ManagePartition(topic, offset.Partition)
+		if err != nil {
+			return err
+		}
+		if offset.Offset > offset.Previous {
+			partitionOffsetManager.MarkOffset(offset.Offset, "")
+		} else {
+			partitionOffsetManager.ResetOffset(offset.Offset, "")
+		}
+		partitionOffsetManager.AsyncClose()
+		partitions = append(partitions, offset.Partition)
+	}
+
+	// the errors of the commit are only reported asynchronously, so the committed offsets are checked instead
+	offsetManager.Commit()
+	committed, err := admin.ListConsumerGroupOffsets(group, map[string][]int32{topic: partitions})
+	if err != nil {
+		return err
+	}
+	for _, offset := range offsets {
+		if block := committed.GetBlock(topic, offset.Partition); block == nil || block.Offset != offset.Offset {
+			return fmt.Errorf("failed to commit offset %d of partition %d", offset.Offset, offset.Partition)
+		}
+	}
+	return nil
+}
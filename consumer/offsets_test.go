@@ -0,0 +1,160 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kafka "github.com/ONSdigital/dp-kafka/v2"
+	"github.com/ONSdigital/dp-kafka/v2/mock"
+	"github.com/Shopify/sarama"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	testTopic = "input-file-available"
+	testGroup = "input-file-available"
+)
+
+var (
+	ctx           = context.Background()
+	testResetTime = time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)
+)
+
+// newTestBroker returns a broker leading the 2 partitions of the test topic, and coordinating the test group
+func newTestBroker(t *testing.T, group *sarama.GroupDescription, offsetFetches ...*sarama.MockOffsetFetchResponse) *sarama.MockBroker {
+	broker := sarama.NewMockBroker(t, 1)
+	fetches := make([]interface{}, 0, len(offsetFetches))
+	for _, fetch := range offsetFetches {
+		fetches = append(fetches, fetch)
+	}
+	describeGroups := sarama.NewMockDescribeGroupsResponse(t)
+	if group != nil {
+		describeGroups.AddGroupDescription(testGroup, group)
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetController(broker.BrokerID()).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader(testTopic, 0, broker.BrokerID()).
+			SetLeader(testTopic, 1, broker.BrokerID()),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, testGroup, broker),
+		"DescribeGroupsRequest": describeGroups,
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset(testTopic, 0, testResetTime.UnixMilli(), 3).
+			SetOffset(testTopic, 1, testResetTime.UnixMilli(), -1).
+			SetOffset(testTopic, 1, sarama.OffsetNewest, 10),
+		"OffsetFetchRequest":  sarama.NewMockSequence(fetches...),
+		"OffsetCommitRequest": sarama.NewMockOffsetCommitResponse(t),
+	})
+	return broker
+}
+
+func TestResetOffsets(t *testing.T) {
+	version := "1.0.2"
+	cgConfig := &kafka.ConsumerGroupConfig{KafkaVersion: &version}
+
+	Convey("Given a consumer group without active members, with a committed offset for one of the 2 partitions of its topic", t, func() {
+		previous := sarama.NewMockOffsetFetchResponse(t).
+			SetOffset(testGroup, testTopic, 0, 5, "", sarama.ErrNoError).
+			SetOffset(testGroup, testTopic, 1, -1, "", sarama.ErrNoError)
+		reset := sarama.NewMockOffsetFetchResponse(t).
+			SetOffset(testGroup, testTopic, 0, 3, "", sarama.ErrNoError).
+			SetOffset(testGroup, testTopic, 1, 10, "", sarama.ErrNoError)
+		// the offsets are fetched before the reset, for each managed partition, and after the commit
+		broker := newTestBroker(t, &sarama.GroupDescription{GroupId: testGroup, State: "Empty"}, previous, previous, previous, reset)
+		defer broker.Close()
+
+		Convey("When its offsets are reset", func() {
+			offsets, err := ResetOffsets(ctx, []string{broker.Addr()}, testTopic, testGroup, cgConfig, testResetTime)
+
+			Convey("Then each partition is reset to the offset of the reset time, or to its newest offset if it has no message since then", func() {
+				So(err, ShouldBeNil)
+				So(offsets, ShouldResemble, []PartitionOffset{
+					{Partition: 0, Previous: 5, Offset: 3},
+					{Partition: 1, Previous: -1, Offset: 10},
+				})
+			})
+		})
+	})
+
+	Convey("Given a consumer group with an active member", t, func() {
+		broker := newTestBroker(t, &sarama.GroupDescription{GroupId: testGroup, State: "Stable"})
+		defer broker.Close()
+
+		Convey("When its offsets are reset", func() {
+			offsets, err := ResetOffsets(ctx, []string{broker.Addr()}, testTopic, testGroup, cgConfig, testResetTime)
+
+			Convey("Then ErrActiveGroup is returned, and no offset is reset", func() {
+				So(err, ShouldResemble, &ErrActiveGroup{Group: testGroup, State: "Stable"})
+				So(offsets, ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given a consumer group whose offsets are not committed", t, func() {
+		previous := sarama.NewMockOffsetFetchResponse(t).
+			SetOffset(testGroup, testTopic, 0, 5, "", sarama.ErrNoError).
+			SetOffset(testGroup, testTopic, 1, -1, "", sarama.ErrNoError)
+		broker := newTestBroker(t, nil, previous)
+		defer broker.Close()
+
+		Convey("When its offsets are reset", func() {
+			_, err := ResetOffsets(ctx, []string{broker.Addr()}, testTopic, testGroup, cgConfig, testResetTime)
+
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldStartWith, "failed to commit offset")
+			})
+		})
+	})
+}
+
+func TestMarkInitialOffsets(t *testing.T) {
+	version := "1.0.2"
+	cgConfig := &kafka.ConsumerGroupConfig{KafkaVersion: &version}
+
+	Convey("Given a consumer with an initial time, whose group has no committed offset for the 2 partitions of its topic", t, func() {
+		committed := sarama.NewMockOffsetFetchResponse(t).
+			SetOffset(testGroup, testTopic, 0, -1, "", sarama.ErrNoError).
+			SetOffset(testGroup, testTopic, 1, -1, "", sarama.ErrNoError)
+		broker := newTestBroker(t, nil, committed)
+		defer broker.Close()
+
+		config, err := getConfig(cgConfig)
+		So(err, ShouldBeNil)
+		client, err := sarama.NewClient([]string{broker.Addr()}, config)
+		So(err, ShouldBeNil)
+		defer client.Close()
+		c := &Consumer{topic: testTopic, group: testGroup, client: client, initialTime: testResetTime}
+
+		Convey("When a session claiming both partitions is set up", func() {
+			var marked []PartitionOffset
+			session := &mock.SaramaConsumerGroupSessionMock{
+				ContextFunc: func() context.Context { return ctx },
+				ClaimsFunc:  func() map[string][]int32 { return map[string][]int32{testTopic: {0, 1}} },
+				MarkOffsetFunc: func(topic string, partition int32, offset int64, metadata string) {
+					marked = append(marked, PartitionOffset{Partition: partition, Previous: -1, Offset: offset})
+				},
+			}
+			err := c.markInitialOffsets(session)
+
+			Convey("Then only the partition with a message since the initial time is marked at the offset of that message", func() {
+				So(err, ShouldBeNil)
+				So(marked, ShouldResemble, []PartitionOffset{{Partition: 0, Previous: -1, Offset: 3}})
+			})
+		})
+	})
+
+	Convey("Given a consumer created with an initial time", t, func() {
+		c, err := New(ctx, nil, testTopic, testGroup, cgConfig, testResetTime)
+		So(err, ShouldBeNil)
+		defer c.Close(ctx)
+
+		Convey("Then the partitions without any message since the initial time are consumed from the newest offset", func() {
+			So(c.config.Consumer.Offsets.Initial, ShouldEqual, sarama.OffsetNewest)
+			So(c.handler.setup, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,139 @@
+// Package datasetapi extends the dataset API client of dp-api-clients-go with the calls it does not provide.
+package datasetapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	"github.com/ONSdigital/dp-api-clients-go/v2/headers"
+	"github.com/ONSdigital/dp-api-clients-go/v2/health"
+	dprequest "github.com/ONSdigital/dp-net/v2/request"
+	"github.com/ONSdigital/log.go/v2/log"
+	"golang.org/x/net/context"
+)
+
+// eventTypeError is the type of the instance events describing an import failure
+const eventTypeError = "error"
+
+// ImportFailure describes why the import of an instance failed
+type ImportFailure struct {
+	ErrorCode string
+	Message   string
+	Rows      []int
+}
+
+// InstanceEvent represents an event of an instance, as accepted by the dataset API.
+// The model has no field for the error code and rows of a failure, so they are included in the message.
+type InstanceEvent struct {
+	Type          string    `json:"type"`
+	Time          time.Time `json:"time"`
+	Message       string    `json:"message"`
+	MessageOffset string    `json:"message_offset"`
+}
+
+// EventLostError is returned when the state of an instance was set to failed, but the event describing the failure
+// could not be added to it. The If-Match precondition only applies to the state update, whose ETag is returned with it.
+type EventLostError struct {
+	InstanceID string
+	Err        error
+}
+
+func (e *EventLostError) Error() string {
+	return fmt.Sprintf("instance %s was set to failed, but the event describing its failure was lost: %v", e.InstanceID, e.Err)
+}
+
+// Unwrap returns the error the event failed to be added with
+func (e *EventLostError) Unwrap() error {
+	return e.Err
+}
+
+// Client is a dataset API client
+type Client struct {
+	*dataset.Client
+	hcCli *health.Client
+}
+
+// NewWithHealthClient returns a dataset API client using the http client and URL of the provided health client
+func NewWithHealthClient(hcCli *health.Client) *Client {
+	return &Client{
+		Client: dataset.NewWithHealthClient(hcCli),
+		hcCli:  hcCli,
+	}
+}
+
+// PutInstanceFailed sets the state of the instance to failed if it matches ifMatch, then adds an event describing the failure to it.
+// If the event cannot be added, an EventLostError is returned with the ETag of the instance once its state was set.
+func (c *Client) PutInstanceFailed(ctx context.Context, serviceAuthToken, instanceID string, failure ImportFailure, ifMatch string) (eTag string, err error) {
+	eTag, err = c.PutInstanceState(ctx, serviceAuthToken, instanceID, dataset.StateFailed, ifMatch)
+	if err != nil {
+		return "", err
+	}
+
+	newETag, err := c.postInstanceEvent(ctx, serviceAuthToken, instanceID, failure)
+	if err != nil {
+		return eTag, &EventLostError{InstanceID: instanceID, Err: err}
+	}
+	if newETag != "" {
+		eTag = newETag
+	}
+	return eTag, nil
+}
+
+// postInstanceEvent adds an error event describing the failure to the instance, and returns the new ETag of the instance, if any
+func (c *Client) postInstanceEvent(ctx context.Context, serviceAuthToken, instanceID string, failure ImportFailure) (eTag string, err error) {
+	event := InstanceEvent{
+		Type:          eventTypeError,
+		Time:          time.Now().UTC(),
+		Message:       failure.String(),
+		MessageOffset: "0",
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+
+	uri := fmt.Sprintf("%s/instances/%s/events", c.hcCli.URL, instanceID)
+	req, err := http.NewRequest(http.MethodPost, uri, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	headers.SetIfMatch(req, headers.IfMatchAnyETag)
+	dprequest.AddServiceTokenHeader(req, serviceAuthToken)
+
+	resp, err := c.hcCli.Client.Do(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Error(ctx, "error closing http response body", err, log.Data{"uri": uri})
+		}
+	}()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", dataset.NewDatasetAPIResponse(resp, uri)
+	}
+
+	// a response without ETag leaves the ETag of the state update in place
+	eTag, _ = headers.GetResponseETag(resp)
+	return eTag, nil
+}
+
+// String returns the failure as a message including its error code and the rows which caused it, if any
+func (f ImportFailure) String() string {
+	msg := fmt.Sprintf("%s: %s", f.ErrorCode, f.Message)
+	if len(f.Rows) > 0 {
+		rows := make([]string, len(f.Rows))
+		for i, row := range f.Rows {
+			rows[i] = strconv.Itoa(row)
+		}
+		msg += fmt.Sprintf(" (rows: %s)", strings.Join(rows, ", "))
+	}
+	return msg
+}
@@ -0,0 +1,123 @@
+package datasetapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	"github.com/ONSdigital/dp-api-clients-go/v2/health"
+	"github.com/ONSdigital/dp-dimension-extractor/datasetapi"
+	dphttp "github.com/ONSdigital/dp-net/v2/http"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	testInstanceID = "123"
+	testAuthToken  = "myAuthToken"
+)
+
+var ctx = context.Background()
+
+type request struct {
+	method string
+	path   string
+	auth   string
+	body   []byte
+}
+
+// newDatasetAPI returns a fake dataset API recording the requests it receives and responding to events with eventStatus
+func newDatasetAPI(eventStatus int) (*httptest.Server, *[]request) {
+	var requests []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, request{method: r.Method, path: r.URL.Path, auth: r.Header.Get("Authorization"), body: body})
+		if r.URL.Path == "/instances/"+testInstanceID+"/events" {
+			w.WriteHeader(eventStatus)
+			return
+		}
+		w.Header().Set("ETag", `"state-etag"`)
+		w.WriteHeader(http.StatusOK)
+	}))
+	return srv, &requests
+}
+
+func newClient(url string) *datasetapi.Client {
+	clienter := dphttp.NewClient()
+	clienter.SetMaxRetries(0)
+	return datasetapi.NewWithHealthClient(health.NewClientWithClienter("", url, clienter))
+}
+
+func TestPutInstanceFailed(t *testing.T) {
+	failure := datasetapi.ImportFailure{ErrorCode: "csv", Message: "failed to extract dimensions: bork", Rows: []int{3}}
+
+	Convey("Given a dataset API accepting instance events", t, func() {
+		srv, requests := newDatasetAPI(http.StatusCreated)
+		defer srv.Close()
+
+		Convey("When PutInstanceFailed is called", func() {
+			_, err := newClient(srv.URL).PutInstanceFailed(ctx, testAuthToken, testInstanceID, failure, "*")
+
+			Convey("Then the instance state is set to failed and an error event is added to it", func() {
+				So(err, ShouldBeNil)
+				So(*requests, ShouldHaveLength, 2)
+
+				stateReq := (*requests)[0]
+				So(stateReq.method, ShouldEqual, http.MethodPut)
+				So(stateReq.path, ShouldEqual, "/instances/"+testInstanceID)
+				So(string(stateReq.body), ShouldContainSubstring, `"state":"failed"`)
+
+				eventReq := (*requests)[1]
+				So(eventReq.method, ShouldEqual, http.MethodPost)
+				So(eventReq.path, ShouldEqual, "/instances/"+testInstanceID+"/events")
+				So(eventReq.auth, ShouldEqual, stateReq.auth)
+
+				var event map[string]interface{}
+				So(json.Unmarshal(eventReq.body, &event), ShouldBeNil)
+				So(event, ShouldHaveLength, 4)
+				So(event["type"], ShouldEqual, "error")
+				So(event["message"], ShouldEqual, "csv: failed to extract dimensions: bork (rows: 3)")
+				So(event["message_offset"], ShouldEqual, "0")
+				So(event, ShouldContainKey, "time")
+			})
+		})
+	})
+
+	Convey("Given a dataset API accepting the instance state but rejecting instance events", t, func() {
+		srv, requests := newDatasetAPI(http.StatusInternalServerError)
+		defer srv.Close()
+
+		Convey("When PutInstanceFailed is called", func() {
+			eTag, err := newClient(srv.URL).PutInstanceFailed(ctx, testAuthToken, testInstanceID, failure, "*")
+
+			Convey("Then the state is set, and an error reporting the event as lost is returned with the ETag of the state update", func() {
+				So(*requests, ShouldHaveLength, 2)
+				So((*requests)[0].method, ShouldEqual, http.MethodPut)
+				So(eTag, ShouldEqual, `"state-etag"`)
+
+				var lostErr *datasetapi.EventLostError
+				So(errors.As(err, &lostErr), ShouldBeTrue)
+				So(lostErr.InstanceID, ShouldEqual, testInstanceID)
+				var apiErr *dataset.ErrInvalidDatasetAPIResponse
+				So(errors.As(err, &apiErr), ShouldBeTrue)
+				So(apiErr.Code(), ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+}
+
+func TestImportFailure_String(t *testing.T) {
+	Convey("An import failure without rows is described by its error code and message", t, func() {
+		failure := datasetapi.ImportFailure{ErrorCode: "s3", Message: "file not found"}
+		So(failure.String(), ShouldEqual, "s3: file not found")
+	})
+
+	Convey("An import failure with rows also lists them", t, func() {
+		failure := datasetapi.ImportFailure{ErrorCode: "csv", Message: "bad row", Rows: []int{3, 5}}
+		So(failure.String(), ShouldEqual, "csv: bad row (rows: 3, 5)")
+	})
+}
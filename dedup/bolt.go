@@ -0,0 +1,120 @@
+package dedup
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/net/context"
+)
+
+var bucketName = []byte("processed-events")
+
+// ErrInvalidExpiry is returned when the expiry stored for a key cannot be decoded
+var ErrInvalidExpiry = errors.New("invalid expiry stored for processed event")
+
+// BoltStore is a Store persisted in an embedded bbolt database file, so that processed events survive restarts
+type BoltStore struct {
+	db  *bolt.DB
+	ttl time.Duration
+	now func() time.Time
+}
+
+// NewBoltStore opens, or creates, the database file at path and removes any expired key from it
+func NewBoltStore(ctx context.Context, path string, ttl time.Duration) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &BoltStore{db: db, ttl: ttl, now: time.Now}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketName)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	purged, err := s.purge()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	log.Info(ctx, "opened processed events store", log.Data{"path": path, "purged_keys": purged})
+
+	return s, nil
+}
+
+// Seen returns true if the key was recorded and has not expired yet
+func (s *BoltStore) Seen(ctx context.Context, key string) (seen bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(bucketName).Get([]byte(key))
+		if value == nil {
+			return nil
+		}
+		expiry, err := decodeExpiry(value)
+		if err != nil {
+			return err
+		}
+		seen = s.now().Before(expiry)
+		return nil
+	})
+	return seen, err
+}
+
+// Record records the key with an expiry of now plus the TTL
+func (s *BoltStore) Record(ctx context.Context, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Put([]byte(key), encodeExpiry(s.now().Add(s.ttl)))
+	})
+}
+
+// Close closes the database file
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// purge deletes the expired keys, returning how many were deleted
+func (s *BoltStore) purge() (purged int, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketName)
+		now := s.now()
+
+		// keys are collected first, as deleting while iterating with a cursor would skip keys
+		var expired [][]byte
+		if err := bucket.ForEach(func(k, v []byte) error {
+			if expiry, err := decodeExpiry(v); err != nil || !now.Before(expiry) {
+				expired = append(expired, k)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		purged = len(expired)
+		return nil
+	})
+	return purged, err
+}
+
+func encodeExpiry(expiry time.Time) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(expiry.UnixNano()))
+	return b
+}
+
+func decodeExpiry(b []byte) (time.Time, error) {
+	if len(b) != 8 {
+		return time.Time{}, ErrInvalidExpiry
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(b))), nil
+}
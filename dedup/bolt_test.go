@@ -0,0 +1,62 @@
+package dedup
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+)
+
+func TestBoltStore(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a bolt store in a new database file", t, func() {
+		path := filepath.Join(t.TempDir(), "events.db")
+		store, err := NewBoltStore(ctx, path, time.Hour)
+		So(err, ShouldBeNil)
+
+		Convey("Then a key which was not recorded is not seen", func() {
+			seen, err := store.Seen(ctx, "a")
+			So(err, ShouldBeNil)
+			So(seen, ShouldBeFalse)
+			So(store.Close(), ShouldBeNil)
+		})
+
+		Convey("When a key is recorded", func() {
+			So(store.Record(ctx, "a"), ShouldBeNil)
+
+			Convey("Then it is seen", func() {
+				seen, err := store.Seen(ctx, "a")
+				So(err, ShouldBeNil)
+				So(seen, ShouldBeTrue)
+				So(store.Close(), ShouldBeNil)
+			})
+
+			Convey("Then it is still seen after the database file is reopened", func() {
+				So(store.Close(), ShouldBeNil)
+				store, err = NewBoltStore(ctx, path, time.Hour)
+				So(err, ShouldBeNil)
+				seen, err := store.Seen(ctx, "a")
+				So(err, ShouldBeNil)
+				So(seen, ShouldBeTrue)
+				So(store.Close(), ShouldBeNil)
+			})
+
+			Convey("Then it is no longer seen once the TTL has expired", func() {
+				store.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+				seen, err := store.Seen(ctx, "a")
+				So(err, ShouldBeNil)
+				So(seen, ShouldBeFalse)
+
+				Convey("And it is purged from the database file", func() {
+					purged, err := store.purge()
+					So(err, ShouldBeNil)
+					So(purged, ShouldEqual, 1)
+					So(store.Close(), ShouldBeNil)
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,37 @@
+package dedup
+
+import (
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"golang.org/x/net/context"
+)
+
+// MemoryStore is an in-memory Store which keeps up to a maximum number of keys, evicting the least recently used ones
+type MemoryStore struct {
+	cache *expirable.LRU[string, struct{}]
+}
+
+// NewMemoryStore returns a MemoryStore holding up to size keys, each of them expiring after ttl
+func NewMemoryStore(size int, ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		cache: expirable.NewLRU[string, struct{}](size, nil, ttl),
+	}
+}
+
+// Seen returns true if the key was recorded and has neither expired nor been evicted
+func (s *MemoryStore) Seen(ctx context.Context, key string) (bool, error) {
+	_, ok := s.cache.Get(key)
+	return ok, nil
+}
+
+// Record records the key
+func (s *MemoryStore) Record(ctx context.Context, key string) error {
+	s.cache.Add(key, struct{}{})
+	return nil
+}
+
+// Close is a no-op, as nothing is held outside memory
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,69 @@
+package dedup_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-extractor/dedup"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+)
+
+var ctx = context.Background()
+
+func TestKey(t *testing.T) {
+	Convey("The key of an event is made of the instance ID, file URL and unquoted ETag", t, func() {
+		So(dedup.Key("123", "s3://bucket/file.csv", `"abc"`), ShouldEqual, "123|s3://bucket/file.csv|abc")
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	Convey("Given an in-memory store holding up to 2 keys", t, func() {
+		store := dedup.NewMemoryStore(2, time.Hour)
+		defer store.Close()
+
+		Convey("Then a key which was not recorded is not seen", func() {
+			seen, err := store.Seen(ctx, "a")
+			So(err, ShouldBeNil)
+			So(seen, ShouldBeFalse)
+		})
+
+		Convey("When a key is recorded", func() {
+			So(store.Record(ctx, "a"), ShouldBeNil)
+
+			Convey("Then it is seen", func() {
+				seen, err := store.Seen(ctx, "a")
+				So(err, ShouldBeNil)
+				So(seen, ShouldBeTrue)
+			})
+		})
+
+		Convey("When more keys than the size of the store are recorded", func() {
+			So(store.Record(ctx, "a"), ShouldBeNil)
+			So(store.Record(ctx, "b"), ShouldBeNil)
+			So(store.Record(ctx, "c"), ShouldBeNil)
+
+			Convey("Then the least recently used key is evicted", func() {
+				seen, _ := store.Seen(ctx, "a")
+				So(seen, ShouldBeFalse)
+				seen, _ = store.Seen(ctx, "c")
+				So(seen, ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given an in-memory store with a short TTL", t, func() {
+		store := dedup.NewMemoryStore(10, 10*time.Millisecond)
+
+		Convey("When a key is recorded and the TTL expires", func() {
+			So(store.Record(ctx, "a"), ShouldBeNil)
+			time.Sleep(50 * time.Millisecond)
+
+			Convey("Then it is no longer seen", func() {
+				seen, err := store.Seen(ctx, "a")
+				So(err, ShouldBeNil)
+				So(seen, ShouldBeFalse)
+			})
+		})
+	})
+}
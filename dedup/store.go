@@ -0,0 +1,24 @@
+// Package dedup provides stores recording the input-file-available events which have already been processed,
+// so that repeated events can be acknowledged without extracting the same file again.
+package dedup
+
+import (
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// Store records the keys of processed events for a period of time (TTL)
+type Store interface {
+	// Seen returns true if the key was recorded and has not expired yet
+	Seen(ctx context.Context, key string) (bool, error)
+	// Record records the key, which will be seen until the TTL expires
+	Record(ctx context.Context, key string) error
+	// Close releases any resource held by the store
+	Close() error
+}
+
+// Key returns the key identifying an event for an instance, file and version (ETag) of the S3 object
+func Key(instanceID, fileURL, eTag string) string {
+	return strings.Join([]string{instanceID, fileURL, strings.Trim(eTag, `"`)}, "|")
+}
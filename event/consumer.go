@@ -1,12 +1,24 @@
 package event
 
 import (
+	"errors"
+	"time"
+
 	kafka "github.com/ONSdigital/dp-kafka/v2"
-	"github.com/ONSdigital/dp-reporter-client/reporter"
 	"github.com/ONSdigital/log.go/v2/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/context"
 )
 
+var tracer = otel.Tracer("github.com/ONSdigital/dp-dimension-extractor/event")
+
+// ErrDrainTimeout is returned when the message being handled could not be finished within the drain timeout,
+// so that its handling was aborted and its offset left uncommitted
+var ErrDrainTimeout = errors.New("timed out draining the event loop, the message being handled was aborted")
+
 // KafkaConsumer represents a Kafka consumer group instance
 type KafkaConsumer interface {
 	Channels() *kafka.ConsumerGroupChannels
@@ -17,46 +29,187 @@ type Service interface {
 	HandleMessage(ctx context.Context, message kafka.Message) (string, error)
 }
 
+// retryable is implemented by errors which indicate that handling the same message again may succeed
+type retryable interface {
+	Retryable() bool
+}
+
 // Consumer polls a kafka topic for incoming messages
 type Consumer struct {
 	KafkaConsumer KafkaConsumer
 	EventService  Service
-	ErrorReporter reporter.ErrorReporter
+	ErrorReporter ErrorReporter
+	MaxRetries    int
+	RetryBackoff  time.Duration
+	Metrics       Metrics
+
+	abortContext context.Context
+	abort        context.CancelFunc
 }
 
-// Start polling the kafka topic for incoming messages
+// Start polling the kafka topic for incoming messages. Once eventLoopContext is done, no new message is consumed,
+// and eventLoopDone is closed when the message being handled, if any, has been finished or aborted (see Drain).
 func (c *Consumer) Start(eventLoopContext context.Context, eventLoopDone, serviceIdentityValidated chan bool) {
+	c.abortContext, c.abort = context.WithCancel(context.Background())
 	go func() {
 		defer close(eventLoopDone)
+		defer c.abort()
 		// waiting to successfully validate service account (via zebedee)
-		<-serviceIdentityValidated
+		select {
+		case <-serviceIdentityValidated:
+		case <-eventLoopContext.Done():
+			log.Info(eventLoopContext, "event loop context done before the service identity was validated")
+			return
+		}
 		for {
 			select {
 			case <-eventLoopContext.Done():
 				log.Info(eventLoopContext, "event loop context done", log.Data{"eventLoopContextErr": eventLoopContext.Err()})
 				return
 			case message := <-c.KafkaConsumer.Channels().Upstream:
-				// In the future, kafka message will provice the context
-				kafkaContext := context.Background()
-				instanceID, err := c.EventService.HandleMessage(kafkaContext, message)
-				if err != nil {
-					log.Error(kafkaContext, "event failed to process", err, log.Data{"instance_id": instanceID})
-
-					if len(instanceID) == 0 {
-						log.Error(kafkaContext, "instance_id is empty, the error will not be reported", err)
-					} else {
-						err = c.ErrorReporter.Notify(instanceID, "event failed to process", err)
-						if err != nil {
-							log.Error(kafkaContext, "error while trying to report an error", err, log.Data{"instance_id": instanceID})
-						}
-					}
-
-				} else {
-					log.Info(kafkaContext, "event successfully processed", log.Data{"instance_id": instanceID})
+				if eventLoopContext.Err() != nil {
+					// the event loop was stopped while the message was received, it is left for the next consumer
+					message.Release()
+					log.Info(eventLoopContext, "event loop context done, message released without being handled")
+					return
+				}
+				if sessionRevoked(message) {
+					// another consumer of the group owns the partition of the message now, and will consume it again
+					message.Release()
+					log.Warn(eventLoopContext, "partition of the message revoked before it was handled, message released without being committed", log.Data{"offset": message.Offset()})
+					continue
+				}
+				if aborted := c.process(message); aborted {
+					return
 				}
-				message.CommitAndRelease()
-				log.Info(eventLoopContext, "message committed and released", log.Data{"instance_id": instanceID})
 			}
 		}
 	}()
 }
+
+// process handles the message, then commits and releases it, or reports the error it failed with.
+// If its handling is aborted on shutdown, or its partition is revoked by a rebalance in the meantime,
+// it is released without being committed, nor reported. It returns true if the handling was aborted on shutdown.
+func (c *Consumer) process(message kafka.Message) (aborted bool) {
+	metrics := c.metrics()
+	metrics.MessageConsumed()
+	metrics.JobStarted()
+	start := time.Now()
+	handlingContext, cancel := withSession(messageContext(c.abortContext, message), message)
+	defer cancel()
+	kafkaContext, span := tracer.Start(handlingContext, "process input-file-available message",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.Int64("messaging.kafka.message.offset", message.Offset())),
+	)
+	defer span.End()
+
+	instanceID, err := c.handleMessage(kafkaContext, message)
+	metrics.JobFinished()
+	span.SetAttributes(attribute.String("instance_id", instanceID))
+
+	switch {
+	case c.abortContext.Err() != nil:
+		// the offset is not committed, so that the message is consumed again after the restart
+		log.Warn(kafkaContext, "event handling aborted on shutdown, the message was released without being committed", log.Data{"instance_id": instanceID})
+		message.Release()
+		span.SetStatus(codes.Error, "aborted")
+		return true
+	case sessionRevoked(message):
+		// the offset can no longer be committed, the message is consumed again by the new owner of its partition
+		log.Warn(kafkaContext, "partition of the message revoked during its handling, the message was released without being committed", log.Data{"instance_id": instanceID})
+		message.Release()
+		span.SetStatus(codes.Error, "partition revoked")
+		return false
+	}
+
+	if err != nil {
+		metrics.MessageFailed(errorClass(err), time.Since(start))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		log.Error(kafkaContext, "event failed to process", err, log.Data{"instance_id": instanceID})
+
+		if len(instanceID) == 0 {
+			log.Error(kafkaContext, "instance_id is empty, the error will not be reported", err)
+		} else {
+			err = c.ErrorReporter.Notify(kafkaContext, instanceID, "event failed to process", err)
+			if err != nil {
+				log.Error(kafkaContext, "error while trying to report an error", err, log.Data{"instance_id": instanceID})
+			}
+		}
+
+	} else {
+		metrics.MessageSucceeded(time.Since(start))
+		log.Info(kafkaContext, "event successfully processed", log.Data{"instance_id": instanceID})
+	}
+	message.CommitAndRelease()
+	log.Info(kafkaContext, "message committed and released", log.Data{"instance_id": instanceID})
+	return false
+}
+
+// Drain waits for the event loop to finish handling the message in flight, if any, once eventLoopContext has been cancelled.
+// If the message cannot be handled within drainTimeout, its handling is aborted and its offset left uncommitted,
+// so that it is consumed again, and ErrDrainTimeout is returned once the event loop is done, or ctx is done.
+func (c *Consumer) Drain(ctx context.Context, eventLoopDone chan bool, drainTimeout time.Duration) error {
+	timer := time.NewTimer(drainTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-eventLoopDone:
+		log.Info(ctx, "event loop drained")
+		return nil
+	case <-timer.C:
+	}
+
+	log.Warn(ctx, "drain timeout exceeded, aborting the message being handled", log.Data{"drain_timeout": drainTimeout.String()})
+	if c.abort != nil {
+		c.abort()
+	}
+	select {
+	case <-eventLoopDone:
+	case <-ctx.Done():
+		log.Warn(ctx, "event loop failed to stop after the message being handled was aborted", log.FormatErrors([]error{ctx.Err()}))
+	}
+	return ErrDrainTimeout
+}
+
+// handleMessage calls the event service for the message, handling it again after RetryBackoff
+// for as long as it fails with a retryable error, up to MaxRetries times, or until the handling is aborted.
+func (c *Consumer) handleMessage(kafkaContext context.Context, message kafka.Message) (string, error) {
+	return handleWithRetries(kafkaContext, c.MaxRetries, c.RetryBackoff, func(ctx context.Context) (string, error) {
+		return c.EventService.HandleMessage(ctx, message)
+	})
+}
+
+// handleWithRetries calls handle again after retryBackoff for as long as it fails with a retryable error,
+// up to maxRetries times, or until ctx is done. It returns the instance ID and error of the last call.
+func handleWithRetries(ctx context.Context, maxRetries int, retryBackoff time.Duration, handle func(ctx context.Context) (string, error)) (string, error) {
+	for attempt := 1; ; attempt++ {
+		instanceID, err := handle(ctx)
+
+		var retryableErr retryable
+		if err == nil || !errors.As(err, &retryableErr) || !retryableErr.Retryable() || attempt > maxRetries {
+			return instanceID, err
+		}
+
+		log.Warn(ctx, "event failed to process with a retryable error, it will be handled again", log.FormatErrors([]error{err}), log.Data{
+			"instance_id":   instanceID,
+			"attempt":       attempt,
+			"max_retries":   maxRetries,
+			"retry_backoff": retryBackoff.String(),
+		})
+
+		select {
+		case <-time.After(retryBackoff):
+		case <-ctx.Done():
+			return instanceID, err
+		}
+	}
+}
+
+// metrics returns the metrics recorder of the consumer, or one which discards everything if none was provided
+func (c *Consumer) metrics() Metrics {
+	if c.Metrics == nil {
+		return nopMetrics{}
+	}
+	return c.Metrics
+}
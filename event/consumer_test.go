@@ -8,9 +8,13 @@ import (
 	"github.com/ONSdigital/dp-dimension-extractor/event/mocks"
 	kafka "github.com/ONSdigital/dp-kafka/v2"
 	"github.com/ONSdigital/dp-kafka/v2/kafkatest"
-	"github.com/ONSdigital/dp-reporter-client/reporter/reportertest"
+	"github.com/ONSdigital/dp-net/v2/request"
 	"github.com/ONSdigital/log.go/v2/log"
 	. "github.com/smartystreets/goconvey/convey"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/context"
 )
 
@@ -35,7 +39,7 @@ func TestConsumer_Start(t *testing.T) {
 			},
 		}
 
-		errorReporter := reportertest.NewImportErrorReporterMock(nil)
+		errorReporter := &mocks.ErrorReporter{}
 
 		consumer := &Consumer{
 			KafkaConsumer: kafkaConsumerMock,
@@ -82,7 +86,7 @@ func TestConsumer_HandleMessageError(t *testing.T) {
 		cgChannels := kafka.CreateConsumerGroupChannels(1)
 		kafkaConsumerMock := kafkatest.NewMessageConsumerWithChannels(cgChannels, true)
 
-		errorReporter := reportertest.NewImportErrorReporterMock(nil)
+		errorReporter := &mocks.ErrorReporter{}
 
 		consumer := &Consumer{
 			KafkaConsumer: kafkaConsumerMock,
@@ -164,6 +168,515 @@ func TestConsumer_HandleMessageError(t *testing.T) {
 	})
 }
 
+// testRetryableError is an error which indicates that the message can be handled again
+type testRetryableError struct{}
+
+func (e *testRetryableError) Error() string   { return "kafka did not acknowledge message" }
+func (e *testRetryableError) Retryable() bool { return true }
+
+func TestConsumer_HandleMessageRetryableError(t *testing.T) {
+	Convey("Given a consumer configured to retry failed messages twice", t, func() {
+
+		eventLoopDone := make(chan bool, 1)
+		serviceIdentityValidated := make(chan bool, 1)
+
+		msg := kafkatest.NewMessage(nil, 1)
+
+		cgChannels := kafka.CreateConsumerGroupChannels(1)
+		kafkaConsumerMock := kafkatest.NewMessageConsumerWithChannels(cgChannels, true)
+
+		errorReporter := &mocks.ErrorReporter{}
+
+		consumer := &Consumer{
+			KafkaConsumer: kafkaConsumerMock,
+			ErrorReporter: errorReporter,
+			MaxRetries:    2,
+			RetryBackoff:  time.Millisecond,
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer closeDown(t, cancel, eventLoopDone)
+
+		Convey("When handler.HandleMessage returns a retryable error and then succeeds", func() {
+			attempts := 0
+			handler := &mocks.MessageHandler{
+				EventLoopContextArgs: make([]context.Context, 0),
+				MessageArgs:          make([]kafka.Message, 0),
+				HandleMessageFunc: func(c context.Context, m kafka.Message) (string, error) {
+					attempts++
+					if attempts == 1 {
+						return "1234567890", &testRetryableError{}
+					}
+					return "1234567890", nil
+				},
+			}
+			consumer.EventService = handler
+
+			consumer.Start(ctx, eventLoopDone, serviceIdentityValidated)
+			serviceIdentityValidated <- true
+			cgChannels.Upstream <- msg
+
+			waitOrTimeout(t, eventLoopDone, msg.UpstreamDone())
+
+			Convey("Then handler.HandleMessage is called twice", func() {
+				So(len(handler.MessageArgs), ShouldEqual, 2)
+			})
+
+			Convey("And message.CommitAndRelease is called once", func() {
+				So(msg.IsCommitted(), ShouldBeTrue)
+				So(len(msg.CommitAndReleaseCalls()), ShouldEqual, 1)
+			})
+
+			Convey("And errorReporter.Notify is never called", func() {
+				So(len(errorReporter.NotifyCalls()), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When handler.HandleMessage always returns a retryable error", func() {
+			handler := &mocks.MessageHandler{
+				EventLoopContextArgs: make([]context.Context, 0),
+				MessageArgs:          make([]kafka.Message, 0),
+				HandleMessageFunc: func(c context.Context, m kafka.Message) (string, error) {
+					return "1234567890", &testRetryableError{}
+				},
+			}
+			consumer.EventService = handler
+
+			consumer.Start(ctx, eventLoopDone, serviceIdentityValidated)
+			serviceIdentityValidated <- true
+			cgChannels.Upstream <- msg
+
+			waitOrTimeout(t, eventLoopDone, msg.UpstreamDone())
+
+			Convey("Then handler.HandleMessage is called once and then retried twice", func() {
+				So(len(handler.MessageArgs), ShouldEqual, 3)
+			})
+
+			Convey("And message.CommitAndRelease is called once", func() {
+				So(msg.IsCommitted(), ShouldBeTrue)
+				So(len(msg.CommitAndReleaseCalls()), ShouldEqual, 1)
+			})
+
+			Convey("And errorReporter.Notify is called once", func() {
+				So(len(errorReporter.NotifyCalls()), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When handler.HandleMessage returns an error which is not retryable", func() {
+			handler := &mocks.MessageHandler{
+				EventLoopContextArgs: make([]context.Context, 0),
+				MessageArgs:          make([]kafka.Message, 0),
+				HandleMessageFunc: func(c context.Context, m kafka.Message) (string, error) {
+					return "1234567890", errors.New("bork")
+				},
+			}
+			consumer.EventService = handler
+
+			consumer.Start(ctx, eventLoopDone, serviceIdentityValidated)
+			serviceIdentityValidated <- true
+			cgChannels.Upstream <- msg
+
+			waitOrTimeout(t, eventLoopDone, msg.UpstreamDone())
+
+			Convey("Then handler.HandleMessage is called once", func() {
+				So(len(handler.MessageArgs), ShouldEqual, 1)
+			})
+
+			Convey("And errorReporter.Notify is called once", func() {
+				So(len(errorReporter.NotifyCalls()), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestConsumer_Drain(t *testing.T) {
+	Convey("Given a consumer handling a message", t, func() {
+		eventLoopDone := make(chan bool, 1)
+		serviceIdentityValidated := make(chan bool, 1)
+
+		msg := kafkatest.NewMessage(nil, 1)
+
+		cgChannels := kafka.CreateConsumerGroupChannels(1)
+		kafkaConsumerMock := kafkatest.NewMessageConsumerWithChannels(cgChannels, true)
+
+		errorReporter := &mocks.ErrorReporter{}
+		handling := make(chan struct{})
+		finish := make(chan struct{})
+
+		consumer := &Consumer{
+			KafkaConsumer: kafkaConsumerMock,
+			ErrorReporter: errorReporter,
+			MaxRetries:    1,
+			RetryBackoff:  time.Millisecond,
+			EventService: &mocks.MessageHandler{
+				EventLoopContextArgs: make([]context.Context, 0),
+				MessageArgs:          make([]kafka.Message, 0),
+				HandleMessageFunc: func(c context.Context, m kafka.Message) (string, error) {
+					close(handling)
+					select {
+					case <-finish:
+						return "1234567890", nil
+					case <-c.Done():
+						return "1234567890", &testRetryableError{}
+					}
+				},
+			},
+		}
+
+		eventLoopContext, eventLoopCancel := context.WithCancel(ctx)
+		defer eventLoopCancel()
+		consumer.Start(eventLoopContext, eventLoopDone, serviceIdentityValidated)
+		serviceIdentityValidated <- true
+		cgChannels.Upstream <- msg
+		<-handling
+		eventLoopCancel()
+
+		Convey("When the message is handled within the drain timeout", func() {
+			close(finish)
+			err := consumer.Drain(ctx, eventLoopDone, time.Second)
+
+			Convey("Then no error is returned and the message is committed", func() {
+				So(err, ShouldBeNil)
+				So(msg.IsCommitted(), ShouldBeTrue)
+				So(len(msg.CommitAndReleaseCalls()), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the message is not handled within the drain timeout", func() {
+			err := consumer.Drain(ctx, eventLoopDone, 10*time.Millisecond)
+
+			Convey("Then ErrDrainTimeout is returned once the handling has been aborted", func() {
+				So(err, ShouldEqual, ErrDrainTimeout)
+				_, open := <-eventLoopDone
+				So(open, ShouldBeFalse)
+			})
+
+			Convey("And the message is released without being committed", func() {
+				So(msg.IsCommitted(), ShouldBeFalse)
+				So(len(msg.CommitAndReleaseCalls()), ShouldEqual, 0)
+				So(len(msg.ReleaseCalls()), ShouldEqual, 1)
+			})
+
+			Convey("And errorReporter.Notify is never called", func() {
+				So(len(errorReporter.NotifyCalls()), ShouldEqual, 0)
+			})
+		})
+	})
+
+	Convey("Given a consumer whose event loop was stopped before the service identity was validated", t, func() {
+		eventLoopDone := make(chan bool, 1)
+		cgChannels := kafka.CreateConsumerGroupChannels(1)
+		consumer := &Consumer{KafkaConsumer: kafkatest.NewMessageConsumerWithChannels(cgChannels, true)}
+
+		eventLoopContext, eventLoopCancel := context.WithCancel(ctx)
+		consumer.Start(eventLoopContext, eventLoopDone, make(chan bool))
+		eventLoopCancel()
+
+		Convey("When the event loop is drained, no error is returned", func() {
+			So(consumer.Drain(ctx, eventLoopDone, time.Second), ShouldBeNil)
+		})
+	})
+}
+
+// sessionMessage is a kafkatest message consumed within a consumer group session, which ends when its partition is revoked
+type sessionMessage struct {
+	*kafkatest.Message
+	sessionContext context.Context
+}
+
+func (m *sessionMessage) SessionContext() context.Context {
+	return m.sessionContext
+}
+
+func TestConsumer_Rebalance(t *testing.T) {
+	Convey("Given a consumer receiving messages consumed within a consumer group session", t, func() {
+		eventLoopDone := make(chan bool, 1)
+		serviceIdentityValidated := make(chan bool, 1)
+
+		sessionContext, revoke := context.WithCancel(ctx)
+		defer revoke()
+		msg := &sessionMessage{Message: kafkatest.NewMessage(nil, 1), sessionContext: sessionContext}
+
+		cgChannels := kafka.CreateConsumerGroupChannels(1)
+		kafkaConsumerMock := kafkatest.NewMessageConsumerWithChannels(cgChannels, true)
+
+		errorReporter := &mocks.ErrorReporter{}
+		handling := make(chan struct{}, 2)
+		handler := &mocks.MessageHandler{
+			EventLoopContextArgs: make([]context.Context, 0),
+			MessageArgs:          make([]kafka.Message, 0),
+			HandleMessageFunc: func(c context.Context, m kafka.Message) (string, error) {
+				handling <- struct{}{}
+				if _, ok := m.(*sessionMessage); !ok {
+					return "1234567890", nil
+				}
+				<-c.Done()
+				return "1234567890", c.Err()
+			},
+		}
+
+		consumer := &Consumer{
+			KafkaConsumer: kafkaConsumerMock,
+			EventService:  handler,
+			ErrorReporter: errorReporter,
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer closeDown(t, cancel, eventLoopDone)
+		consumer.Start(ctx, eventLoopDone, serviceIdentityValidated)
+		serviceIdentityValidated <- true
+
+		Convey("When the partition of the message is revoked while it is being handled", func() {
+			cgChannels.Upstream <- msg
+			<-handling
+			revoke()
+
+			waitOrTimeout(t, eventLoopDone, msg.UpstreamDone())
+
+			Convey("Then the handling is cancelled and the message is released without being committed", func() {
+				So(len(handler.MessageArgs), ShouldEqual, 1)
+				So(msg.IsCommitted(), ShouldBeFalse)
+				So(len(msg.CommitAndReleaseCalls()), ShouldEqual, 0)
+				So(len(msg.ReleaseCalls()), ShouldEqual, 1)
+			})
+
+			Convey("And errorReporter.Notify is never called", func() {
+				So(len(errorReporter.NotifyCalls()), ShouldEqual, 0)
+			})
+
+			Convey("And the next message is handled and committed", func() {
+				next := kafkatest.NewMessage(nil, 2)
+				cgChannels.Upstream <- next
+
+				waitOrTimeout(t, eventLoopDone, next.UpstreamDone())
+				So(len(handler.MessageArgs), ShouldEqual, 2)
+				So(next.IsCommitted(), ShouldBeTrue)
+			})
+		})
+
+		Convey("When the partition of the message was revoked before it is received", func() {
+			revoke()
+			cgChannels.Upstream <- msg
+
+			waitOrTimeout(t, eventLoopDone, msg.UpstreamDone())
+
+			Convey("Then the message is released without being handled nor committed", func() {
+				So(len(handler.MessageArgs), ShouldEqual, 0)
+				So(msg.IsCommitted(), ShouldBeFalse)
+				So(len(msg.ReleaseCalls()), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestConsumer_RequestID(t *testing.T) {
+	Convey("Given a consumer whose handler fails to process messages", t, func() {
+		eventLoopDone := make(chan bool, 1)
+		serviceIdentityValidated := make(chan bool, 1)
+
+		cgChannels := kafka.CreateConsumerGroupChannels(1)
+		kafkaConsumerMock := kafkatest.NewMessageConsumerWithChannels(cgChannels, true)
+
+		handler := &mocks.MessageHandler{
+			EventLoopContextArgs: make([]context.Context, 0),
+			MessageArgs:          make([]kafka.Message, 0),
+			HandleMessageFunc: func(c context.Context, m kafka.Message) (string, error) {
+				return "1234567890", errors.New("bork")
+			},
+		}
+		errorReporter := &mocks.ErrorReporter{}
+
+		consumer := &Consumer{
+			KafkaConsumer: kafkaConsumerMock,
+			EventService:  handler,
+			ErrorReporter: errorReporter,
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer closeDown(t, cancel, eventLoopDone)
+
+		Convey("When a message with a request ID header is received", func() {
+			msg := kafkatest.NewMessage(nil, 1, kafkatest.TestHeader{kafka.TraceIDHeaderKey: "abcdef123456"})
+
+			consumer.Start(ctx, eventLoopDone, serviceIdentityValidated)
+			serviceIdentityValidated <- true
+			cgChannels.Upstream <- msg
+
+			waitOrTimeout(t, eventLoopDone, msg.UpstreamDone())
+
+			Convey("Then the message is handled with a context carrying the request ID", func() {
+				So(len(handler.EventLoopContextArgs), ShouldEqual, 1)
+				So(request.GetRequestId(handler.EventLoopContextArgs[0]), ShouldEqual, "abcdef123456")
+			})
+
+			Convey("And the error is reported with the same request ID", func() {
+				So(len(errorReporter.NotifyCalls()), ShouldEqual, 1)
+				So(request.GetRequestId(errorReporter.NotifyCalls()[0].Ctx), ShouldEqual, "abcdef123456")
+			})
+		})
+
+		Convey("When a message without a request ID header is received", func() {
+			msg := kafkatest.NewMessage(nil, 1)
+
+			consumer.Start(ctx, eventLoopDone, serviceIdentityValidated)
+			serviceIdentityValidated <- true
+			cgChannels.Upstream <- msg
+
+			waitOrTimeout(t, eventLoopDone, msg.UpstreamDone())
+
+			Convey("Then a request ID is generated and used for both handling and reporting the message", func() {
+				So(len(handler.EventLoopContextArgs), ShouldEqual, 1)
+				requestID := request.GetRequestId(handler.EventLoopContextArgs[0])
+				So(len(requestID), ShouldEqual, requestIDSize)
+				So(request.GetRequestId(errorReporter.NotifyCalls()[0].Ctx), ShouldEqual, requestID)
+			})
+		})
+	})
+}
+
+func TestConsumer_TraceContext(t *testing.T) {
+	Convey("Given a consumer with tracing enabled", t, func() {
+		defer otel.SetTracerProvider(otel.GetTracerProvider())
+		defer otel.SetTextMapPropagator(otel.GetTextMapPropagator())
+		otel.SetTracerProvider(sdktrace.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+
+		eventLoopDone := make(chan bool, 1)
+		serviceIdentityValidated := make(chan bool, 1)
+
+		cgChannels := kafka.CreateConsumerGroupChannels(1)
+		kafkaConsumerMock := kafkatest.NewMessageConsumerWithChannels(cgChannels, true)
+
+		handler := &mocks.MessageHandler{
+			EventLoopContextArgs: make([]context.Context, 0),
+			MessageArgs:          make([]kafka.Message, 0),
+			HandleMessageFunc: func(c context.Context, m kafka.Message) (string, error) {
+				return "1234567890", nil
+			},
+		}
+
+		consumer := &Consumer{
+			KafkaConsumer: kafkaConsumerMock,
+			EventService:  handler,
+			ErrorReporter: &mocks.ErrorReporter{},
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer closeDown(t, cancel, eventLoopDone)
+
+		Convey("When a message with a traceparent header is received", func() {
+			traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+			msg := kafkatest.NewMessage(nil, 1, kafkatest.TestHeader{"traceparent": "00-" + traceID + "-00f067aa0ba902b7-01"})
+
+			consumer.Start(ctx, eventLoopDone, serviceIdentityValidated)
+			serviceIdentityValidated <- true
+			cgChannels.Upstream <- msg
+
+			waitOrTimeout(t, eventLoopDone, msg.UpstreamDone())
+
+			Convey("Then the message is handled within a span which continues the upstream trace", func() {
+				So(len(handler.EventLoopContextArgs), ShouldEqual, 1)
+				spanContext := trace.SpanContextFromContext(handler.EventLoopContextArgs[0])
+				So(spanContext.IsValid(), ShouldBeTrue)
+				So(spanContext.TraceID().String(), ShouldEqual, traceID)
+				So(spanContext.SpanID().String(), ShouldNotEqual, "00f067aa0ba902b7")
+			})
+		})
+	})
+}
+
+// classifiedError is an error carrying its class of failure
+type classifiedError struct {
+	error
+	class string
+}
+
+func (e classifiedError) ErrorClass() string {
+	return e.class
+}
+
+func TestConsumer_Metrics(t *testing.T) {
+	Convey("Given a consumer with a metrics recorder", t, func() {
+		eventLoopDone := make(chan bool, 1)
+		serviceIdentityValidated := make(chan bool, 1)
+
+		cgChannels := kafka.CreateConsumerGroupChannels(1)
+		kafkaConsumerMock := kafkatest.NewMessageConsumerWithChannels(cgChannels, true)
+
+		var handlerErr error
+		handler := &mocks.MessageHandler{
+			EventLoopContextArgs: make([]context.Context, 0),
+			MessageArgs:          make([]kafka.Message, 0),
+			HandleMessageFunc: func(c context.Context, m kafka.Message) (string, error) {
+				return "1234567890", handlerErr
+			},
+		}
+		metrics := &mocks.Metrics{}
+
+		consumer := &Consumer{
+			KafkaConsumer: kafkaConsumerMock,
+			EventService:  handler,
+			ErrorReporter: &mocks.ErrorReporter{},
+			Metrics:       metrics,
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer closeDown(t, cancel, eventLoopDone)
+
+		Convey("When a message is processed successfully", func() {
+			msg := kafkatest.NewMessage(nil, 1)
+
+			consumer.Start(ctx, eventLoopDone, serviceIdentityValidated)
+			serviceIdentityValidated <- true
+			cgChannels.Upstream <- msg
+
+			waitOrTimeout(t, eventLoopDone, msg.UpstreamDone())
+
+			Convey("Then the message is recorded as consumed and succeeded, and the job is no longer in flight", func() {
+				So(metrics.Consumed, ShouldEqual, 1)
+				So(len(metrics.Succeeded), ShouldEqual, 1)
+				So(len(metrics.Failed), ShouldEqual, 0)
+				So(metrics.JobsStarted, ShouldEqual, 1)
+				So(metrics.MaxInFlight, ShouldEqual, 1)
+				So(metrics.InFlight, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When a message fails to be processed with a classified error", func() {
+			handlerErr = classifiedError{error: errors.New("bork"), class: "csv"}
+			msg := kafkatest.NewMessage(nil, 1)
+
+			consumer.Start(ctx, eventLoopDone, serviceIdentityValidated)
+			serviceIdentityValidated <- true
+			cgChannels.Upstream <- msg
+
+			waitOrTimeout(t, eventLoopDone, msg.UpstreamDone())
+
+			Convey("Then the message is recorded as failed with the class of the error", func() {
+				So(metrics.Consumed, ShouldEqual, 1)
+				So(len(metrics.Succeeded), ShouldEqual, 0)
+				So(metrics.FailedClasses, ShouldResemble, []string{"csv"})
+				So(metrics.InFlight, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When a message fails to be processed with an error which is not classified", func() {
+			handlerErr = errors.New("bork")
+			msg := kafkatest.NewMessage(nil, 1)
+
+			consumer.Start(ctx, eventLoopDone, serviceIdentityValidated)
+			serviceIdentityValidated <- true
+			cgChannels.Upstream <- msg
+
+			waitOrTimeout(t, eventLoopDone, msg.UpstreamDone())
+
+			Convey("Then the message is recorded as failed with an unknown class", func() {
+				So(metrics.FailedClasses, ShouldResemble, []string{errorClassUnknown})
+			})
+		})
+	})
+}
+
 func waitOrTimeout(t *testing.T, eventLoopDone chan bool, expected chan struct{}) {
 	select {
 	case <-eventLoopDone:
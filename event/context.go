@@ -0,0 +1,57 @@
+package event
+
+import (
+	"github.com/ONSdigital/dp-dimension-extractor/tracing"
+	kafka "github.com/ONSdigital/dp-kafka/v2"
+	"github.com/ONSdigital/dp-net/v2/request"
+	"go.opentelemetry.io/otel"
+	"golang.org/x/net/context"
+)
+
+// requestIDSize is the length of the request ID generated for messages which do not carry one
+const requestIDSize = 16
+
+// messageContext returns the context used to handle a message, which is done when parent is done. It carries the request ID found in the
+// message headers, or a newly generated one, so that it is logged and propagated to every outbound call,
+// and the W3C trace context found in the message headers, if any.
+func messageContext(parent context.Context, message kafka.Message) context.Context {
+	requestID := message.GetHeader(kafka.TraceIDHeaderKey)
+	if requestID == "" {
+		requestID = message.GetHeader(request.RequestHeaderKey)
+	}
+	if requestID == "" {
+		requestID = request.NewRequestID(requestIDSize)
+	}
+	ctx := request.WithRequestId(parent, requestID)
+	return otel.GetTextMapPropagator().Extract(ctx, tracing.MessageCarrier{Message: message})
+}
+
+// SessionMessage is implemented by messages which carry the context of the consumer group session they were consumed in.
+// The context is done when the session ends, i.e. when the partition of the message is revoked by a rebalance.
+type SessionMessage interface {
+	SessionContext() context.Context
+}
+
+// withSession returns a copy of ctx which is also done when the session of the message ends, if it carries one
+func withSession(ctx context.Context, message kafka.Message) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	sessionMessage, ok := message.(SessionMessage)
+	if !ok {
+		return ctx, cancel
+	}
+
+	go func() {
+		select {
+		case <-sessionMessage.SessionContext().Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// sessionRevoked returns true if the message carries the context of its session, and the session has ended
+func sessionRevoked(message kafka.Message) bool {
+	sessionMessage, ok := message.(SessionMessage)
+	return ok && sessionMessage.SessionContext().Err() != nil
+}
@@ -0,0 +1,65 @@
+package event
+
+import (
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-extractor/service"
+	"github.com/ONSdigital/log.go/v2/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+)
+
+// EventService handles input file available events which were not consumed from kafka
+type EventService interface {
+	HandleEvent(ctx context.Context, event *service.InputFileAvailable) (string, error)
+}
+
+// Extractor handles the events requested outside of kafka, e.g. through the admin API,
+// with the same retries and error reporting as the consumed messages
+type Extractor struct {
+	EventService  EventService
+	ErrorReporter ErrorReporter
+	MaxRetries    int
+	RetryBackoff  time.Duration
+	Metrics       Metrics
+}
+
+// Extract handles the event, handling it again after RetryBackoff for as long as it fails with a retryable error,
+// up to MaxRetries times, or until ctx is done. If it fails, the error is returned, and reported unless the event is a dry run.
+func (e *Extractor) Extract(ctx context.Context, event *service.InputFileAvailable) error {
+	metrics := e.metrics()
+	metrics.JobStarted()
+	defer metrics.JobFinished()
+	ctx, span := tracer.Start(ctx, "process extraction request", trace.WithAttributes(attribute.String("instance_id", event.InstanceID)))
+	defer span.End()
+
+	instanceID, err := handleWithRetries(ctx, e.MaxRetries, e.RetryBackoff, func(ctx context.Context) (string, error) {
+		return e.EventService.HandleEvent(ctx, event)
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		log.Error(ctx, "extraction failed to process", err, log.Data{"instance_id": instanceID, "dry_run": event.DryRun()})
+		if event.DryRun() {
+			// the instance is not being imported, so the failure of a dry run is not reported
+			return err
+		}
+		if reportErr := e.ErrorReporter.Notify(ctx, instanceID, "extraction failed to process", err); reportErr != nil {
+			log.Error(ctx, "error while trying to report an error", reportErr, log.Data{"instance_id": instanceID})
+		}
+		return err
+	}
+
+	log.Info(ctx, "extraction successfully processed", log.Data{"instance_id": instanceID})
+	return nil
+}
+
+// metrics returns the metrics recorder of the extractor, or one which discards everything if none was provided
+func (e *Extractor) metrics() Metrics {
+	if e.Metrics == nil {
+		return nopMetrics{}
+	}
+	return e.Metrics
+}
@@ -0,0 +1,79 @@
+package event
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-extractor/event/mocks"
+	"github.com/ONSdigital/dp-dimension-extractor/service"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+)
+
+func TestExtractor_Extract(t *testing.T) {
+	Convey("Given an extractor configured to retry failed extractions once", t, func() {
+		errorReporter := &mocks.ErrorReporter{}
+		metrics := &mocks.Metrics{}
+		extractor := &Extractor{
+			ErrorReporter: errorReporter,
+			MaxRetries:    1,
+			RetryBackoff:  time.Millisecond,
+			Metrics:       metrics,
+		}
+		event := &service.InputFileAvailable{InstanceID: "1234567890", FileURL: "s3://bucket/file.csv"}
+
+		Convey("When the event is handled successfully after a retryable error", func() {
+			attempts := 0
+			eventService := &mocks.EventService{
+				HandleEventFunc: func(c context.Context, event *service.InputFileAvailable) (string, error) {
+					attempts++
+					if attempts == 1 {
+						return event.InstanceID, &testRetryableError{}
+					}
+					return event.InstanceID, nil
+				},
+			}
+			extractor.EventService = eventService
+			err := extractor.Extract(ctx, event)
+
+			Convey("Then it is handled twice, and nothing is reported", func() {
+				So(err, ShouldBeNil)
+				So(eventService.EventArgs, ShouldResemble, []*service.InputFileAvailable{event, event})
+				So(errorReporter.NotifyCalls(), ShouldBeEmpty)
+				So(metrics.JobsStarted, ShouldEqual, 1)
+				So(metrics.JobsFinished, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the event fails to be handled", func() {
+			handleErr := errors.New("dataset api unavailable")
+			extractor.EventService = &mocks.EventService{
+				HandleEventFunc: func(c context.Context, event *service.InputFileAvailable) (string, error) {
+					return event.InstanceID, handleErr
+				},
+			}
+			err := extractor.Extract(ctx, event)
+
+			Convey("Then the error is reported for the instance, and returned", func() {
+				So(err, ShouldEqual, handleErr)
+				So(errorReporter.NotifyCalls(), ShouldHaveLength, 1)
+				So(errorReporter.NotifyCalls()[0].ID, ShouldEqual, "1234567890")
+				So(errorReporter.NotifyCalls()[0].Err, ShouldEqual, handleErr)
+			})
+		})
+
+		Convey("When a dry run fails to be handled, the error is returned but not reported", func() {
+			handleErr := errors.New("s3 unavailable")
+			extractor.EventService = &mocks.EventService{
+				HandleEventFunc: func(c context.Context, event *service.InputFileAvailable) (string, error) {
+					return event.InstanceID, handleErr
+				},
+			}
+			event.ProcessingOptions = map[string]string{service.ProcessingOptionDryRun: "true"}
+
+			So(extractor.Extract(ctx, event), ShouldEqual, handleErr)
+			So(errorReporter.NotifyCalls(), ShouldBeEmpty)
+		})
+	})
+}
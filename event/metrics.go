@@ -0,0 +1,41 @@
+package event
+
+import (
+	"errors"
+	"time"
+)
+
+// errorClassUnknown is used to label failures caused by an error which does not carry its class
+const errorClassUnknown = "unknown"
+
+// Metrics records the outcome of the messages consumed, so that the consumer is not coupled to any metrics backend
+type Metrics interface {
+	MessageConsumed()
+	MessageSucceeded(duration time.Duration)
+	MessageFailed(errorClass string, duration time.Duration)
+	JobStarted()
+	JobFinished()
+}
+
+// classified is implemented by errors which carry the class of failure which caused them
+type classified interface {
+	ErrorClass() string
+}
+
+// errorClass returns the class of failure carried by err, or errorClassUnknown
+func errorClass(err error) string {
+	var classifiedErr classified
+	if errors.As(err, &classifiedErr) {
+		return classifiedErr.ErrorClass()
+	}
+	return errorClassUnknown
+}
+
+// nopMetrics is used when the consumer is not given a metrics recorder
+type nopMetrics struct{}
+
+func (nopMetrics) MessageConsumed()                                        {}
+func (nopMetrics) MessageSucceeded(duration time.Duration)                 {}
+func (nopMetrics) MessageFailed(errorClass string, duration time.Duration) {}
+func (nopMetrics) JobStarted()                                             {}
+func (nopMetrics) JobFinished()                                            {}
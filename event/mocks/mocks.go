@@ -1,6 +1,9 @@
 package mocks
 
 import (
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-extractor/service"
 	kafka "github.com/ONSdigital/dp-kafka/v2"
 	"golang.org/x/net/context"
 )
@@ -18,3 +21,97 @@ func (m *MessageHandler) HandleMessage(c context.Context, msg kafka.Message) (st
 	m.MessageArgs = append(m.MessageArgs, msg)
 	return m.HandleMessageFunc(c, msg)
 }
+
+// EventService provides mocked functionality for a event.EventService
+type EventService struct {
+	EventArgs       []*service.InputFileAvailable
+	HandleEventFunc func(c context.Context, event *service.InputFileAvailable) (string, error)
+}
+
+// HandleEvent captures method parameters and returns the configured values
+func (m *EventService) HandleEvent(c context.Context, event *service.InputFileAvailable) (string, error) {
+	m.EventArgs = append(m.EventArgs, event)
+	return m.HandleEventFunc(c, event)
+}
+
+// NotifyParams holds the parameters of a single call to ErrorReporter.Notify
+type NotifyParams struct {
+	Ctx        context.Context
+	ID         string
+	ErrContext string
+	Err        error
+}
+
+// ErrorReporter provides mocked functionality for a event.ErrorReporter
+type ErrorReporter struct {
+	NotifyArgs []NotifyParams
+	NotifyErr  error
+}
+
+// Notify captures method parameters and returns the configured error
+func (m *ErrorReporter) Notify(c context.Context, id string, errContext string, err error) error {
+	m.NotifyArgs = append(m.NotifyArgs, NotifyParams{Ctx: c, ID: id, ErrContext: errContext, Err: err})
+	return m.NotifyErr
+}
+
+// NotifyCalls returns the parameters passed into each call to Notify
+func (m *ErrorReporter) NotifyCalls() []NotifyParams {
+	return m.NotifyArgs
+}
+
+// Producer provides mocked functionality for a event.Producer
+type Producer struct {
+	SendArgs [][]byte
+	SendCtxs []context.Context
+	SendErr  error
+}
+
+// Send captures method parameters and returns the configured error
+func (m *Producer) Send(c context.Context, message []byte) error {
+	m.SendCtxs = append(m.SendCtxs, c)
+	m.SendArgs = append(m.SendArgs, message)
+	return m.SendErr
+}
+
+// Metrics provides mocked functionality for a event.Metrics
+type Metrics struct {
+	Consumed      int
+	Succeeded     []time.Duration
+	FailedClasses []string
+	Failed        []time.Duration
+	InFlight      int
+	MaxInFlight   int
+	JobsStarted   int
+	JobsFinished  int
+}
+
+// MessageConsumed counts the call
+func (m *Metrics) MessageConsumed() {
+	m.Consumed++
+}
+
+// MessageSucceeded captures the duration
+func (m *Metrics) MessageSucceeded(duration time.Duration) {
+	m.Succeeded = append(m.Succeeded, duration)
+}
+
+// MessageFailed captures method parameters
+func (m *Metrics) MessageFailed(errorClass string, duration time.Duration) {
+	m.FailedClasses = append(m.FailedClasses, errorClass)
+	m.Failed = append(m.Failed, duration)
+}
+
+// JobStarted counts the call and the jobs in flight
+func (m *Metrics) JobStarted() {
+	m.JobsStarted++
+	m.InFlight++
+	if m.InFlight > m.MaxInFlight {
+		m.MaxInFlight = m.InFlight
+	}
+}
+
+// JobFinished counts the call and the jobs in flight
+func (m *Metrics) JobFinished() {
+	m.JobsFinished++
+	m.InFlight--
+}
@@ -0,0 +1,90 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ONSdigital/dp-reporter-client/model"
+	"github.com/ONSdigital/dp-reporter-client/schema"
+	"github.com/ONSdigital/log.go/v2/log"
+	"golang.org/x/net/context"
+)
+
+var (
+	errIDEmpty          = errors.New("cannot Notify, ID is a required field but was empty")
+	errContextEmpty     = errors.New("cannot Notify, errContext is a required field but was empty")
+	errProducerNil      = errors.New("cannot create new import error reporter as kafka producer is nil")
+	errServiceNameEmpty = errors.New("cannot create new import error reporter as serviceName is empty")
+)
+
+// ErrorReporter reports an error that occurred while handling an event for an instance
+type ErrorReporter interface {
+	Notify(ctx context.Context, id string, errContext string, err error) error
+}
+
+// Producer sends messages to kafka, writing the request ID held by the context as a message header
+type Producer interface {
+	Send(ctx context.Context, message []byte) error
+}
+
+// ImportErrorReporter sends error reports to the import-reporter
+type ImportErrorReporter struct {
+	producer    Producer
+	serviceName string
+}
+
+// NewImportErrorReporter creates a new ImportErrorReporter to send error reports to the import-reporter
+func NewImportErrorReporter(producer Producer, serviceName string) (*ImportErrorReporter, error) {
+	if producer == nil {
+		return nil, errProducerNil
+	}
+	if len(serviceName) == 0 {
+		return nil, errServiceNameEmpty
+	}
+	return &ImportErrorReporter{
+		producer:    producer,
+		serviceName: serviceName,
+	}, nil
+}
+
+// Notify sends an error report for the instance to the import-reporter.
+// The report carries the same request ID as the event which caused the error.
+func (r *ImportErrorReporter) Notify(ctx context.Context, id string, errContext string, err error) error {
+	if len(id) == 0 {
+		return errIDEmpty
+	}
+	if len(errContext) == 0 {
+		return errContextEmpty
+	}
+
+	reportEvent := &model.ReportEvent{
+		InstanceID:  id,
+		EventMsg:    fmt.Sprintf("%s: %s", errContext, err.Error()),
+		ServiceName: r.serviceName,
+		EventType:   "error",
+	}
+	logData := log.Data{"report_event": reportEvent}
+	log.Info(ctx, "sending report event for application error", logData)
+
+	avroBytes, err := schema.ReportEventSchema.Marshal(reportEvent)
+	if err != nil {
+		log.Error(ctx, "failed to marshal report event to avro", err, logData)
+		return err
+	}
+
+	return r.producer.Send(ctx, avroBytes)
+}
+
+// ErrorReporters notifies each of its reporters, so that an error can be reported in several ways
+type ErrorReporters []ErrorReporter
+
+// Notify notifies every reporter, even if some of them fail, and returns the errors of those which failed
+func (reporters ErrorReporters) Notify(ctx context.Context, id string, errContext string, err error) error {
+	var errs []error
+	for _, reporter := range reporters {
+		if notifyErr := reporter.Notify(ctx, id, errContext, err); notifyErr != nil {
+			errs = append(errs, notifyErr)
+		}
+	}
+	return errors.Join(errs...)
+}
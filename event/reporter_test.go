@@ -0,0 +1,117 @@
+package event
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-extractor/event/mocks"
+	"github.com/ONSdigital/dp-kafka/v2/kafkatest"
+	"github.com/ONSdigital/dp-net/v2/request"
+	"github.com/ONSdigital/dp-reporter-client/model"
+	"github.com/ONSdigital/dp-reporter-client/reporter"
+	"github.com/ONSdigital/dp-reporter-client/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewImportErrorReporter(t *testing.T) {
+	Convey("Given a nil producer, NewImportErrorReporter returns an error", t, func() {
+		r, err := NewImportErrorReporter(nil, "dp-dimension-extractor")
+		So(r, ShouldBeNil)
+		So(err, ShouldEqual, errProducerNil)
+	})
+
+	Convey("Given an empty service name, NewImportErrorReporter returns an error", t, func() {
+		r, err := NewImportErrorReporter(&mocks.Producer{}, "")
+		So(r, ShouldBeNil)
+		So(err, ShouldEqual, errServiceNameEmpty)
+	})
+}
+
+func TestImportErrorReporter_Notify(t *testing.T) {
+	Convey("Given an ImportErrorReporter", t, func() {
+		producer := &mocks.Producer{}
+		r, err := NewImportErrorReporter(producer, "dp-dimension-extractor")
+		So(err, ShouldBeNil)
+
+		Convey("When Notify is called with a context carrying a request ID", func() {
+			reqCtx := request.WithRequestId(ctx, "abcdef123456")
+			err := r.Notify(reqCtx, "1234567890", "event failed to process", errors.New("bork"))
+
+			Convey("Then the report event is sent with the same context", func() {
+				So(err, ShouldBeNil)
+				So(len(producer.SendArgs), ShouldEqual, 1)
+				So(request.GetRequestId(producer.SendCtxs[0]), ShouldEqual, "abcdef123456")
+
+				var reportEvent model.ReportEvent
+				So(schema.ReportEventSchema.Unmarshal(producer.SendArgs[0], &reportEvent), ShouldBeNil)
+				So(reportEvent, ShouldResemble, model.ReportEvent{
+					InstanceID:  "1234567890",
+					EventType:   "error",
+					EventMsg:    "event failed to process: bork",
+					ServiceName: "dp-dimension-extractor",
+				})
+			})
+		})
+
+		Convey("When Notify is called, the report event is encoded as dp-reporter-client encodes it", func() {
+			libProducer := kafkatest.NewMessageProducer(true)
+			libReporter, err := reporter.NewImportErrorReporter(libProducer, "dp-dimension-extractor")
+			So(err, ShouldBeNil)
+			notified := make(chan error, 1)
+			go func() { notified <- libReporter.Notify("1234567890", "event failed to process", errors.New("bork")) }()
+			expected := <-libProducer.Channels().Output
+			So(<-notified, ShouldBeNil)
+
+			So(r.Notify(ctx, "1234567890", "event failed to process", errors.New("bork")), ShouldBeNil)
+			So(producer.SendArgs, ShouldResemble, [][]byte{expected})
+		})
+
+		Convey("When Notify is called without an instance ID", func() {
+			err := r.Notify(ctx, "", "event failed to process", errors.New("bork"))
+
+			Convey("Then an error is returned and nothing is sent", func() {
+				So(err, ShouldEqual, errIDEmpty)
+				So(len(producer.SendArgs), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When the producer fails to send the report event", func() {
+			producer.SendErr = errors.New("kafka unavailable")
+			err := r.Notify(ctx, "1234567890", "event failed to process", errors.New("bork"))
+
+			Convey("Then the producer error is returned", func() {
+				So(err, ShouldResemble, errors.New("kafka unavailable"))
+			})
+		})
+	})
+}
+
+func TestErrorReporters_Notify(t *testing.T) {
+	Convey("Given several error reporters", t, func() {
+		first := &mocks.ErrorReporter{}
+		second := &mocks.ErrorReporter{}
+		reporters := ErrorReporters{first, second}
+
+		Convey("When Notify is called", func() {
+			err := reporters.Notify(ctx, "1234567890", "event failed to process", errors.New("bork"))
+
+			Convey("Then every reporter is notified", func() {
+				So(err, ShouldBeNil)
+				So(first.NotifyCalls(), ShouldHaveLength, 1)
+				So(second.NotifyCalls(), ShouldHaveLength, 1)
+				So(second.NotifyCalls()[0].ID, ShouldEqual, "1234567890")
+			})
+		})
+
+		Convey("When the first reporter fails", func() {
+			first.NotifyErr = errors.New("kafka unavailable")
+			err := reporters.Notify(ctx, "1234567890", "event failed to process", errors.New("bork"))
+
+			Convey("Then the other reporters are still notified and the failure is returned", func() {
+				So(err, ShouldNotBeNil)
+				So(errors.Is(err, first.NotifyErr), ShouldBeTrue)
+				So(second.NotifyCalls(), ShouldHaveLength, 1)
+			})
+		})
+	})
+}
@@ -3,13 +3,18 @@ package initialise
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/ONSdigital/dp-dimension-extractor/config"
+	"github.com/ONSdigital/dp-dimension-extractor/consumer"
+	"github.com/ONSdigital/dp-dimension-extractor/dedup"
+	"github.com/ONSdigital/dp-dimension-extractor/event"
+	"github.com/ONSdigital/dp-dimension-extractor/producer"
+	"github.com/ONSdigital/dp-dimension-extractor/registry"
 	"github.com/ONSdigital/dp-dimension-extractor/service"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
 	kafka "github.com/ONSdigital/dp-kafka/v2"
-	"github.com/ONSdigital/dp-reporter-client/reporter"
-	s3client "github.com/ONSdigital/dp-s3/v3"
+	dphttp "github.com/ONSdigital/dp-net/v2/http"
 	vault "github.com/ONSdigital/dp-vault"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsConfig "github.com/aws/aws-sdk-go-v2/config"
@@ -24,6 +29,8 @@ type ExternalServiceList struct {
 	DimensionExtractedErrProducer bool
 	Vault                         bool
 	S3Clients                     bool
+	ProcessedEventStore           bool
+	SchemaRegistry                bool
 	ErrorReporter                 bool
 	HealthCheck                   bool
 }
@@ -39,35 +46,26 @@ const (
 
 var kafkaProducerNames = []string{"DimensionExtracted", "DimensionExtractedErr"}
 
-var kafkaOffset = kafka.OffsetOldest
-
 // Values of the kafka producers names
 func (k KafkaProducerName) String() string {
 	return kafkaProducerNames[k]
 }
 
-// GetConsumer returns a kafka consumer, which might not be initialised yet.
-func (e *ExternalServiceList) GetConsumer(ctx context.Context, KafkaConfig *config.KafkaConfig) (kafkaConsumer *kafka.ConsumerGroup, err error) {
-	cgChannels := kafka.CreateConsumerGroupChannels(1)
-	cgConfig := &kafka.ConsumerGroupConfig{
-		Offset:       &kafkaOffset,
-		KafkaVersion: &KafkaConfig.Version,
-	}
-	if KafkaConfig.SecProtocol == config.KafkaTLSProtocolFlag {
-		cgConfig.SecurityConfig = kafka.GetSecurityConfig(
-			KafkaConfig.SecCACerts,
-			KafkaConfig.SecClientCert,
-			KafkaConfig.SecClientKey,
-			KafkaConfig.SecSkipVerify,
-		)
+// GetConsumer returns a kafka consumer, whose messages carry the context of the consumer group session they were consumed in.
+// It might not be initialised yet. If the initial offset is a timestamp, the partitions without a committed offset
+// are consumed from the first messages produced at or after it, which are looked up when the consumer group is joined.
+func (e *ExternalServiceList) GetConsumer(ctx context.Context, KafkaConfig *config.KafkaConfig) (kafkaConsumer *consumer.Consumer, err error) {
+	cgConfig, initialTime, err := getConsumerGroupConfig(KafkaConfig)
+	if err != nil {
+		return nil, err
 	}
-	kafkaConsumer, err = kafka.NewConsumerGroup(
+	kafkaConsumer, err = consumer.New(
 		ctx,
 		KafkaConfig.BindAddr,
 		KafkaConfig.InputFileAvailableTopic,
 		KafkaConfig.InputFileAvailableGroup,
-		cgChannels,
 		cgConfig,
+		initialTime,
 	)
 	if err != nil {
 		return
@@ -77,9 +75,45 @@ func (e *ExternalServiceList) GetConsumer(ctx context.Context, KafkaConfig *conf
 	return
 }
 
-// GetProducer returns a kafka producer, which might not be initialised yet.
-func (e *ExternalServiceList) GetProducer(ctx context.Context, kafkaConfig *config.KafkaConfig, topic string, name KafkaProducerName, envMax int) (kafkaProducer *kafka.Producer, err error) {
-	pChannels := kafka.CreateProducerChannels()
+// ResetConsumerOffsets resets the offsets of the consumer group for the input file available topic
+// to the first messages produced at or after the provided time
+func ResetConsumerOffsets(ctx context.Context, KafkaConfig *config.KafkaConfig, to time.Time) ([]consumer.PartitionOffset, error) {
+	cgConfig, _, err := getConsumerGroupConfig(KafkaConfig)
+	if err != nil {
+		return nil, err
+	}
+	return consumer.ResetOffsets(ctx, KafkaConfig.BindAddr, KafkaConfig.InputFileAvailableTopic, KafkaConfig.InputFileAvailableGroup, cgConfig, to)
+}
+
+// getConsumerGroupConfig returns the config of the consumer group, and the time of its initial offset if it is a timestamp
+func getConsumerGroupConfig(KafkaConfig *config.KafkaConfig) (*kafka.ConsumerGroupConfig, time.Time, error) {
+	initialTime, isTimestamp, err := KafkaConfig.InitialOffsetTime()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	offset := kafka.OffsetOldest
+	if KafkaConfig.InitialOffset == config.KafkaInitialOffsetNewest || isTimestamp {
+		offset = kafka.OffsetNewest
+	}
+
+	cgConfig := &kafka.ConsumerGroupConfig{
+		Offset:       &offset,
+		KafkaVersion: &KafkaConfig.Version,
+	}
+	if KafkaConfig.SecProtocol == config.KafkaTLSProtocolFlag {
+		cgConfig.SecurityConfig = kafka.GetSecurityConfig(
+			KafkaConfig.SecCACerts,
+			KafkaConfig.SecClientCert,
+			KafkaConfig.SecClientKey,
+			KafkaConfig.SecSkipVerify,
+		)
+	}
+	return cgConfig, initialTime, nil
+}
+
+// GetSyncProducer returns a kafka producer which waits for each message to be acknowledged by the broker,
+// and writes the provided headers on every message. It might not be initialised yet.
+func (e *ExternalServiceList) GetSyncProducer(ctx context.Context, kafkaConfig *config.KafkaConfig, topic string, name KafkaProducerName, envMax int, headers map[string]string) (kafkaProducer *producer.Producer, err error) {
 	pConfig := &kafka.ProducerConfig{
 		KafkaVersion:    &kafkaConfig.Version,
 		MaxMessageBytes: &envMax,
@@ -92,7 +126,7 @@ func (e *ExternalServiceList) GetProducer(ctx context.Context, kafkaConfig *conf
 			kafkaConfig.SecSkipVerify,
 		)
 	}
-	kafkaProducer, err = kafka.NewProducer(ctx, kafkaConfig.BindAddr, topic, pChannels, pConfig)
+	kafkaProducer, err = producer.New(ctx, kafkaConfig.BindAddr, topic, pConfig, kafkaConfig.DeliveryTimeout, headers)
 	if err != nil {
 		return
 	}
@@ -134,7 +168,7 @@ func (e *ExternalServiceList) GetS3Clients(ctx context.Context, cfg *config.Conf
 
 		s3Clients := make(map[string]service.S3Client)
 		for _, bucketName := range cfg.BucketNames {
-			s3Clients[bucketName] = s3client.NewClientWithConfig(bucketName, awsConfig, func(o *s3.Options) {
+			s3Clients[bucketName] = service.NewS3Client(bucketName, awsConfig, func(o *s3.Options) {
 				o.BaseEndpoint = aws.String(cfg.LocalstackHost)
 				o.UsePathStyle = true
 			})
@@ -153,21 +187,50 @@ func (e *ExternalServiceList) GetS3Clients(ctx context.Context, cfg *config.Conf
 	// create S3 clients for expected bucket names, so that they can be health-checked
 	s3Clients := make(map[string]service.S3Client)
 	for _, bucketName := range cfg.BucketNames {
-		s3Clients[bucketName] = s3client.NewClientWithConfig(bucketName, awsConfig)
+		s3Clients[bucketName] = service.NewS3Client(bucketName, awsConfig)
 	}
 	e.S3Clients = true
 
 	return &awsConfig, s3Clients, nil
 }
 
+// GetProcessedEventStore returns the store recording the processed events, according to DUPLICATE_EVENT_STORE,
+// or nil if the suppression of duplicate events is disabled
+func (e *ExternalServiceList) GetProcessedEventStore(ctx context.Context, cfg *config.Config) (store dedup.Store, err error) {
+	switch cfg.DuplicateEventStore {
+	case config.DuplicateEventStoreMemory:
+		store = dedup.NewMemoryStore(cfg.DuplicateEventCacheSize, cfg.DuplicateEventTTL)
+	case config.DuplicateEventStoreBolt:
+		store, err = dedup.NewBoltStore(ctx, cfg.DuplicateEventStorePath, cfg.DuplicateEventTTL)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+
+	e.ProcessedEventStore = true
+	return store, nil
+}
+
+// GetSchemaRegistry returns a client of the schema registry, or nil if SCHEMA_REGISTRY_URL is not set
+func (e *ExternalServiceList) GetSchemaRegistry(cfg *config.Config, clienter dphttp.Clienter) *registry.HTTPClient {
+	if cfg.SchemaRegistryURL == "" {
+		return nil
+	}
+
+	e.SchemaRegistry = true
+	return registry.NewHTTPClient(cfg.SchemaRegistryURL, clienter)
+}
+
 // GetImportErrorReporter returns an ErrorImportReporter to send error reports to the import-reporter (only if DimensionExtractedErrProducer is available)
-func (e *ExternalServiceList) GetImportErrorReporter(dimensionExtractedErrProducer reporter.KafkaProducer, serviceName string) (errorReporter reporter.ImportErrorReporter, err error) {
+func (e *ExternalServiceList) GetImportErrorReporter(dimensionExtractedErrProducer event.Producer, serviceName string) (errorReporter *event.ImportErrorReporter, err error) {
 	if !e.DimensionExtractedErrProducer {
-		return reporter.ImportErrorReporter{},
+		return nil,
 			fmt.Errorf("cannot create ImportErrorReporter because kafka producer '%s' is not available", kafkaProducerNames[DimensionExtractedErr])
 	}
 
-	errorReporter, err = reporter.NewImportErrorReporter(dimensionExtractedErrProducer, serviceName)
+	errorReporter, err = event.NewImportErrorReporter(dimensionExtractedErrProducer, serviceName)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,238 @@
+// Package jobs keeps track of the progress of the extractions, so that a long import can be told apart from a hung one.
+package jobs
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Phases of an extraction job
+const (
+	PhaseRetrieving       = "retrieving"
+	PhaseScanning         = "scanning"
+	PhasePostingOptions   = "posting_options"
+	PhaseUpdatingInstance = "updating_instance"
+	PhaseProducing        = "producing"
+	PhaseCompleted        = "completed"
+	PhaseSkipped          = "skipped"
+	PhaseFailed           = "failed"
+)
+
+// Status is a snapshot of the progress of an extraction job
+type Status struct {
+	InstanceID    string     `json:"instance_id"`
+	FileURL       string     `json:"file_url"`
+	Phase         string     `json:"phase"`
+	Attempts      int        `json:"attempts"`
+	RowsScanned   int        `json:"rows_scanned"`
+	OptionsPosted int        `json:"options_posted"`
+	OptionsTotal  int        `json:"options_total"`
+	BytesRead     int64      `json:"bytes_read"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+	Errors        []string   `json:"errors"`
+	// Report is the validation report of a dry run
+	Report interface{} `json:"report,omitempty"`
+}
+
+// Finished returns true if the job has completed, been skipped or failed
+func (s Status) Finished() bool {
+	return s.FinishedAt != nil
+}
+
+// Job records the progress of the extraction of the file of an instance. All its methods can be called on a nil Job,
+// which records nothing, so that the extraction does not depend on jobs being tracked.
+type Job struct {
+	mutex  sync.Mutex
+	status Status
+	onDone func()
+}
+
+// SetPhase records the phase the job has reached
+func (j *Job) SetPhase(phase string) {
+	if j == nil {
+		return
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.status.Phase = phase
+}
+
+// AddBytesRead adds to the number of bytes of the file read so far
+func (j *Job) AddBytesRead(count int) {
+	if j == nil {
+		return
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.status.BytesRead += int64(count)
+}
+
+// RowScanned counts a row of the file as scanned
+func (j *Job) RowScanned() {
+	if j == nil {
+		return
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.status.RowsScanned++
+}
+
+// SetOptionsTotal records the number of dimension options to post
+func (j *Job) SetOptionsTotal(total int) {
+	if j == nil {
+		return
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.status.OptionsTotal = total
+}
+
+// OptionPosted counts a dimension option as posted
+func (j *Job) OptionPosted() {
+	if j == nil {
+		return
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.status.OptionsPosted++
+}
+
+// SetReport records the validation report of a dry run
+func (j *Job) SetReport(report interface{}) {
+	if j == nil {
+		return
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.status.Report = report
+}
+
+// Finish records the end of the job, as failed with the error if it is not nil, otherwise as completed unless it was skipped
+func (j *Job) Finish(err error) {
+	if j == nil {
+		return
+	}
+	j.mutex.Lock()
+	now := time.Now().UTC()
+	j.status.FinishedAt = &now
+	switch {
+	case err != nil:
+		j.status.Phase = PhaseFailed
+		j.status.Errors = append(j.status.Errors, err.Error())
+	case j.status.Phase != PhaseSkipped:
+		j.status.Phase = PhaseCompleted
+	}
+	onDone := j.onDone
+	j.mutex.Unlock()
+
+	if onDone != nil {
+		onDone()
+	}
+}
+
+// Status returns a snapshot of the progress of the job
+func (j *Job) Status() Status {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	status := j.status
+	status.Errors = append([]string{}, j.status.Errors...)
+	return status
+}
+
+// Registry keeps the jobs in progress, and the most recent finished jobs, up to a maximum number. It only keeps
+// the latest job of each instance. A failed job of the same file of an instance is handled again by the same job,
+// which counts the attempts and keeps the errors of the previous ones.
+type Registry struct {
+	mutex    sync.Mutex
+	jobs     map[string]*Job
+	finished []string
+	retained int
+}
+
+// NewRegistry returns a registry which keeps up to retained finished jobs
+func NewRegistry(retained int) *Registry {
+	return &Registry{jobs: make(map[string]*Job), retained: retained}
+}
+
+// Start records the start of the extraction of the file of the instance, and returns its job.
+// If the registry is nil, a nil Job is returned, which records nothing.
+func (r *Registry) Start(instanceID, fileURL string) *Job {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	status := Status{
+		InstanceID: instanceID,
+		FileURL:    fileURL,
+		Phase:      PhaseRetrieving,
+		Attempts:   1,
+		StartedAt:  time.Now().UTC(),
+		Errors:     []string{},
+	}
+	if previous, ok := r.jobs[instanceID]; ok {
+		r.removeFinished(instanceID)
+		if previousStatus := previous.Status(); previousStatus.Phase == PhaseFailed && previousStatus.FileURL == fileURL {
+			status.Attempts = previousStatus.Attempts + 1
+			status.Errors = previousStatus.Errors
+		}
+	}
+
+	job := &Job{status: status}
+	job.onDone = func() { r.finish(instanceID, job) }
+	r.jobs[instanceID] = job
+	return job
+}
+
+// Get returns the status of the latest job of the instance, and false if there is none
+func (r *Registry) Get(instanceID string) (Status, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	job, ok := r.jobs[instanceID]
+	if !ok {
+		return Status{}, false
+	}
+	return job.Status(), true
+}
+
+// List returns the status of every job kept, the most recently started first
+func (r *Registry) List() []Status {
+	r.mutex.Lock()
+	statuses := make([]Status, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		statuses = append(statuses, job.Status())
+	}
+	r.mutex.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].StartedAt.After(statuses[j].StartedAt) })
+	return statuses
+}
+
+// finish records the job as finished, and forgets the oldest finished jobs beyond the number retained
+func (r *Registry) finish(instanceID string, job *Job) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// the job may have been replaced by a newer job of the same instance in the meantime
+	if r.jobs[instanceID] != job {
+		return
+	}
+	r.finished = append(r.finished, instanceID)
+	for len(r.finished) > r.retained {
+		delete(r.jobs, r.finished[0])
+		r.finished = r.finished[1:]
+	}
+}
+
+// removeFinished removes the instance from the finished jobs, if its job has finished
+func (r *Registry) removeFinished(instanceID string) {
+	for i, id := range r.finished {
+		if id == instanceID {
+			r.finished = append(r.finished[:i], r.finished[i+1:]...)
+			return
+		}
+	}
+}
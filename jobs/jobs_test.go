@@ -0,0 +1,87 @@
+package jobs_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-extractor/jobs"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestJob(t *testing.T) {
+	Convey("Given a job in progress", t, func() {
+		registry := jobs.NewRegistry(10)
+		job := registry.Start("instance-1", "s3://bucket/file.csv")
+
+		Convey("When its progress is recorded, it is returned by the registry", func() {
+			job.AddBytesRead(100)
+			job.SetPhase(jobs.PhaseScanning)
+			job.RowScanned()
+			job.RowScanned()
+			job.SetPhase(jobs.PhasePostingOptions)
+			job.SetOptionsTotal(3)
+			job.OptionPosted()
+
+			status, ok := registry.Get("instance-1")
+			So(ok, ShouldBeTrue)
+			So(status.Phase, ShouldEqual, jobs.PhasePostingOptions)
+			So(status.BytesRead, ShouldEqual, 100)
+			So(status.RowsScanned, ShouldEqual, 2)
+			So(status.OptionsPosted, ShouldEqual, 1)
+			So(status.OptionsTotal, ShouldEqual, 3)
+			So(status.Finished(), ShouldBeFalse)
+		})
+
+		Convey("When it fails, and the same file is extracted again", func() {
+			job.Finish(errors.New("dataset api unavailable"))
+			registry.Start("instance-1", "s3://bucket/file.csv")
+
+			Convey("Then the attempts are counted, and the errors of the previous ones kept", func() {
+				status, _ := registry.Get("instance-1")
+				So(status.Phase, ShouldEqual, jobs.PhaseRetrieving)
+				So(status.Attempts, ShouldEqual, 2)
+				So(status.Errors, ShouldResemble, []string{"dataset api unavailable"})
+			})
+		})
+
+		Convey("When it is skipped, it is finished without being completed", func() {
+			job.SetPhase(jobs.PhaseSkipped)
+			job.Finish(nil)
+
+			status, _ := registry.Get("instance-1")
+			So(status.Phase, ShouldEqual, jobs.PhaseSkipped)
+			So(status.Finished(), ShouldBeTrue)
+		})
+	})
+
+	Convey("A nil job records nothing", t, func() {
+		var registry *jobs.Registry
+		job := registry.Start("instance-1", "s3://bucket/file.csv")
+		So(job, ShouldBeNil)
+		So(func() {
+			job.SetPhase(jobs.PhaseScanning)
+			job.RowScanned()
+			job.Finish(nil)
+		}, ShouldNotPanic)
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	Convey("Given a registry retaining 2 finished jobs", t, func() {
+		registry := jobs.NewRegistry(2)
+		running := registry.Start("running", "s3://bucket/running.csv")
+
+		Convey("When 3 jobs finish, only the 2 most recent are kept with the running job", func() {
+			for _, instanceID := range []string{"first", "second", "third"} {
+				registry.Start(instanceID, "s3://bucket/"+instanceID+".csv").Finish(nil)
+			}
+
+			_, ok := registry.Get("first")
+			So(ok, ShouldBeFalse)
+			statuses := registry.List()
+			So(statuses, ShouldHaveLength, 3)
+			So(statuses[2].InstanceID, ShouldEqual, "running")
+			So(running.Status().Finished(), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,77 @@
+// Package kafkatls configures TLS on sarama clients from the dp-kafka security config.
+package kafkatls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	kafka "github.com/ONSdigital/dp-kafka/v2"
+	"github.com/Shopify/sarama"
+	saramatls "github.com/Shopify/sarama/tools/tls"
+)
+
+// certPrefix identifies a PEM value provided inline rather than as a file path
+const certPrefix = "-----BEGIN "
+
+// ErrCannotLoadCACerts is returned when the CA certs cannot be loaded
+var ErrCannotLoadCACerts = errors.New("cannot load CA Certs")
+
+func expandNewlines(s string) string {
+	return strings.ReplaceAll(s, `\n`, "\n")
+}
+
+// Add enables TLS in the sarama config, accepting certs either inline (PEM) or as file paths,
+// in the same way as dp-kafka does for its producers and consumers.
+func Add(tlsConfig *kafka.SecurityConfig, saramaConfig *sarama.Config) (err error) {
+	if tlsConfig == nil {
+		return
+	}
+
+	var saramaTLSConfig *tls.Config
+	if strings.HasPrefix(tlsConfig.ClientCert, certPrefix) {
+		var cert tls.Certificate
+		if cert, err = tls.X509KeyPair(
+			[]byte(expandNewlines(tlsConfig.ClientCert)),
+			[]byte(expandNewlines(tlsConfig.ClientKey)),
+		); err != nil {
+			return
+		}
+		saramaTLSConfig = &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{cert},
+		}
+	} else {
+		if saramaTLSConfig, err = saramatls.NewConfig(tlsConfig.ClientCert, tlsConfig.ClientKey); err != nil {
+			return
+		}
+	}
+
+	if tlsConfig.RootCACerts != "" {
+		var rootCAsBytes []byte
+		if strings.HasPrefix(tlsConfig.RootCACerts, certPrefix) {
+			rootCAsBytes = []byte(expandNewlines(tlsConfig.RootCACerts))
+		} else {
+			if rootCAsBytes, err = os.ReadFile(tlsConfig.RootCACerts); err != nil {
+				return fmt.Errorf("failed read from %q: %w", tlsConfig.RootCACerts, err)
+			}
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(rootCAsBytes) {
+			return fmt.Errorf("failed load from %q: %w", tlsConfig.RootCACerts, ErrCannotLoadCACerts)
+		}
+		saramaTLSConfig.RootCAs = certPool
+	}
+
+	if tlsConfig.InsecureSkipVerify {
+		saramaTLSConfig.InsecureSkipVerify = true
+	}
+
+	saramaConfig.Net.TLS.Enable = true
+	saramaConfig.Net.TLS.Config = saramaTLSConfig
+
+	return
+}
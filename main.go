@@ -2,6 +2,7 @@ package main
 
 import (
 	"errors"
+	"net/http"
 	"os"
 	"strconv"
 
@@ -9,19 +10,28 @@ import (
 	"os/signal"
 	"syscall"
 
-	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
 	"github.com/ONSdigital/dp-api-clients-go/v2/health"
 	"github.com/ONSdigital/dp-api-clients-go/v2/identity"
+	"github.com/ONSdigital/dp-dimension-extractor/api"
 	"github.com/ONSdigital/dp-dimension-extractor/config"
+	"github.com/ONSdigital/dp-dimension-extractor/consumer"
+	"github.com/ONSdigital/dp-dimension-extractor/datasetapi"
 	"github.com/ONSdigital/dp-dimension-extractor/event"
 	"github.com/ONSdigital/dp-dimension-extractor/initialise"
+	"github.com/ONSdigital/dp-dimension-extractor/jobs"
+	"github.com/ONSdigital/dp-dimension-extractor/metrics"
+	"github.com/ONSdigital/dp-dimension-extractor/producer"
+	"github.com/ONSdigital/dp-dimension-extractor/registry"
+	"github.com/ONSdigital/dp-dimension-extractor/schema"
 	"github.com/ONSdigital/dp-dimension-extractor/service"
+	"github.com/ONSdigital/dp-dimension-extractor/tracing"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
-	kafka "github.com/ONSdigital/dp-kafka/v2"
+	"github.com/ONSdigital/dp-net/v2/handlers"
 	dphttp "github.com/ONSdigital/dp-net/v2/http"
 	vault "github.com/ONSdigital/dp-vault"
 	"github.com/ONSdigital/log.go/v2/log"
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"golang.org/x/net/context"
 )
 
@@ -37,6 +47,10 @@ var (
 func main() {
 	log.Namespace = "dp-dimension-extractor"
 	ctx := context.Background()
+	// the logs of a command are written to stderr, so that its output can be piped
+	if len(os.Args) > 1 {
+		log.SetDestination(os.Stderr, nil)
+	}
 	log.Info(ctx, "starting dimension extractor")
 
 	// Signals channel to notify only of SIGING and SIGTERM
@@ -47,9 +61,27 @@ func main() {
 	cfg, err := config.Get()
 	exitIfError(ctx, "", err, nil)
 
+	// Run the command given on the command line, if any, instead of the service
+	if len(os.Args) > 1 {
+		exitIfError(ctx, "could not run command", runCommand(ctx, cfg, os.Args[1:], os.Stdout), log.Data{"command": os.Args[1]})
+		os.Exit(0)
+	}
+
 	// Sensitive fields are omitted from config.String().
 	log.Info(ctx, "config on startup", log.Data{"config": cfg})
 
+	// If tracing is enabled, register the global tracer provider. Exit on failure.
+	shutdownTracing := func(context.Context) error { return nil }
+	if cfg.OTelEnabled {
+		shutdownTracing, err = tracing.Init(ctx, tracing.Config{
+			Exporter:     cfg.OTelExporter,
+			OTLPEndpoint: cfg.OTelExporterOtlpEndpoint,
+			ServiceName:  cfg.OTelServiceName,
+			Version:      Version,
+		})
+		exitIfError(ctx, "could not initialise tracing", err, nil)
+	}
+
 	// Attempt to parse envMax from config. Exit on failure.
 	envMax, err := strconv.ParseInt(cfg.KafkaConfig.MaxBytes, 10, 32)
 	exitIfError(ctx, "encountered error parsing kafka max bytes", err, nil)
@@ -65,23 +97,30 @@ func main() {
 	awsConfig, s3Clients, err := serviceList.GetS3Clients(ctx, cfg)
 	logIfError(ctx, "", err, nil)
 
-	// Get dimensionExtracted Kafka Producer
-	dimensionExtractedProducer, err := serviceList.GetProducer(
+	// Get dimensionExtracted Kafka Producer, which waits for kafka to acknowledge each message,
+	// written with a content-type header describing the encoding of the messages
+	contentType := schema.ContentTypeAvro
+	if cfg.MessageEncoding == config.MessageEncodingJSON {
+		contentType = schema.ContentTypeJSON
+	}
+	dimensionExtractedProducer, err := serviceList.GetSyncProducer(
 		ctx,
 		&cfg.KafkaConfig,
 		cfg.KafkaConfig.DimensionsExtractedTopic,
 		initialise.DimensionExtracted,
 		int(envMax),
+		map[string]string{schema.ContentTypeHeaderKey: contentType},
 	)
 	exitIfError(ctx, "", err, nil)
 
-	// Get dimensionExtracted Error Kafka Producer
-	dimensionExtractedErrProducer, err := serviceList.GetProducer(
+	// Get dimensionExtracted Error Kafka Producer, which also waits for kafka to acknowledge each report event
+	dimensionExtractedErrProducer, err := serviceList.GetSyncProducer(
 		ctx,
 		&cfg.KafkaConfig,
 		cfg.KafkaConfig.EventReporterTopic,
 		initialise.DimensionExtractedErr,
 		int(envMax),
+		nil,
 	)
 	exitIfError(ctx, "", err, nil)
 
@@ -92,12 +131,24 @@ func main() {
 		logIfError(ctx, "", err, nil)
 	}
 
+	// Get the store of processed events, used to skip duplicate events (nil if disabled). Exit on failure.
+	processedEventStore, err := serviceList.GetProcessedEventStore(ctx, cfg)
+	exitIfError(ctx, "could not open processed event store", err, nil)
+
 	// Get Identity client for Zebedee serviceAuthToken validation
 	zhc := health.NewClient("Zebedee", cfg.ZebedeeURL)
 	idClient := identity.New(cfg.ZebedeeURL)
 
-	// Dataset API Client with Max retries
-	dc := dataset.NewAPIClientWithMaxRetries(cfg.DatasetAPIURL, cfg.MaxRetries)
+	// Dataset API Client with Max retries. If tracing is enabled, its transport propagates the trace context.
+	clienter := newHTTPClient(cfg)
+	dc := datasetapi.NewWithHealthClient(health.NewClientWithClienter("", cfg.DatasetAPIURL, clienter))
+
+	// Get the schema registry client, used to encode and decode messages with the ID of their schema (nil if disabled)
+	schemaRegistry := serviceList.GetSchemaRegistry(cfg, clienter)
+
+	// Client of the https file source, whose timeout bounds the download of a whole file
+	httpsFileClient := newHTTPClient(cfg)
+	httpsFileClient.SetTimeout(cfg.FileSourceHTTPSTimeout)
 
 	// Get HealthCheck and register checkers
 	hc, err := serviceList.GetHealthCheck(cfg, BuildTime, GitCommit, Version)
@@ -105,14 +156,102 @@ func main() {
 	if err := registerCheckers(ctx, &hc, !cfg.EncryptionDisabled, syncConsumerGroup, dimensionExtractedProducer, dimensionExtractedErrProducer, s3Clients, vc, zhc, dc); err != nil {
 		os.Exit(1)
 	}
+	if serviceList.SchemaRegistry {
+		if err := hc.AddCheck("Schema Registry", schemaRegistry.Checker); err != nil {
+			log.Error(ctx, "error adding check for schema registry", err)
+			os.Exit(1)
+		}
+	}
 
 	// create Channels
 	eventLoopDone := make(chan bool)
 	apiErrors := make(chan error, 1)
 
-	// Create HTTP server for healthcheck
+	// Metrics recorded while extracting dimensions, exposed at /metrics
+	metricsRecorder := metrics.New()
+
+	svc := &service.Service{
+		AuthToken:                  cfg.ServiceAuthToken,
+		DimensionExtractedProducer: dimensionExtractedProducer,
+		EncryptionDisabled:         cfg.EncryptionDisabled,
+		DatasetClient:              dc,
+		AwsConfig:                  awsConfig,
+		S3Clients:                  s3Clients,
+		VaultClient:                vc,
+		VaultPath:                  cfg.VaultPath,
+		Metrics:                    metricsRecorder,
+		ExtractorVersion:           Version,
+		MessageEncoding:            cfg.MessageEncoding,
+		ProduceMaxRetries:          cfg.KafkaConfig.ProducerMaxRetries,
+		ProduceRetryBackoff:        cfg.KafkaConfig.ProducerRetryBackoff,
+		Jobs:                       jobs.NewRegistry(cfg.ExtractionJobsRetained),
+		FileSources:                service.FileSources{},
+		FileChecks: service.FileChecks{
+			MaxSize:             cfg.InputMaxSize,
+			RejectEmpty:         cfg.InputRejectEmpty,
+			AllowedContentTypes: cfg.InputAllowedContentTypes,
+			RequiredMetadata:    cfg.InputRequiredMetadata,
+		},
+	}
+	for _, source := range cfg.FileSources {
+		switch source {
+		case config.FileSourceS3:
+			svc.FileSources[service.FileSchemeS3] = &service.S3Source{
+				Clients:             s3Clients,
+				AwsConfig:           awsConfig,
+				LocalstackHost:      cfg.LocalstackHost,
+				MaxReadRetries:      cfg.S3ReadMaxRetries,
+				ReadRetryBackoff:    cfg.S3ReadRetryBackoff,
+				DownloadConcurrency: cfg.S3DownloadConcurrency,
+				DownloadPartSize:    cfg.S3DownloadPartSize,
+				DownloadDir:         cfg.S3DownloadDir,
+				ChecksumMetadataKey: cfg.S3ChecksumMetadataKey,
+				VerifyETag:          cfg.S3VerifyETag,
+			}
+		case config.FileSourceFile:
+			svc.FileSources[service.FileSchemeFile] = &service.LocalFileSource{Dir: cfg.FileSourceDir}
+		case config.FileSourceHTTPS:
+			svc.FileSources[service.FileSchemeHTTPS] = &service.HTTPSFileSource{Client: httpsFileClient}
+		}
+	}
+	if serviceList.ProcessedEventStore {
+		svc.ProcessedEvents = processedEventStore
+	}
+	if serviceList.SchemaRegistry {
+		svc.SchemaRegistry = registry.NewCodec(schemaRegistry)
+		svc.DimensionsExtractedSubject = registry.ValueSubject(cfg.KafkaConfig.DimensionsExtractedTopic)
+	}
+
+	// Get Error reporters. If enabled, failed imports are also marked as failed in the dataset API.
+	var errorReporters event.ErrorReporters
+	importErrorReporter, err := serviceList.GetImportErrorReporter(dimensionExtractedErrProducer, log.Namespace)
+	logIfError(ctx, "error while attempting to create error reporter client", err, nil)
+	if err == nil {
+		errorReporters = append(errorReporters, importErrorReporter)
+	}
+	if cfg.MarkInstanceFailed {
+		errorReporters = append(errorReporters, &service.InstanceFailureReporter{AuthToken: cfg.ServiceAuthToken, DatasetClient: dc})
+	}
+
+	// Create HTTP server for healthcheck, metrics and the admin endpoints (extractions, their progress and the validation of uploaded files),
+	// which require a zebedee identity
 	router := mux.NewRouter()
 	router.HandleFunc("/health", hc.Handler)
+	router.Handle("/metrics", metricsRecorder.Handler())
+	identityHandler := handlers.IdentityWithHTTPClient(idClient)
+	adminAPI := api.Setup(router, func(handler http.HandlerFunc) http.Handler {
+		return identityHandler(handlers.CheckIdentity(handler))
+	}, &event.Extractor{
+		EventService:  svc,
+		ErrorReporter: errorReporters,
+		MaxRetries:    cfg.HandlerMaxRetries,
+		RetryBackoff:  cfg.HandlerRetryBackoff,
+		Metrics:       metricsRecorder,
+	}, svc.Jobs, svc, api.UploadLimits{
+		MaxSize:       cfg.ValidateMaxUploadSize,
+		MaxConcurrent: cfg.ValidateMaxConcurrent,
+		Timeout:       cfg.ValidateTimeout,
+	})
 	hc.Start(ctx)
 	httpServer := dphttp.NewServer(cfg.BindAddr, router)
 	httpServer.HandleOSSignals = false // Disable this here to allow main to manage graceful shutdown of the entire app.
@@ -126,26 +265,14 @@ func main() {
 		}
 	}()
 
-	svc := &service.Service{
-		AuthToken:                  cfg.ServiceAuthToken,
-		DimensionExtractedProducer: dimensionExtractedProducer,
-		EncryptionDisabled:         cfg.EncryptionDisabled,
-		DatasetClient:              dc,
-		AwsConfig:                  awsConfig,
-		S3Clients:                  s3Clients,
-		VaultClient:                vc,
-		VaultPath:                  cfg.VaultPath,
-	}
-
-	// Get Error reporter
-	errorReporter, err := serviceList.GetImportErrorReporter(dimensionExtractedErrProducer, log.Namespace)
-	logIfError(ctx, "error while attempting to create error reporter client", err, nil)
-
 	// Initialize event Consumer struct with initialized kafka consumers/producers and services
 	eventConsumer := event.Consumer{
 		KafkaConsumer: syncConsumerGroup,
 		EventService:  svc,
-		ErrorReporter: errorReporter,
+		ErrorReporter: errorReporters,
+		MaxRetries:    cfg.HandlerMaxRetries,
+		RetryBackoff:  cfg.HandlerRetryBackoff,
+		Metrics:       metricsRecorder,
 	}
 
 	eventLoopContext, eventLoopCancel := context.WithCancel(ctx)
@@ -167,8 +294,6 @@ func main() {
 
 	// Log non-fatal errors, without exiting. Note that the structs and channels will always exist even if Kafka has not been initialised yet.
 	syncConsumerGroup.Channels().LogErrors(ctx, "Kafka consumer error")
-	dimensionExtractedProducer.Channels().LogErrors(ctx, "Kafka dimension extracted producer")
-	dimensionExtractedErrProducer.Channels().LogErrors(ctx, "Kafka dimension extracted error producer")
 	go func() {
 		select {
 		case apiError := <-apiErrors:
@@ -182,22 +307,41 @@ func main() {
 	signal := <-signals
 	log.Info(ctx, "quitting after os signal received", log.Data{"signal": signal})
 
-	// give the app `Timeout` seconds to close gracefully before killing it.
-	shutdownContext, cancel := context.WithTimeout(ctx, cfg.GracefulShutdownTimeout)
-
+	// Stop fetching new messages, and give the message being handled, if any, `DrainTimeout` to finish.
+	// If it cannot finish in time, it is aborted and its offset left uncommitted so that it is consumed again.
+	// The extraction jobs requested through the admin API are drained in the same way, at the same time.
+	forcedShutdown := false
+	drainContext, drainCancel := context.WithTimeout(ctx, cfg.DrainTimeout+cfg.GracefulShutdownTimeout)
+	adminAPIDrained := make(chan error, 1)
 	go func() {
-
-		// If kafka consumer exists, stop listening to it. (Will close later)
-		if serviceList.Consumer {
-			log.Info(shutdownContext, "stopping kafka consumer listener")
-			if err := syncConsumerGroup.StopListeningToConsumer(shutdownContext); err != nil {
-				log.Error(shutdownContext, "failed to stop listening to kafka consumer", err)
+		adminAPIDrained <- adminAPI.Drain(drainContext, cfg.DrainTimeout)
+	}()
+	eventLoopCancel()
+	if serviceList.Consumer {
+		// If kafka consumer exists, stop listening to it, which completes once the message being handled is released. (Will close later)
+		go func() {
+			log.Info(drainContext, "stopping kafka consumer listener")
+			if err := syncConsumerGroup.StopListeningToConsumer(drainContext); err != nil {
+				log.Error(drainContext, "failed to stop listening to kafka consumer", err)
 			}
-			log.Info(shutdownContext, "stopped kafka consumer listener")
+			log.Info(drainContext, "stopped kafka consumer listener")
+		}()
+
+		if err := eventConsumer.Drain(drainContext, eventLoopDone, cfg.DrainTimeout); err != nil {
+			log.Error(drainContext, "failed to drain the event loop", err)
+			forcedShutdown = true
 		}
+	}
+	if err := <-adminAPIDrained; err != nil {
+		log.Error(drainContext, "failed to drain the extraction jobs", err)
+		forcedShutdown = true
+	}
+	drainCancel()
 
-		eventLoopCancel()
-		<-eventLoopDone
+	// give the app `Timeout` seconds to close gracefully before killing it.
+	shutdownContext, cancel := context.WithTimeout(ctx, cfg.GracefulShutdownTimeout)
+
+	go func() {
 
 		// Shutdown HTTP server
 		log.Info(shutdownContext, "closing http server")
@@ -230,6 +374,18 @@ func main() {
 			log.Info(shutdownContext, "closed kafka consumer", log.Data{"consumer": "SyncConsumerGroup"})
 		}
 
+		// If the processed event store exists, close it
+		if serviceList.ProcessedEventStore {
+			if err := processedEventStore.Close(); err != nil {
+				log.Error(shutdownContext, "failed to close processed event store", err)
+			}
+		}
+
+		// Flush any pending span
+		if err := shutdownTracing(shutdownContext); err != nil {
+			log.Error(shutdownContext, "failed to shutdown tracing", err)
+		}
+
 		log.Info(shutdownContext, "done shutdown - cancelling timeout context")
 		cancel() // stop timer
 	}()
@@ -238,22 +394,38 @@ func main() {
 	<-shutdownContext.Done()
 	if shutdownContext.Err() == context.DeadlineExceeded {
 		log.Error(shutdownContext, "shutdown timeout", shutdownContext.Err())
+		forcedShutdown = true
 	} else {
-		log.Error(shutdownContext, "done shutdown gracefully", errors.New("done shutdown gracefully"), log.Data{"context": shutdownContext.Err()})
+		log.Info(shutdownContext, "done shutdown gracefully", log.Data{"context": shutdownContext.Err(), "forced": forcedShutdown})
+	}
+
+	// exit with a non-zero status if the shutdown was forced, i.e. a message was aborted or resources failed to close in time
+	if forcedShutdown {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// newHTTPClient returns a client with REQUEST_MAX_RETRIES retries. If tracing is enabled, its transport propagates the trace context.
+func newHTTPClient(cfg *config.Config) dphttp.Clienter {
+	clienter := dphttp.NewClient()
+	if cfg.OTelEnabled {
+		clienter = dphttp.NewClientWithTransport(otelhttp.NewTransport(dphttp.DefaultTransport))
 	}
-	os.Exit(1)
+	clienter.SetMaxRetries(cfg.MaxRetries)
+	return clienter
 }
 
 // registerCheckers adds the checkers for the provided clients to the healthcheck object.
 // VaultClient health client will only be registered if encryption is enabled.
 func registerCheckers(ctx context.Context, hc *healthcheck.HealthCheck, isEncryptionEnabled bool,
-	kafkaConsumer *kafka.ConsumerGroup,
-	dimensionExtractedProducer *kafka.Producer,
-	dimensionExtractedErrProducer *kafka.Producer,
+	kafkaConsumer *consumer.Consumer,
+	dimensionExtractedProducer *producer.Producer,
+	dimensionExtractedErrProducer *producer.Producer,
 	s3Clients map[string]service.S3Client,
 	vc *vault.Client,
 	zebedeeHealthClient *health.Client,
-	dc *dataset.Client) error {
+	dc *datasetapi.Client) error {
 
 	hasErrors := false
 
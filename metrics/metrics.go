@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "dimension_extractor"
+
+// Prometheus records the metrics of the dimension extractor in its own prometheus registry.
+// It implements both event.Metrics and service.Metrics.
+type Prometheus struct {
+	registry                  *prometheus.Registry
+	messagesConsumed          prometheus.Counter
+	messagesSucceeded         prometheus.Counter
+	messagesFailed            *prometheus.CounterVec
+	processingDuration        *prometheus.HistogramVec
+	jobsInFlight              prometheus.Gauge
+	rowsScanned               prometheus.Counter
+	optionsPosted             prometheus.Counter
+	datasetAPIRequestDuration *prometheus.HistogramVec
+	bytesRead                 *prometheus.CounterVec
+}
+
+// New returns a Prometheus metrics recorder with all of its metrics, and the go runtime and process metrics, registered
+func New() *Prometheus {
+	p := &Prometheus{
+		registry: prometheus.NewRegistry(),
+		messagesConsumed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_consumed_total",
+			Help:      "Number of input-file-available messages consumed",
+		}),
+		messagesSucceeded: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_succeeded_total",
+			Help:      "Number of input-file-available messages processed successfully",
+		}),
+		messagesFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_failed_total",
+			Help:      "Number of input-file-available messages which failed to be processed, by class of error",
+		}, []string{"error_class"}),
+		processingDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "processing_duration_seconds",
+			Help:      "End-to-end duration of the processing of input-file-available messages, by result",
+			Buckets:   prometheus.ExponentialBuckets(0.5, 2, 12),
+		}, []string{"result"}),
+		jobsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "jobs_in_flight",
+			Help:      "Number of extractions currently being processed",
+		}),
+		rowsScanned: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rows_scanned_total",
+			Help:      "Number of observation rows scanned from input files",
+		}),
+		optionsPosted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "options_posted_total",
+			Help:      "Number of dimension options posted to the dataset API",
+		}),
+		datasetAPIRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "dataset_api_request_duration_seconds",
+			Help:      "Duration of requests to the dataset API, by endpoint and status",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"endpoint", "status"}),
+		bytesRead: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bytes_read_total",
+			Help:      "Number of bytes of input files read, after decryption, by source",
+		}, []string{"source"}),
+	}
+
+	p.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		p.messagesConsumed,
+		p.messagesSucceeded,
+		p.messagesFailed,
+		p.processingDuration,
+		p.jobsInFlight,
+		p.rowsScanned,
+		p.optionsPosted,
+		p.datasetAPIRequestDuration,
+		p.bytesRead,
+	)
+
+	return p
+}
+
+// Handler returns the http handler which exposes the metrics in the prometheus text format
+func (p *Prometheus) Handler() http.Handler {
+	return promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{Registry: p.registry})
+}
+
+// MessageConsumed counts a consumed message
+func (p *Prometheus) MessageConsumed() {
+	p.messagesConsumed.Inc()
+}
+
+// MessageSucceeded counts a message processed successfully and observes its processing duration
+func (p *Prometheus) MessageSucceeded(duration time.Duration) {
+	p.messagesSucceeded.Inc()
+	p.processingDuration.WithLabelValues("success").Observe(duration.Seconds())
+}
+
+// MessageFailed counts a message which failed to be processed and observes its processing duration
+func (p *Prometheus) MessageFailed(errorClass string, duration time.Duration) {
+	p.messagesFailed.WithLabelValues(errorClass).Inc()
+	p.processingDuration.WithLabelValues("failure").Observe(duration.Seconds())
+}
+
+// JobStarted increments the number of extractions in flight
+func (p *Prometheus) JobStarted() {
+	p.jobsInFlight.Inc()
+}
+
+// JobFinished decrements the number of extractions in flight
+func (p *Prometheus) JobFinished() {
+	p.jobsInFlight.Dec()
+}
+
+// RowsScanned counts the observation rows scanned from an input file
+func (p *Prometheus) RowsScanned(count int) {
+	p.rowsScanned.Add(float64(count))
+}
+
+// OptionsPosted counts the dimension options posted to the dataset API
+func (p *Prometheus) OptionsPosted(count int) {
+	p.optionsPosted.Add(float64(count))
+}
+
+// DatasetAPIRequest observes the duration of a request to the dataset API
+func (p *Prometheus) DatasetAPIRequest(endpoint, status string, duration time.Duration) {
+	p.datasetAPIRequestDuration.WithLabelValues(endpoint, status).Observe(duration.Seconds())
+}
+
+// BytesRead counts the bytes of an input file read from a source, i.e. the scheme of the file URLs it serves
+func (p *Prometheus) BytesRead(source string, count int) {
+	p.bytesRead.WithLabelValues(source).Add(float64(count))
+}
@@ -0,0 +1,60 @@
+package metrics_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-extractor/event"
+	"github.com/ONSdigital/dp-dimension-extractor/metrics"
+	"github.com/ONSdigital/dp-dimension-extractor/service"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var (
+	_ event.Metrics   = (*metrics.Prometheus)(nil)
+	_ service.Metrics = (*metrics.Prometheus)(nil)
+)
+
+func TestPrometheus(t *testing.T) {
+	Convey("Given a prometheus metrics recorder which has recorded the processing of two messages", t, func() {
+		p := metrics.New()
+		p.MessageConsumed()
+		p.JobStarted()
+		p.RowsScanned(10)
+		p.OptionsPosted(4)
+		p.BytesRead("s3", 512)
+		p.BytesRead("file", 64)
+		p.DatasetAPIRequest("get_instance", "2xx", 20*time.Millisecond)
+		p.DatasetAPIRequest("post_instance_dimensions", "409", 30*time.Millisecond)
+		p.JobFinished()
+		p.MessageFailed("dataset_api", 2*time.Second)
+		p.MessageConsumed()
+		p.JobStarted()
+
+		Convey("When the metrics endpoint is requested", func() {
+			w := httptest.NewRecorder()
+			p.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			body, err := io.ReadAll(w.Body)
+			So(err, ShouldBeNil)
+
+			Convey("Then the recorded metrics are exposed in the prometheus text format", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(string(body), ShouldContainSubstring, "dimension_extractor_messages_consumed_total 2")
+				So(string(body), ShouldContainSubstring, `dimension_extractor_messages_failed_total{error_class="dataset_api"} 1`)
+				So(string(body), ShouldContainSubstring, "dimension_extractor_messages_succeeded_total 0")
+				So(string(body), ShouldContainSubstring, `dimension_extractor_processing_duration_seconds_count{result="failure"} 1`)
+				So(string(body), ShouldContainSubstring, "dimension_extractor_jobs_in_flight 1")
+				So(string(body), ShouldContainSubstring, "dimension_extractor_rows_scanned_total 10")
+				So(string(body), ShouldContainSubstring, "dimension_extractor_options_posted_total 4")
+				So(string(body), ShouldContainSubstring, `dimension_extractor_bytes_read_total{source="s3"} 512`)
+				So(string(body), ShouldContainSubstring, `dimension_extractor_bytes_read_total{source="file"} 64`)
+				So(string(body), ShouldContainSubstring, `dimension_extractor_dataset_api_request_duration_seconds_count{endpoint="get_instance",status="2xx"} 1`)
+				So(string(body), ShouldContainSubstring, `dimension_extractor_dataset_api_request_duration_seconds_count{endpoint="post_instance_dimensions",status="409"} 1`)
+				So(string(body), ShouldContainSubstring, "go_goroutines")
+			})
+		})
+	})
+}
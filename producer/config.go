@@ -0,0 +1,46 @@
+package producer
+
+import (
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-extractor/kafkatls"
+	kafka "github.com/ONSdigital/dp-kafka/v2"
+	"github.com/Shopify/sarama"
+)
+
+// getConfig creates a sarama config for a synchronous producer, overwriting any values provided in pConfig.
+// The broker is asked to acknowledge each message once every in-sync replica has stored it, waiting at most deliveryTimeout for them.
+// Messages are partitioned by the hash of their key, or randomly if they have none.
+func getConfig(pConfig *kafka.ProducerConfig, deliveryTimeout time.Duration) (config *sarama.Config, err error) {
+	config = sarama.NewConfig()
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Return.Successes = true
+	config.Producer.Return.Errors = true
+	config.Producer.Partitioner = sarama.NewHashPartitioner
+	if deliveryTimeout > 0 {
+		config.Producer.Timeout = deliveryTimeout
+	}
+	if pConfig != nil {
+		if pConfig.KafkaVersion != nil {
+			if config.Version, err = sarama.ParseKafkaVersion(*pConfig.KafkaVersion); err != nil {
+				return nil, err
+			}
+		}
+		if pConfig.MaxMessageBytes != nil && *pConfig.MaxMessageBytes > 0 {
+			config.Producer.MaxMessageBytes = *pConfig.MaxMessageBytes
+		}
+		if pConfig.KeepAlive != nil {
+			config.Net.KeepAlive = *pConfig.KeepAlive
+		}
+		if pConfig.RetryMax != nil {
+			config.Producer.Retry.Max = *pConfig.RetryMax
+		}
+		if pConfig.RetryBackoff != nil {
+			config.Producer.Retry.Backoff = *pConfig.RetryBackoff
+		}
+		if err = kafkatls.Add(pConfig.SecurityConfig, config); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,31 @@
+package producer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+const (
+	// MsgHealthyProducer is the check message returned when the producer is healthy
+	MsgHealthyProducer = "kafka producer is healthy"
+
+	// MsgUninitialisedProducer is the check message returned when the producer has not connected to kafka yet
+	MsgUninitialisedProducer = "kafka producer is not initialised"
+)
+
+// Checker checks that the topic metadata can be obtained from kafka and updates the provided CheckState accordingly
+func (p *Producer) Checker(ctx context.Context, state *healthcheck.CheckState) error {
+	if !p.IsInitialised() {
+		return state.Update(healthcheck.StatusWarning, MsgUninitialisedProducer, 0)
+	}
+
+	if err := p.client.RefreshMetadata(p.topic); err != nil {
+		log.Warn(ctx, "failed to obtain metadata from kafka", log.FormatErrors([]error{err}), log.Data{"topic": p.topic})
+		return state.Update(healthcheck.StatusCritical, fmt.Sprintf("failed to obtain metadata for topic %s: %s", p.topic, err), 0)
+	}
+
+	return state.Update(healthcheck.StatusOK, MsgHealthyProducer, 0)
+}
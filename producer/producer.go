@@ -0,0 +1,205 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-extractor/tracing"
+	kafka "github.com/ONSdigital/dp-kafka/v2"
+	"github.com/ONSdigital/dp-net/v2/request"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/Shopify/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/ONSdigital/dp-dimension-extractor/producer")
+
+// ErrUninitialisedProducer is returned when a message is sent before a connection to kafka could be established
+var ErrUninitialisedProducer = errors.New("producer is not initialised")
+
+// Producer sends messages to a single kafka topic through a sarama sync producer, and waits for the broker to acknowledge each of them.
+// It is used instead of a dp-kafka v2 producer, which only reports the messages kafka failed to store.
+type Producer struct {
+	brokerAddrs []string
+	topic       string
+	config      *sarama.Config
+	headers     []sarama.RecordHeader
+	client      sarama.Client
+	producer    sarama.SyncProducer
+	mutex       *sync.Mutex
+}
+
+// New returns a new Producer for the provided topic, writing the provided headers on every message, e.g. their content type.
+// If kafka is not reachable, the producer is returned uninitialised and a new connection attempt will be made on the next Send.
+func New(ctx context.Context, brokerAddrs []string, topic string, pConfig *kafka.ProducerConfig, deliveryTimeout time.Duration, headers map[string]string) (*Producer, error) {
+	config, err := getConfig(pConfig, deliveryTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Producer{
+		brokerAddrs: brokerAddrs,
+		topic:       topic,
+		config:      config,
+		headers:     recordHeaders(headers),
+		mutex:       &sync.Mutex{},
+	}
+
+	if err := p.Initialise(ctx); err != nil {
+		log.Warn(ctx, "kafka producer could not be initialised, will retry on send", log.FormatErrors([]error{err}), log.Data{"topic": topic})
+	}
+
+	return p, nil
+}
+
+// recordHeaders returns the headers sorted by key, so that they are always written in the same order
+func recordHeaders(headers map[string]string) []sarama.RecordHeader {
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	recordHeaders := make([]sarama.RecordHeader, 0, len(keys))
+	for _, key := range keys {
+		recordHeaders = append(recordHeaders, sarama.RecordHeader{Key: []byte(key), Value: []byte(headers[key])})
+	}
+	return recordHeaders
+}
+
+// IsInitialised returns true only if a connection to kafka has been established
+func (p *Producer) IsInitialised() bool {
+	if p == nil {
+		return false
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.producer != nil
+}
+
+// Initialise connects to kafka, only if the producer was not already initialised
+func (p *Producer) Initialise(ctx context.Context) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.producer != nil {
+		return nil
+	}
+
+	client, err := sarama.NewClient(p.brokerAddrs, p.config)
+	if err != nil {
+		return err
+	}
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
+		return err
+	}
+
+	p.client = client
+	p.producer = producer
+	log.Info(ctx, "initialised sarama sync producer", log.Data{"topic": p.topic})
+	return nil
+}
+
+// Send produces the message without a key. See SendWithKey.
+func (p *Producer) Send(ctx context.Context, message []byte) error {
+	return p.SendWithKey(ctx, "", message)
+}
+
+// SendWithKey produces the message, and returns once kafka has acknowledged it or failed to store it.
+// Messages with the same non-empty key are sent to the same partition, so that they are consumed in the order they were produced.
+// The headers of the producer are written on the message, along with the request ID held by the context, if any,
+// and the trace context of the produce span.
+// Once the message has been handed to sarama, the outcome of its delivery is waited for even if the context is done,
+// so that a message is never deemed undelivered while it may still be delivered. It is bounded by the delivery timeout
+// and the retries of the sarama config. If the context is done before, the message is not sent.
+func (p *Producer) SendWithKey(ctx context.Context, key string, message []byte) (err error) {
+	ctx, span := tracer.Start(ctx, "kafka produce "+p.topic, trace.WithSpanKind(trace.SpanKindProducer), trace.WithAttributes(
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", p.topic),
+		attribute.Int("messaging.message.body.size", len(message)),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	if err := p.Initialise(ctx); err != nil {
+		log.Error(ctx, "failed to initialise kafka producer", err, log.Data{"topic": p.topic})
+		return ErrUninitialisedProducer
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic:   p.topic,
+		Value:   sarama.ByteEncoder(message),
+		Headers: append([]sarama.RecordHeader(nil), p.headers...),
+	}
+	if key != "" {
+		msg.Key = sarama.StringEncoder(key)
+	}
+	if requestID := request.GetRequestId(ctx); requestID != "" {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{
+			Key:   []byte(kafka.TraceIDHeaderKey),
+			Value: []byte(requestID),
+		})
+	}
+	otel.GetTextMapPropagator().Inject(ctx, tracing.HeadersCarrier{Headers: &msg.Headers})
+	partition, offset, err := p.producer.SendMessage(msg)
+	if err != nil {
+		log.Error(ctx, "kafka failed to deliver message", err, log.Data{"topic": p.topic})
+		return err
+	}
+	span.SetAttributes(
+		attribute.Int("messaging.kafka.destination.partition", int(partition)),
+		attribute.Int64("messaging.kafka.message.offset", offset),
+	)
+	return nil
+}
+
+// Close closes the producer and its connection to kafka, waiting for any in-flight message.
+// If the context is done before closing has finished, kafka.ErrShutdownTimedOut is returned.
+func (p *Producer) Close(ctx context.Context) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.producer == nil {
+		return nil
+	}
+
+	closed := make(chan error, 1)
+	go func() {
+		err := p.producer.Close()
+		if p.client != nil && !p.client.Closed() {
+			if closeErr := p.client.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		closed <- err
+	}()
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			log.Error(ctx, "close failed of kafka producer", err, log.Data{"topic": p.topic})
+			return err
+		}
+		log.Info(ctx, "successfully closed kafka producer", log.Data{"topic": p.topic})
+		return nil
+	case <-ctx.Done():
+		log.Warn(ctx, "shutdown context time exceeded, skipping graceful shutdown of producer", log.Data{"topic": p.topic})
+		return kafka.ErrShutdownTimedOut
+	}
+}
@@ -0,0 +1,231 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	kafka "github.com/ONSdigital/dp-kafka/v2"
+	"github.com/ONSdigital/dp-net/v2/request"
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const testTopic = "dimensions-extracted"
+
+var ctx = context.Background()
+
+// slowSyncProducer is a sarama.SyncProducer whose messages are acknowledged once released
+type slowSyncProducer struct {
+	sarama.SyncProducer
+	sending chan struct{}
+	release chan struct{}
+}
+
+func (s *slowSyncProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	close(s.sending)
+	<-s.release
+	return 0, 0, nil
+}
+
+func newTestProducer(syncProducer sarama.SyncProducer, headers map[string]string) *Producer {
+	return &Producer{
+		topic:    testTopic,
+		config:   sarama.NewConfig(),
+		headers:  recordHeaders(headers),
+		producer: syncProducer,
+		mutex:    &sync.Mutex{},
+	}
+}
+
+func TestSend(t *testing.T) {
+	Convey("Given an initialised producer", t, func() {
+		config := mocks.NewTestConfig()
+		config.Producer.Return.Successes = true
+		syncProducer := mocks.NewSyncProducer(t, config)
+		p := newTestProducer(syncProducer, nil)
+
+		Convey("When the broker acknowledges the message", func() {
+			syncProducer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+				if msg.Topic != testTopic {
+					return errors.New("unexpected topic")
+				}
+				value, err := msg.Value.Encode()
+				if err != nil {
+					return err
+				}
+				if string(value) != "message" {
+					return errors.New("unexpected value")
+				}
+				return nil
+			})
+			err := p.Send(ctx, []byte("message"))
+
+			Convey("Then no error is returned", func() {
+				So(err, ShouldBeNil)
+				So(syncProducer.Close(), ShouldBeNil)
+			})
+		})
+
+		Convey("When the broker fails to store the message", func() {
+			syncProducer.ExpectSendMessageAndFail(sarama.ErrNotEnoughReplicas)
+			err := p.Send(ctx, []byte("message"))
+
+			Convey("Then the produce error is returned", func() {
+				So(err, ShouldEqual, sarama.ErrNotEnoughReplicas)
+				So(syncProducer.Close(), ShouldBeNil)
+			})
+		})
+
+		Convey("When the context is done before the message is sent", func() {
+			cancelledCtx, cancel := context.WithCancel(ctx)
+			cancel()
+			err := p.Send(cancelledCtx, []byte("message"))
+
+			Convey("Then the message is not sent and the context error is returned", func() {
+				So(err, ShouldEqual, context.Canceled)
+				So(syncProducer.Close(), ShouldBeNil)
+			})
+		})
+
+		Convey("When a message is sent with a key", func() {
+			var key sarama.Encoder
+			syncProducer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+				key = msg.Key
+				return nil
+			})
+			err := p.SendWithKey(ctx, "instance-1", []byte("message"))
+
+			Convey("Then the key is written on the message", func() {
+				So(err, ShouldBeNil)
+				So(key, ShouldEqual, sarama.StringEncoder("instance-1"))
+			})
+		})
+
+		Convey("When a message is sent without a key", func() {
+			var key sarama.Encoder
+			syncProducer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+				key = msg.Key
+				return nil
+			})
+			err := p.Send(ctx, []byte("message"))
+
+			Convey("Then the message has no key", func() {
+				So(err, ShouldBeNil)
+				So(key, ShouldBeNil)
+			})
+		})
+
+		Convey("When a message is sent with a context carrying a request ID", func() {
+			var headers []sarama.RecordHeader
+			syncProducer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+				headers = msg.Headers
+				return nil
+			})
+			err := p.Send(request.WithRequestId(ctx, "abcdef123456"), []byte("message"))
+
+			Convey("Then the request ID is written as a message header", func() {
+				So(err, ShouldBeNil)
+				So(headers, ShouldResemble, []sarama.RecordHeader{
+					{Key: []byte(kafka.TraceIDHeaderKey), Value: []byte("abcdef123456")},
+				})
+			})
+		})
+
+		Convey("When a message is sent with tracing enabled", func() {
+			recorder := tracetest.NewSpanRecorder()
+			// the global delegates cannot be restored, so tracing is disabled again afterwards
+			defer otel.SetTracerProvider(noop.NewTracerProvider())
+			defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+			otel.SetTextMapPropagator(propagation.TraceContext{})
+			var headers []sarama.RecordHeader
+			syncProducer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+				headers = msg.Headers
+				return nil
+			})
+			err := p.Send(ctx, []byte("message"))
+
+			Convey("Then a produce span is recorded and its trace context is written as a traceparent header", func() {
+				So(err, ShouldBeNil)
+				spans := recorder.Ended()
+				So(len(spans), ShouldEqual, 1)
+				So(spans[0].Name(), ShouldEqual, "kafka produce "+testTopic)
+				So(len(headers), ShouldEqual, 1)
+				So(string(headers[0].Key), ShouldEqual, "traceparent")
+				So(string(headers[0].Value), ShouldContainSubstring, spans[0].SpanContext().TraceID().String())
+			})
+		})
+	})
+
+	Convey("Given a producer created with headers", t, func() {
+		config := mocks.NewTestConfig()
+		config.Producer.Return.Successes = true
+		syncProducer := mocks.NewSyncProducer(t, config)
+		p := newTestProducer(syncProducer, map[string]string{"content-type": "avro/binary", "b": "2"})
+
+		Convey("When a message is sent", func() {
+			var headers []sarama.RecordHeader
+			syncProducer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+				headers = msg.Headers
+				return nil
+			})
+			err := p.Send(request.WithRequestId(ctx, "abcdef123456"), []byte("message"))
+
+			Convey("Then the headers are written on the message, sorted by key, followed by the request ID", func() {
+				So(err, ShouldBeNil)
+				So(headers, ShouldResemble, []sarama.RecordHeader{
+					{Key: []byte("b"), Value: []byte("2")},
+					{Key: []byte("content-type"), Value: []byte("avro/binary")},
+					{Key: []byte(kafka.TraceIDHeaderKey), Value: []byte("abcdef123456")},
+				})
+			})
+		})
+	})
+
+	Convey("Given a producer whose messages are slowly acknowledged", t, func() {
+		syncProducer := &slowSyncProducer{sending: make(chan struct{}), release: make(chan struct{})}
+		p := newTestProducer(syncProducer, nil)
+
+		Convey("When the context is done after the message has been handed to sarama", func() {
+			sendCtx, cancel := context.WithCancel(ctx)
+			sent := make(chan error, 1)
+			go func() { sent <- p.Send(sendCtx, []byte("message")) }()
+			<-syncProducer.sending
+			cancel()
+			time.Sleep(10 * time.Millisecond)
+
+			Convey("Then the acknowledgement is still waited for", func() {
+				So(len(sent), ShouldEqual, 0)
+				close(syncProducer.release)
+				So(<-sent, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestChecker(t *testing.T) {
+	Convey("Given a producer which has not connected to kafka", t, func() {
+		p := newTestProducer(nil, nil)
+
+		Convey("When Checker is called", func() {
+			state := healthcheck.NewCheckState("Kafka Producer")
+			err := p.Checker(ctx, state)
+
+			Convey("Then the state is set to warning", func() {
+				So(err, ShouldBeNil)
+				So(state.Status(), ShouldEqual, healthcheck.StatusWarning)
+				So(state.Message(), ShouldEqual, MsgUninitialisedProducer)
+			})
+		})
+	})
+}
@@ -0,0 +1,122 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	dphttp "github.com/ONSdigital/dp-net/v2/http"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// contentType is the media type of the requests and responses of the schema registry API
+const contentType = "application/vnd.schemaregistry.v1+json"
+
+// HTTPClient is a client of the REST API of a Confluent-compatible schema registry
+type HTTPClient struct {
+	url    string
+	client dphttp.Clienter
+}
+
+// NewHTTPClient returns a client of the schema registry at the provided URL
+func NewHTTPClient(registryURL string, client dphttp.Clienter) *HTTPClient {
+	return &HTTPClient{
+		url:    strings.TrimSuffix(registryURL, "/"),
+		client: client,
+	}
+}
+
+// ResponseError is returned when the schema registry responds with an unexpected status
+type ResponseError struct {
+	StatusCode int
+	ErrorCode  int    `json:"error_code"`
+	Message    string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("schema registry responded with status %d: %d %s", e.StatusCode, e.ErrorCode, e.Message)
+}
+
+// IsTransient returns true if the error may not happen again when the request is sent again,
+// i.e. the schema registry could not be reached, timed out or failed with a server error.
+// Other errors, such as an unknown schema ID (404), are permanent.
+func IsTransient(err error) bool {
+	var respErr *ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.StatusCode >= http.StatusInternalServerError || respErr.StatusCode == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Register registers the schema under the subject, if it is not registered yet, and returns its ID
+func (c *HTTPClient) Register(ctx context.Context, subject, schema string) (int, error) {
+	body, err := json.Marshal(struct {
+		Schema string `json:"schema"`
+	}{Schema: schema})
+	if err != nil {
+		return 0, err
+	}
+
+	var resp struct {
+		ID int `json:"id"`
+	}
+	uri := fmt.Sprintf("%s/subjects/%s/versions", c.url, url.PathEscape(subject))
+	if err := c.do(ctx, http.MethodPost, uri, body, &resp); err != nil {
+		return 0, err
+	}
+	return resp.ID, nil
+}
+
+// Schema returns the schema with the provided ID
+func (c *HTTPClient) Schema(ctx context.Context, id int) (string, error) {
+	var resp struct {
+		Schema string `json:"schema"`
+	}
+	uri := fmt.Sprintf("%s/schemas/ids/%d", c.url, id)
+	if err := c.do(ctx, http.MethodGet, uri, nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.Schema, nil
+}
+
+// do sends a request to the schema registry and decodes its JSON response into v
+func (c *HTTPClient) do(ctx context.Context, method, uri string, body []byte, v interface{}) error {
+	req, err := http.NewRequest(method, uri, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", contentType)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.client.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Error(ctx, "error closing http response body", err, log.Data{"uri": uri})
+		}
+	}()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		respErr := &ResponseError{StatusCode: resp.StatusCode}
+		_ = json.Unmarshal(respBody, respErr)
+		return respErr
+	}
+	return json.Unmarshal(respBody, v)
+}
@@ -0,0 +1,110 @@
+package registry_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-extractor/registry"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	dphttp "github.com/ONSdigital/dp-net/v2/http"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// newSchemaRegistry returns a fake schema registry API with a single schema, registered under the 'test-value' subject
+func newSchemaRegistry() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/subjects/test-value/versions":
+			var body struct {
+				Schema string `json:"schema"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Schema != testSchema.Definition {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write([]byte(`{"error_code":42201,"message":"Invalid schema"}`))
+				return
+			}
+			w.Write([]byte(`{"id":7}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/schemas/ids/7":
+			json.NewEncoder(w).Encode(map[string]string{"schema": testSchema.Definition})
+		case r.Method == http.MethodGet && r.URL.Path == "/subjects":
+			w.Write([]byte(`["test-value"]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+		}
+	}))
+}
+
+func newClient(url string) *registry.HTTPClient {
+	clienter := dphttp.NewClient()
+	clienter.SetMaxRetries(0)
+	return registry.NewHTTPClient(url+"/", clienter)
+}
+
+func TestHTTPClient(t *testing.T) {
+	Convey("Given a schema registry", t, func() {
+		srv := newSchemaRegistry()
+		defer srv.Close()
+		client := newClient(srv.URL)
+
+		Convey("When a schema is registered, its ID is returned", func() {
+			id, err := client.Register(ctx, "test-value", testSchema.Definition)
+			So(err, ShouldBeNil)
+			So(id, ShouldEqual, 7)
+		})
+
+		Convey("When an invalid schema is registered, the error of the registry is returned", func() {
+			_, err := client.Register(ctx, "test-value", "invalid")
+			So(err, ShouldResemble, &registry.ResponseError{StatusCode: http.StatusUnprocessableEntity, ErrorCode: 42201, Message: "Invalid schema"})
+		})
+
+		Convey("When a schema is fetched by ID, its definition is returned", func() {
+			schema, err := client.Schema(ctx, 7)
+			So(err, ShouldBeNil)
+			So(schema, ShouldEqual, testSchema.Definition)
+		})
+
+		Convey("When an unknown schema is fetched, the error of the registry is returned", func() {
+			_, err := client.Schema(ctx, 8)
+			So(err, ShouldResemble, &registry.ResponseError{StatusCode: http.StatusNotFound, ErrorCode: 40403, Message: "Schema not found"})
+		})
+
+		Convey("When the registry is checked, the check state is OK", func() {
+			state := healthcheck.NewCheckState("Schema Registry")
+			So(client.Checker(ctx, state), ShouldBeNil)
+			So(state.Status(), ShouldEqual, healthcheck.StatusOK)
+			So(state.Message(), ShouldEqual, registry.MsgHealthy)
+		})
+	})
+
+	Convey("Given a schema registry which is not reachable, the check state is critical", t, func() {
+		srv := newSchemaRegistry()
+		srv.Close()
+
+		state := healthcheck.NewCheckState("Schema Registry")
+		So(newClient(srv.URL).Checker(ctx, state), ShouldBeNil)
+		So(state.Status(), ShouldEqual, healthcheck.StatusCritical)
+	})
+}
+
+func TestIsTransient(t *testing.T) {
+	Convey("Server errors, network errors and timeouts are transient", t, func() {
+		So(registry.IsTransient(&registry.ResponseError{StatusCode: http.StatusServiceUnavailable}), ShouldBeTrue)
+		So(registry.IsTransient(&registry.ResponseError{StatusCode: http.StatusTooManyRequests}), ShouldBeTrue)
+		So(registry.IsTransient(fmt.Errorf("failed to fetch schema 9: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")})), ShouldBeTrue)
+		So(registry.IsTransient(context.DeadlineExceeded), ShouldBeTrue)
+	})
+
+	Convey("An unknown schema ID and other client errors are permanent", t, func() {
+		So(registry.IsTransient(fmt.Errorf("failed to fetch schema 9: %w", &registry.ResponseError{StatusCode: http.StatusNotFound, ErrorCode: 40403})), ShouldBeFalse)
+		So(registry.IsTransient(&registry.ResponseError{StatusCode: http.StatusUnprocessableEntity}), ShouldBeFalse)
+		So(registry.IsTransient(registry.ErrInvalidWireFormat), ShouldBeFalse)
+	})
+}
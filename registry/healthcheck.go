@@ -0,0 +1,23 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// MsgHealthy is the check message returned when the schema registry is healthy
+const MsgHealthy = "schema registry is healthy"
+
+// Checker checks that the subjects can be listed from the schema registry and updates the provided CheckState accordingly
+func (c *HTTPClient) Checker(ctx context.Context, state *healthcheck.CheckState) error {
+	var subjects []string
+	if err := c.do(ctx, http.MethodGet, c.url+"/subjects", nil, &subjects); err != nil {
+		log.Warn(ctx, "failed to list the subjects of the schema registry", log.FormatErrors([]error{err}), log.Data{"url": c.url})
+		return state.Update(healthcheck.StatusCritical, fmt.Sprintf("failed to list the subjects of the schema registry: %s", err), 0)
+	}
+	return state.Update(healthcheck.StatusOK, MsgHealthy, 0)
+}
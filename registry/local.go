@@ -0,0 +1,64 @@
+package registry
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// errorCodeSchemaNotFound is the error code of the schema registry for an unknown schema ID
+const errorCodeSchemaNotFound = 40403
+
+// Local is an in-process schema registry, intended for tests and local development.
+// As with a schema registry, the same schema gets the same ID in every subject.
+type Local struct {
+	mutex    *sync.Mutex
+	schemas  []string
+	subjects map[string][]int
+}
+
+// NewLocal returns an empty in-process schema registry
+func NewLocal() *Local {
+	return &Local{
+		mutex:    &sync.Mutex{},
+		subjects: make(map[string][]int),
+	}
+}
+
+// Register registers the schema under the subject, if it is not registered yet, and returns its ID
+func (l *Local) Register(ctx context.Context, subject, schema string) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	id := 0
+	for i, s := range l.schemas {
+		if s == schema {
+			id = i + 1
+			break
+		}
+	}
+	if id == 0 {
+		l.schemas = append(l.schemas, schema)
+		id = len(l.schemas)
+	}
+
+	for _, subjectID := range l.subjects[subject] {
+		if subjectID == id {
+			return id, nil
+		}
+	}
+	l.subjects[subject] = append(l.subjects[subject], id)
+	return id, nil
+}
+
+// Schema returns the schema with the provided ID. As with a schema registry, a ResponseError with the
+// 404 status is returned if there is none.
+func (l *Local) Schema(ctx context.Context, id int) (string, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if id < 1 || id > len(l.schemas) {
+		return "", &ResponseError{StatusCode: http.StatusNotFound, ErrorCode: errorCodeSchemaNotFound, Message: "Schema not found"}
+	}
+	return l.schemas[id-1], nil
+}
@@ -0,0 +1,123 @@
+// Package registry encodes and decodes kafka messages with the wire format of a Confluent-compatible schema registry,
+// where each Avro payload is prefixed with a magic byte and the ID of the schema it was written with.
+package registry
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ONSdigital/dp-kafka/v2/avro"
+)
+
+// magicByte is the first byte of every message encoded with the wire format of the schema registry
+const magicByte = 0
+
+// headerLength is the length of the magic byte followed by the schema ID
+const headerLength = 5
+
+// ErrInvalidWireFormat is returned when a message is not encoded with the wire format of the schema registry
+var ErrInvalidWireFormat = errors.New("message is not encoded with the schema registry wire format")
+
+// ValueSubject returns the subject of the schemas of the message values of a topic, following the topic name strategy
+func ValueSubject(topic string) string {
+	return topic + "-value"
+}
+
+// Client registers schemas under a subject and fetches them by ID
+type Client interface {
+	Register(ctx context.Context, subject, schema string) (id int, err error)
+	Schema(ctx context.Context, id int) (schema string, err error)
+}
+
+// Codec encodes messages with the ID of their schema, registering the schema if needed, and decodes messages
+// with the schema they were written with. Schema IDs and schemas are cached, as they never change.
+type Codec struct {
+	client  Client
+	mutex   *sync.Mutex
+	ids     map[subjectSchema]int
+	schemas map[int]*avro.Schema
+}
+
+type subjectSchema struct {
+	subject string
+	schema  string
+}
+
+// NewCodec returns a Codec using the provided schema registry client
+func NewCodec(client Client) *Codec {
+	return &Codec{
+		client:  client,
+		mutex:   &sync.Mutex{},
+		ids:     make(map[subjectSchema]int),
+		schemas: make(map[int]*avro.Schema),
+	}
+}
+
+// Register registers the schema under the subject, if it is not registered yet, and returns its ID
+func (c *Codec) Register(ctx context.Context, subject string, schema *avro.Schema) (int, error) {
+	key := subjectSchema{subject: subject, schema: schema.Definition}
+
+	c.mutex.Lock()
+	id, ok := c.ids[key]
+	c.mutex.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	id, err := c.client.Register(ctx, subject, schema.Definition)
+	if err != nil {
+		return 0, fmt.Errorf("failed to register schema for subject %s: %w", subject, err)
+	}
+
+	c.mutex.Lock()
+	c.ids[key] = id
+	c.schemas[id] = schema
+	c.mutex.Unlock()
+	return id, nil
+}
+
+// Encode marshals the value with the schema and prefixes it with the magic byte and the ID of the schema
+func (c *Codec) Encode(ctx context.Context, subject string, schema *avro.Schema, value interface{}) ([]byte, error) {
+	id, err := c.Register(ctx, subject, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := schema.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	message := make([]byte, headerLength, headerLength+len(payload))
+	message[0] = magicByte
+	binary.BigEndian.PutUint32(message[1:headerLength], uint32(id))
+	return append(message, payload...), nil
+}
+
+// Decode returns the schema the message was written with, fetched from the registry by ID, and its Avro payload
+func (c *Codec) Decode(ctx context.Context, message []byte) (*avro.Schema, []byte, error) {
+	if len(message) < headerLength || message[0] != magicByte {
+		return nil, nil, ErrInvalidWireFormat
+	}
+	id := int(binary.BigEndian.Uint32(message[1:headerLength]))
+
+	c.mutex.Lock()
+	schema, ok := c.schemas[id]
+	c.mutex.Unlock()
+	if !ok {
+		definition, err := c.client.Schema(ctx, id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch schema %d: %w", id, err)
+		}
+		schema = &avro.Schema{Definition: definition}
+
+		c.mutex.Lock()
+		c.schemas[id] = schema
+		c.mutex.Unlock()
+	}
+
+	return schema, message[headerLength:], nil
+}
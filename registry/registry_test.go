@@ -0,0 +1,152 @@
+package registry_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-extractor/registry"
+	"github.com/ONSdigital/dp-kafka/v2/avro"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var ctx = context.Background()
+
+var testSchema = &avro.Schema{Definition: `{
+  "type": "record",
+  "name": "test",
+  "fields": [
+    {"name": "instance_id", "type": "string"}
+  ]
+}`}
+
+type testMessage struct {
+	InstanceID string `avro:"instance_id"`
+}
+
+// countingClient counts the calls made to the schema registry
+type countingClient struct {
+	registry.Client
+	registrations int
+	fetches       int
+}
+
+func (c *countingClient) Register(ctx context.Context, subject, schema string) (int, error) {
+	c.registrations++
+	return c.Client.Register(ctx, subject, schema)
+}
+
+func (c *countingClient) Schema(ctx context.Context, id int) (string, error) {
+	c.fetches++
+	return c.Client.Schema(ctx, id)
+}
+
+func TestCodec(t *testing.T) {
+	Convey("Given a codec using a local schema registry", t, func() {
+		local := registry.NewLocal()
+		_, err := local.Register(ctx, "other-value", `"string"`)
+		So(err, ShouldBeNil)
+		client := &countingClient{Client: local}
+		codec := registry.NewCodec(client)
+
+		Convey("When a message is encoded", func() {
+			message, err := codec.Encode(ctx, "test-value", testSchema, &testMessage{InstanceID: "123"})
+			So(err, ShouldBeNil)
+
+			Convey("Then it is prefixed with the magic byte and the ID of its schema, which is registered", func() {
+				So(message[:5], ShouldResemble, []byte{0, 0, 0, 0, 2})
+				payload, err := testSchema.Marshal(&testMessage{InstanceID: "123"})
+				So(err, ShouldBeNil)
+				So(message[5:], ShouldResemble, payload)
+
+				definition, err := local.Schema(ctx, 2)
+				So(err, ShouldBeNil)
+				So(definition, ShouldEqual, testSchema.Definition)
+			})
+
+			Convey("Then it is decoded with its schema", func() {
+				writerSchema, payload, err := codec.Decode(ctx, message)
+				So(err, ShouldBeNil)
+				So(writerSchema.Definition, ShouldEqual, testSchema.Definition)
+
+				var decoded testMessage
+				So(writerSchema.Unmarshal(payload, &decoded), ShouldBeNil)
+				So(decoded, ShouldResemble, testMessage{InstanceID: "123"})
+			})
+
+			Convey("Then encoding another message does not register the schema again", func() {
+				_, err := codec.Encode(ctx, "test-value", testSchema, &testMessage{InstanceID: "456"})
+				So(err, ShouldBeNil)
+				So(client.registrations, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When a message encoded by another codec is decoded twice", func() {
+			message, err := registry.NewCodec(local).Encode(ctx, "test-value", testSchema, &testMessage{InstanceID: "123"})
+			So(err, ShouldBeNil)
+
+			_, _, err = codec.Decode(ctx, message)
+			So(err, ShouldBeNil)
+			_, _, err = codec.Decode(ctx, message)
+			So(err, ShouldBeNil)
+
+			Convey("Then its schema is only fetched once", func() {
+				So(client.fetches, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When a message which is not encoded with the wire format is decoded", func() {
+			payload, err := testSchema.Marshal(&testMessage{InstanceID: "123"})
+			So(err, ShouldBeNil)
+			_, _, err = codec.Decode(ctx, payload)
+
+			Convey("Then ErrInvalidWireFormat is returned", func() {
+				So(err, ShouldEqual, registry.ErrInvalidWireFormat)
+			})
+		})
+
+		Convey("When a message with an unknown schema ID is decoded", func() {
+			_, _, err := codec.Decode(ctx, []byte{0, 0, 0, 0, 9, 1})
+
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "failed to fetch schema 9: schema registry responded with status 404: 40403 Schema not found")
+				So(registry.IsTransient(err), ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestLocal(t *testing.T) {
+	Convey("Given a local schema registry", t, func() {
+		local := registry.NewLocal()
+
+		Convey("The same schema gets the same ID in every subject", func() {
+			id1, err := local.Register(ctx, "a-value", testSchema.Definition)
+			So(err, ShouldBeNil)
+			id2, err := local.Register(ctx, "b-value", testSchema.Definition)
+			So(err, ShouldBeNil)
+			So(id1, ShouldEqual, 1)
+			So(id2, ShouldEqual, id1)
+		})
+
+		Convey("Different schemas get different IDs", func() {
+			id1, err := local.Register(ctx, "a-value", testSchema.Definition)
+			So(err, ShouldBeNil)
+			id2, err := local.Register(ctx, "a-value", `"string"`)
+			So(err, ShouldBeNil)
+			So(id2, ShouldNotEqual, id1)
+		})
+
+		Convey("Fetching an unknown schema returns an error", func() {
+			_, err := local.Schema(ctx, 1)
+			So(err, ShouldResemble, &registry.ResponseError{StatusCode: http.StatusNotFound, ErrorCode: 40403, Message: "Schema not found"})
+		})
+	})
+}
+
+func TestValueSubject(t *testing.T) {
+	Convey("The value subject of a topic follows the topic name strategy", t, func() {
+		So(registry.ValueSubject("dimensions-extracted"), ShouldEqual, "dimensions-extracted-value")
+	})
+}
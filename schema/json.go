@@ -0,0 +1,134 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// Kafka header describing the encoding of a message, and its supported values
+const (
+	ContentTypeHeaderKey = "content-type"
+	ContentTypeAvro      = "avro/binary"
+	ContentTypeJSON      = "application/json"
+)
+
+// JSONSchema is a JSON Schema which the JSON messages are validated against when they are marshalled or unmarshalled
+type JSONSchema struct {
+	Name       string
+	Definition string
+
+	once     sync.Once
+	compiled *jsonschema.Schema
+	err      error
+}
+
+// Marshal encodes the value as JSON and validates the result against the schema
+func (s *JSONSchema) Marshal(v interface{}) ([]byte, error) {
+	message, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Validate(message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// Unmarshal validates the JSON message against the schema and decodes it into v
+func (s *JSONSchema) Unmarshal(message []byte, v interface{}) error {
+	if err := s.Validate(message); err != nil {
+		return err
+	}
+	return json.Unmarshal(message, v)
+}
+
+// Validate returns an error describing why the JSON message is not valid against the schema, if it is not
+func (s *JSONSchema) Validate(message []byte) error {
+	schema, err := s.compile()
+	if err != nil {
+		return err
+	}
+
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(message))
+	if err != nil {
+		return err
+	}
+	return schema.Validate(instance)
+}
+
+// compile compiles the definition of the schema the first time it is used
+func (s *JSONSchema) compile() (*jsonschema.Schema, error) {
+	s.once.Do(func() {
+		doc, err := jsonschema.UnmarshalJSON(strings.NewReader(s.Definition))
+		if err != nil {
+			s.err = err
+			return
+		}
+		compiler := jsonschema.NewCompiler()
+		if s.err = compiler.AddResource(s.Name, doc); s.err != nil {
+			return
+		}
+		s.compiled, s.err = compiler.Compile(s.Name)
+	})
+	return s.compiled, s.err
+}
+
+var inputFileAvailableJSON = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "input-file-available",
+  "type": "object",
+  "required": ["file_url", "instance_id"],
+  "properties": {
+    "file_url": {"type": "string", "minLength": 1},
+    "instance_id": {"type": "string", "minLength": 1},
+    "dataset_id": {"type": "string"},
+    "edition": {"type": "string"},
+    "version": {"type": "string"},
+    "expected_checksum": {"type": "string"},
+    "content_encoding": {"type": "string"},
+    "csv_dialect": {
+      "type": "object",
+      "properties": {
+        "delimiter": {"type": "string"},
+        "comment": {"type": "string"},
+        "lazy_quotes": {"type": "boolean"},
+        "trim_leading_space": {"type": "boolean"}
+      }
+    },
+    "processing_options": {"type": ["object", "null"], "additionalProperties": {"type": "string"}}
+  }
+}`
+
+// InputFileAvailableJSONSchema is the JSON Schema for each input file that becomes available, with the same fields
+// as InputFileAvailableV2Schema. Only the file URL and instance ID are required.
+var InputFileAvailableJSONSchema = &JSONSchema{
+	Name:       "input-file-available.json",
+	Definition: inputFileAvailableJSON,
+}
+
+var dimensionsExtractedJSON = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "dimensions-extracted",
+  "type": "object",
+  "required": ["file_url", "instance_id"],
+  "properties": {
+    "file_url": {"type": "string", "minLength": 1},
+    "instance_id": {"type": "string", "minLength": 1},
+    "header_names": {"type": ["array", "null"], "items": {"type": "string"}},
+    "number_of_observations": {"type": "integer", "minimum": 0},
+    "dimension_option_counts": {"type": ["object", "null"], "additionalProperties": {"type": "string"}},
+    "file_checksum": {"type": "string"},
+    "s3_etag": {"type": "string"},
+    "extractor_version": {"type": "string"}
+  }
+}`
+
+// DimensionsExtractedJSONSchema is the JSON Schema for each dimension extracted, with the same fields as DimensionsExtractedV2Schema
+var DimensionsExtractedJSONSchema = &JSONSchema{
+	Name:       "dimensions-extracted.json",
+	Definition: dimensionsExtractedJSON,
+}
@@ -0,0 +1,81 @@
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-extractor/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type inputFileAvailable struct {
+	FileURL           string            `json:"file_url"`
+	InstanceID        string            `json:"instance_id"`
+	ProcessingOptions map[string]string `json:"processing_options"`
+}
+
+func TestInputFileAvailableJSONSchema(t *testing.T) {
+	Convey("Given a valid input file available JSON message", t, func() {
+		message := []byte(`{"file_url": "s3://bucket/file.csv", "instance_id": "123", "processing_options": {"dry_run": "true"}}`)
+
+		Convey("When it is unmarshalled, the fields are decoded", func() {
+			var event inputFileAvailable
+			So(schema.InputFileAvailableJSONSchema.Unmarshal(message, &event), ShouldBeNil)
+			So(event, ShouldResemble, inputFileAvailable{
+				FileURL:           "s3://bucket/file.csv",
+				InstanceID:        "123",
+				ProcessingOptions: map[string]string{"dry_run": "true"},
+			})
+		})
+	})
+
+	Convey("Given a JSON message without an instance ID", t, func() {
+		message := []byte(`{"file_url": "s3://bucket/file.csv"}`)
+
+		Convey("When it is unmarshalled, a validation error is returned", func() {
+			var event inputFileAvailable
+			err := schema.InputFileAvailableJSONSchema.Unmarshal(message, &event)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "instance_id")
+		})
+	})
+
+	Convey("Given a JSON message with a processing option which is not a string", t, func() {
+		message := []byte(`{"file_url": "s3://bucket/file.csv", "instance_id": "123", "processing_options": {"dry_run": true}}`)
+
+		Convey("When it is validated, a validation error is returned", func() {
+			err := schema.InputFileAvailableJSONSchema.Validate(message)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "dry_run")
+		})
+	})
+
+	Convey("Given a message which is not JSON", t, func() {
+		Convey("When it is validated, an error is returned", func() {
+			So(schema.InputFileAvailableJSONSchema.Validate([]byte("wrongMessageFormat")), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestDimensionsExtractedJSONSchema(t *testing.T) {
+	Convey("Given a dimensions extracted message", t, func() {
+		v2 := struct {
+			FileURL              string   `json:"file_url"`
+			InstanceID           string   `json:"instance_id"`
+			HeaderNames          []string `json:"header_names"`
+			NumberOfObservations int64    `json:"number_of_observations"`
+		}{FileURL: "s3://bucket/file.csv", InstanceID: "123", NumberOfObservations: 2}
+
+		Convey("When it is marshalled, the JSON message is returned", func() {
+			message, err := schema.DimensionsExtractedJSONSchema.Marshal(&v2)
+			So(err, ShouldBeNil)
+			So(string(message), ShouldEqual, `{"file_url":"s3://bucket/file.csv","instance_id":"123","header_names":null,"number_of_observations":2}`)
+		})
+
+		Convey("When it is marshalled without a file URL, a validation error is returned", func() {
+			v2.FileURL = ""
+			_, err := schema.DimensionsExtractedJSONSchema.Marshal(&v2)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "file_url")
+		})
+	})
+}
@@ -1,6 +1,10 @@
 package schema
 
-import "github.com/ONSdigital/dp-kafka/v2/avro"
+import (
+	"encoding/json"
+
+	"github.com/ONSdigital/dp-kafka/v2/avro"
+)
 
 var inputFileAvailable = `{
   "type": "record",
@@ -17,6 +21,40 @@ var InputFileAvailableSchema *avro.Schema = &avro.Schema{
 	Definition: inputFileAvailable,
 }
 
+// inputFileAvailableV2 extends inputFileAvailable with optional details of the file and of how to process it.
+// The new fields are appended after the original ones and have default values, so that producers still using
+// the first version of the schema are supported.
+var inputFileAvailableV2 = `{
+  "type": "record",
+  "name": "input-file-available",
+  "fields": [
+    {"name": "file_url", "type": "string"},
+    {"name": "instance_id", "type": "string"},
+    {"name": "dataset_id", "type": "string", "default": ""},
+    {"name": "edition", "type": "string", "default": ""},
+    {"name": "version", "type": "string", "default": ""},
+    {"name": "expected_checksum", "type": "string", "default": ""},
+    {"name": "content_encoding", "type": "string", "default": ""},
+    {"name": "csv_dialect", "type": {
+      "type": "record",
+      "name": "csv-dialect",
+      "fields": [
+        {"name": "delimiter", "type": "string", "default": ""},
+        {"name": "comment", "type": "string", "default": ""},
+        {"name": "lazy_quotes", "type": "boolean", "default": false},
+        {"name": "trim_leading_space", "type": "boolean", "default": false}
+      ]
+    }, "default": {}},
+    {"name": "processing_options", "type": {"type": "map", "values": "string"}, "default": {}}
+  ]
+}`
+
+// InputFileAvailableV2Schema is the Avro schema for each input file that becomes available,
+// including the optional details of the file and of how to process it
+var InputFileAvailableV2Schema *avro.Schema = &avro.Schema{
+	Definition: inputFileAvailableV2,
+}
+
 var dimensionsExtracted = `{
   "type": "record",
   "name": "dimensions-extracted",
@@ -30,3 +68,47 @@ var dimensionsExtracted = `{
 var DimensionsExtractedSchema *avro.Schema = &avro.Schema{
 	Definition: dimensionsExtracted,
 }
+
+// dimensionsExtractedV2 extends dimensionsExtracted with the details of the extraction. The new fields are appended
+// after the original ones and have default values, so that consumers still using the first version of the schema
+// can decode the messages. The option counts are keyed by dimension name and held as decimal strings,
+// as the avro package of dp-kafka only supports maps of strings.
+var dimensionsExtractedV2 = `{
+  "type": "record",
+  "name": "dimensions-extracted",
+  "fields": [
+    {"name": "file_url", "type": "string"},
+    {"name": "instance_id", "type": "string"},
+    {"name": "header_names", "type": {"type": "array", "items": "string"}, "default": []},
+    {"name": "number_of_observations", "type": "long", "default": 0},
+    {"name": "dimension_option_counts", "type": {"type": "map", "values": "string"}, "default": {}},
+    {"name": "file_checksum", "type": "string", "default": ""},
+    {"name": "s3_etag", "type": "string", "default": ""},
+    {"name": "extractor_version", "type": "string", "default": ""}
+  ]
+}`
+
+// DimensionsExtractedV2Schema is the Avro schema for each dimension extracted, including the header names,
+// the number of observations and of options of each dimension, the checksum and ETag of the file and the version
+// of the extractor
+var DimensionsExtractedV2Schema *avro.Schema = &avro.Schema{
+	Definition: dimensionsExtractedV2,
+}
+
+// FieldNames returns the names of the fields of the record defined by the schema
+func FieldNames(schema *avro.Schema) ([]string, error) {
+	var record struct {
+		Fields []struct {
+			Name string `json:"name"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(schema.Definition), &record); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(record.Fields))
+	for i, field := range record.Fields {
+		names[i] = field.Name
+	}
+	return names, nil
+}
@@ -0,0 +1,73 @@
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-extractor/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// dimensionsExtractedV1 is the model of a consumer which only knows the first version of the dimensions-extracted schema
+type dimensionsExtractedV1 struct {
+	FileURL    string `avro:"file_url"`
+	InstanceID string `avro:"instance_id"`
+}
+
+type dimensionsExtractedV2 struct {
+	FileURL               string            `avro:"file_url"`
+	InstanceID            string            `avro:"instance_id"`
+	HeaderNames           []string          `avro:"header_names"`
+	NumberOfObservations  int64             `avro:"number_of_observations"`
+	DimensionOptionCounts map[string]string `avro:"dimension_option_counts"`
+	FileChecksum          string            `avro:"file_checksum"`
+	S3ETag                string            `avro:"s3_etag"`
+	ExtractorVersion      string            `avro:"extractor_version"`
+}
+
+func TestDimensionsExtractedV2Schema(t *testing.T) {
+	Convey("Given a dimensions extracted message encoded with the v2 schema", t, func() {
+		v2 := dimensionsExtractedV2{
+			FileURL:               "s3://csv-exported/dir1/file.csv",
+			InstanceID:            "123",
+			HeaderNames:           []string{"V4_0", "Time", "Time"},
+			NumberOfObservations:  3,
+			DimensionOptionCounts: map[string]string{"time": "3"},
+			FileChecksum:          "8f434346648f6b96df89dda901c5176b10a6d83961dd3c1ac88b59b2dc327aa4",
+			S3ETag:                "etag",
+			ExtractorVersion:      "1.2.3",
+		}
+		message, err := schema.DimensionsExtractedV2Schema.Marshal(&v2)
+		So(err, ShouldBeNil)
+
+		Convey("Then a consumer using the v1 schema can decode it", func() {
+			var v1 dimensionsExtractedV1
+			So(schema.DimensionsExtractedSchema.Unmarshal(message, &v1), ShouldBeNil)
+			So(v1, ShouldResemble, dimensionsExtractedV1{FileURL: v2.FileURL, InstanceID: v2.InstanceID})
+		})
+
+		Convey("Then a consumer using the v2 schema decodes every field", func() {
+			var decoded dimensionsExtractedV2
+			So(schema.DimensionsExtractedV2Schema.Unmarshal(message, &decoded), ShouldBeNil)
+			So(decoded, ShouldResemble, v2)
+		})
+	})
+
+	Convey("Given a dimensions extracted message encoded with the v2 schema without the optional details", t, func() {
+		message, err := schema.DimensionsExtractedV2Schema.Marshal(&dimensionsExtractedV2{FileURL: "s3://csv-exported/dir1/file.csv", InstanceID: "123"})
+		So(err, ShouldBeNil)
+
+		Convey("Then a consumer using the v1 schema can decode it", func() {
+			var v1 dimensionsExtractedV1
+			So(schema.DimensionsExtractedSchema.Unmarshal(message, &v1), ShouldBeNil)
+			So(v1, ShouldResemble, dimensionsExtractedV1{FileURL: "s3://csv-exported/dir1/file.csv", InstanceID: "123"})
+		})
+	})
+}
+
+func TestFieldNames(t *testing.T) {
+	Convey("FieldNames returns the names of the fields of the schema, in order", t, func() {
+		fields, err := schema.FieldNames(schema.InputFileAvailableSchema)
+		So(err, ShouldBeNil)
+		So(fields, ShouldResemble, []string{"file_url", "instance_id"})
+	})
+}
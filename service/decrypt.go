@@ -0,0 +1,65 @@
+package service
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+)
+
+// encryptedChunkSize is the size of the chunks which are encrypted separately by dp-s3, with the PSK as initialisation vector
+const encryptedChunkSize = 5 * 1024 * 1024
+
+// DecodePSK decodes a hex encoded pre-shared key, as stored in vault
+func DecodePSK(encoded string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimSpace(encoded))
+}
+
+// ReadPSKFile reads and decodes the hex encoded pre-shared key stored in the file at path
+func ReadPSKFile(path string) ([]byte, error) {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecodePSK(string(encoded))
+}
+
+// NewDecryptingReader returns a reader of the content of r, encrypted with the pre-shared key the same way as
+// the files uploaded with a PSK to S3, so that they can be decrypted once downloaded
+func NewDecryptingReader(r io.Reader, psk []byte) (io.Reader, error) {
+	block, err := aes.NewCipher(psk)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{r: r, block: block, psk: psk}, nil
+}
+
+type decryptingReader struct {
+	r     io.Reader
+	block cipher.Block
+	psk   []byte
+	chunk *bytes.Reader
+	err   error
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+	for d.chunk == nil || d.chunk.Len() == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		encrypted := make([]byte, encryptedChunkSize)
+		n, err := io.ReadFull(d.r, encrypted)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		d.err = err
+		// each chunk is decrypted with a new stream, as it was encrypted. CFB is deprecated, but it is what dp-s3 encrypts with.
+		decrypted := make([]byte, n)
+		cipher.NewCFBDecrypter(d.block, d.psk).XORKeyStream(decrypted, encrypted[:n])
+		d.chunk = bytes.NewReader(decrypted)
+	}
+	return d.chunk.Read(p)
+}
@@ -0,0 +1,50 @@
+package service_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"io"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-extractor/service"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewDecryptingReader(t *testing.T) {
+	Convey("Given a file encrypted with a pre-shared key", t, func() {
+		psk, err := service.DecodePSK("000102030405060708090a0b0c0d0e0f\n")
+		So(err, ShouldBeNil)
+		block, err := aes.NewCipher(psk)
+		So(err, ShouldBeNil)
+		encrypted := make([]byte, len(validCsvContent))
+		cipher.NewCFBEncrypter(block, psk).XORKeyStream(encrypted, []byte(validCsvContent))
+
+		Convey("When it is read with the key, its content is decrypted", func() {
+			r, err := service.NewDecryptingReader(bytes.NewReader(encrypted), psk)
+			So(err, ShouldBeNil)
+			content, err := io.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, validCsvContent)
+		})
+
+		Convey("When a file larger than a chunk is read with the key, each of its chunks is decrypted", func() {
+			const chunkSize = 5 * 1024 * 1024
+			content := bytes.Repeat([]byte("0123456789"), chunkSize/10+100)
+			encrypted := make([]byte, len(content))
+			cipher.NewCFBEncrypter(block, psk).XORKeyStream(encrypted[:chunkSize], content[:chunkSize])
+			cipher.NewCFBEncrypter(block, psk).XORKeyStream(encrypted[chunkSize:], content[chunkSize:])
+
+			r, err := service.NewDecryptingReader(bytes.NewReader(encrypted), psk)
+			So(err, ShouldBeNil)
+			decrypted, err := io.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(bytes.Equal(decrypted, content), ShouldBeTrue)
+		})
+
+		Convey("When the key does not have a valid size, an error is returned", func() {
+			_, err := service.NewDecryptingReader(bytes.NewReader(encrypted), psk[:5])
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,14 @@
+//go:build linux || darwin
+
+package service
+
+import "syscall"
+
+// availableDiskSpace returns the number of bytes available in the filesystem of the directory, if it can be determined
+func availableDiskSpace(dir string) (uint64, bool) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, false
+	}
+	return stat.Bavail * uint64(stat.Bsize), true
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+)
+
+// downloadFilePattern is the pattern of the names of the temporary files objects are downloaded to
+const downloadFilePattern = "dimension-extractor-*.csv"
+
+var errInsufficientDiskSpace = errors.New("insufficient disk space to download the file")
+
+// spooledFile is an object downloaded to a temporary file, which can be read again from any offset.
+// The temporary file is removed once it is closed.
+type spooledFile struct {
+	*os.File
+}
+
+func (f *spooledFile) Close() error {
+	err := f.File.Close()
+	if removeErr := os.Remove(f.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// downloadsInParallel returns whether the unencrypted file is downloaded in concurrent byte ranges, rather than streamed.
+// Only files larger than a part, whose ETag ensures that all the parts belong to the same version, are.
+func (s *S3Source) downloadsInParallel(file *FileInfo) bool {
+	return s.DownloadConcurrency > 1 && s.DownloadPartSize > 0 && file.ETag != "" && file.Size > s.DownloadPartSize
+}
+
+// download fetches the object in concurrent byte ranges to a temporary file, and returns it positioned at its start
+func (s *S3Source) download(ctx context.Context, client S3Client, key string, file *FileInfo) (io.ReadCloser, error) {
+	dir := s.DownloadDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	logData := log.Data{"s3_url": file.URL, "size": file.Size, "dir": dir, "concurrency": s.DownloadConcurrency, "part_size": s.DownloadPartSize}
+
+	if available, ok := availableDiskSpace(dir); ok && available < uint64(file.Size) {
+		err := fmt.Errorf("%w: %d bytes required, %d bytes available in %s", errInsufficientDiskSpace, file.Size, available, dir)
+		log.Error(ctx, "unable to download csv file", err, logData)
+		return nil, classify(ErrorClassFileSource, err)
+	}
+	f, err := os.CreateTemp(dir, downloadFilePattern)
+	if err != nil {
+		log.Error(ctx, "unable to create file to download csv file to", err, logData)
+		return nil, classify(ErrorClassFileSource, err)
+	}
+	spooled := &spooledFile{f}
+	logData["path"] = f.Name()
+
+	downloadCtx, span := tracer.Start(ctx, "s3 download object", trace.WithAttributes(
+		attribute.String("s3.key", key),
+		attribute.Int64("s3.size", file.Size),
+	))
+	start := time.Now()
+	err = s.downloadParts(downloadCtx, client, key, file, f)
+	endSpan(span, err)
+	if err != nil {
+		spooled.Close()
+		log.Error(ctx, "encountered error downloading csv file", err, logData)
+		return nil, classify(ErrorClassS3, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		spooled.Close()
+		return nil, classify(ErrorClassFileSource, err)
+	}
+
+	logData["duration"] = time.Since(start).String()
+	log.Info(ctx, "csv file downloaded", logData)
+	return spooled, nil
+}
+
+// downloadParts writes each part of the object to the file, with up to DownloadConcurrency parts downloaded at the same time.
+// The first error cancels the download of the remaining parts.
+func (s *S3Source) downloadParts(ctx context.Context, client S3Client, key string, file *FileInfo, f *os.File) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parts := (file.Size + s.DownloadPartSize - 1) / s.DownloadPartSize
+	offsets := make(chan int64)
+	errs := make(chan error, s.DownloadConcurrency)
+	var wg sync.WaitGroup
+	for range min(int64(s.DownloadConcurrency), parts) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for offset := range offsets {
+				if err := s.downloadPart(ctx, client, key, file, f, offset); err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+
+send:
+	for offset := int64(0); offset < file.Size; offset += s.DownloadPartSize {
+		select {
+		case offsets <- offset:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(offsets)
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// downloadPart writes the part of the object at the offset to the file, retrying up to MaxReadRetries times
+// with the same backoff as resumed reads, unless the failure is permanent
+func (s *S3Source) downloadPart(ctx context.Context, client S3Client, key string, file *FileInfo, f *os.File, offset int64) error {
+	length := min(s.DownloadPartSize, file.Size-offset)
+	for attempt := 0; ; attempt++ {
+		err := copyPart(ctx, client, key, file.ETag, f, offset, length)
+		if err == nil || attempt >= s.MaxReadRetries || ctx.Err() != nil || isPermanentReadError(err) {
+			return err
+		}
+		log.Warn(ctx, "failed to download part of s3 object, retrying", log.FormatErrors([]error{err}), log.Data{"s3_key": key, "offset": offset, "attempt": attempt + 1})
+		if !waitReadRetry(ctx, s.ReadRetryBackoff, attempt+1) {
+			return err
+		}
+	}
+}
+
+func copyPart(ctx context.Context, client S3Client, key, eTag string, f *os.File, offset, length int64) error {
+	body, err := client.GetRange(ctx, key, eTag, offset, length)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	n, err := io.Copy(io.NewOffsetWriter(f, offset), io.LimitReader(body, length))
+	if err != nil {
+		return fmt.Errorf("failed to download s3 object range at offset %d: %w", offset, err)
+	}
+	if n != length {
+		return fmt.Errorf("s3 object range at offset %d is %d bytes, expected %d", offset, n, length)
+	}
+	return nil
+}
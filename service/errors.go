@@ -0,0 +1,79 @@
+package service
+
+// RetryableError wraps an error which is expected to be transient,
+// so that handling the same message again may succeed
+type RetryableError struct {
+	Err error
+}
+
+func (e *RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports that the message which caused this error can be handled again
+func (e *RetryableError) Retryable() bool {
+	return true
+}
+
+// RowError wraps an error caused by a row of the csv file. Row is the line of the file at which the row starts.
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Classes of error, used to label failures in metrics
+const (
+	ErrorClassMessage    = "message"
+	ErrorClassConfig     = "config"
+	ErrorClassVault      = "vault"
+	ErrorClassS3         = "s3"
+	ErrorClassFileSource = "file_source"
+	ErrorClassFileCheck  = "file_check"
+	ErrorClassDatasetAPI = "dataset_api"
+	ErrorClassCSV        = "csv"
+	ErrorClassChecksum   = "checksum"
+	ErrorClassKafka      = "kafka"
+	ErrorClassRegistry   = "schema_registry"
+)
+
+// ClassifiedError wraps an error with the class of failure which caused it
+type ClassifiedError struct {
+	Class string
+	Err   error
+}
+
+func (e *ClassifiedError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *ClassifiedError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorClass returns the class of failure which caused this error
+func (e *ClassifiedError) ErrorClass() string {
+	return e.Class
+}
+
+// classify wraps err with the provided class, unless it is nil
+func classify(class string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ClassifiedError{Class: class, Err: err}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+)
+
+// Processing options which can be set on an input-file-available event
+const (
+	// ProcessingOptionDryRun extracts the dimensions without sending them to the dataset API nor producing a message
+	ProcessingOptionDryRun = "dry_run"
+	// ProcessingOptionStrict rejects files without observations or without a column for each dimension of the instance
+	ProcessingOptionStrict = "strict"
+)
+
+// Content encodings supported for the csv file
+const (
+	ContentEncodingIdentity = "identity"
+	ContentEncodingGzip     = "gzip"
+)
+
+// CSVDialect represents a kafka avro model for the format of the csv file. Empty fields keep the default format.
+type CSVDialect struct {
+	Delimiter        string `avro:"delimiter" json:"delimiter"`
+	Comment          string `avro:"comment" json:"comment"`
+	LazyQuotes       bool   `avro:"lazy_quotes" json:"lazy_quotes"`
+	TrimLeadingSpace bool   `avro:"trim_leading_space" json:"trim_leading_space"`
+}
+
+// newReader returns a csv reader for the dialect
+func (d CSVDialect) newReader(r io.Reader) (*csv.Reader, error) {
+	csvReader := csv.NewReader(r)
+	csvReader.LazyQuotes = d.LazyQuotes
+	csvReader.TrimLeadingSpace = d.TrimLeadingSpace
+
+	if d.Delimiter != "" {
+		delimiter, err := singleRune("delimiter", d.Delimiter)
+		if err != nil {
+			return nil, err
+		}
+		csvReader.Comma = delimiter
+	}
+	if d.Comment != "" {
+		comment, err := singleRune("comment", d.Comment)
+		if err != nil {
+			return nil, err
+		}
+		csvReader.Comment = comment
+	}
+	return csvReader, nil
+}
+
+func singleRune(name, value string) (rune, error) {
+	r, size := utf8.DecodeRuneInString(value)
+	if size != len(value) || r == utf8.RuneError {
+		return 0, fmt.Errorf("csv dialect %s must be a single character, got '%s'", name, value)
+	}
+	return r, nil
+}
+
+// DryRun returns whether the dimensions must be extracted without being sent to the dataset API
+func (inputFileAvailable *InputFileAvailable) DryRun() bool {
+	return inputFileAvailable.option(ProcessingOptionDryRun)
+}
+
+// Strict returns whether files without observations or without a column for each dimension of the instance are rejected
+func (inputFileAvailable *InputFileAvailable) Strict() bool {
+	return inputFileAvailable.option(ProcessingOptionStrict)
+}
+
+// option returns whether the boolean processing option is set. Invalid values are ignored.
+func (inputFileAvailable *InputFileAvailable) option(name string) bool {
+	enabled, _ := strconv.ParseBool(inputFileAvailable.ProcessingOptions[name])
+	return enabled
+}
+
+// checkInstance returns an error if the dataset ID, edition or version of the event, when provided,
+// differ from those of the instance. Those which are not set on the instance yet are not checked.
+func (inputFileAvailable *InputFileAvailable) checkInstance(instance dataset.Instance) error {
+	if id := instance.Links.Dataset.ID; inputFileAvailable.DatasetID != "" && id != "" && inputFileAvailable.DatasetID != id {
+		return fmt.Errorf("event dataset ID '%s' does not match the instance dataset ID '%s'", inputFileAvailable.DatasetID, id)
+	}
+	if inputFileAvailable.Edition != "" && instance.Edition != "" && inputFileAvailable.Edition != instance.Edition {
+		return fmt.Errorf("event edition '%s' does not match the instance edition '%s'", inputFileAvailable.Edition, instance.Edition)
+	}
+	if inputFileAvailable.Version != "" && instance.Version.Version != 0 && inputFileAvailable.Version != strconv.Itoa(instance.Version.Version) {
+		return fmt.Errorf("event version '%s' does not match the instance version '%d'", inputFileAvailable.Version, instance.Version.Version)
+	}
+	return nil
+}
+
+// verifyChecksum returns an error if the expected checksum of the event, when provided, differs from the checksum of the file
+func (inputFileAvailable *InputFileAvailable) verifyChecksum(checksum string) error {
+	if inputFileAvailable.ExpectedChecksum == "" || strings.EqualFold(inputFileAvailable.ExpectedChecksum, checksum) {
+		return nil
+	}
+	return fmt.Errorf("file checksum '%s' does not match the expected checksum '%s'", checksum, inputFileAvailable.ExpectedChecksum)
+}
+
+// decodeContent returns a reader of the decoded content of the file
+func decodeContent(file io.Reader, contentEncoding string) (io.Reader, error) {
+	switch strings.ToLower(contentEncoding) {
+	case "", ContentEncodingIdentity:
+		return file, nil
+	case ContentEncodingGzip:
+		return gzip.NewReader(file)
+	default:
+		return nil, fmt.Errorf("content encoding not supported: '%s' Supported encodings: %v", contentEncoding, []string{ContentEncodingIdentity, ContentEncodingGzip})
+	}
+}
+
+// checkStrict returns an error if the csv file has no observations, or no column for a dimension of the instance
+func checkStrict(instance dataset.Instance, headerRow []string, numberOfObservations int) error {
+	if numberOfObservations == 0 {
+		return errors.New("the csv file has no observations")
+	}
+
+	columns := make(map[string]bool, len(headerRow))
+	for _, column := range headerRow {
+		columns[strings.ToLower(column)] = true
+	}
+	for _, dim := range instance.Dimensions {
+		if !columns[strings.ToLower(dim.Name)] {
+			return fmt.Errorf("the csv file has no column for dimension '%s'", dim.Name)
+		}
+	}
+	return nil
+}
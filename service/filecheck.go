@@ -0,0 +1,91 @@
+package service
+
+import (
+	"fmt"
+	"mime"
+	"slices"
+	"strings"
+)
+
+// Checks which a file can fail before it is parsed
+const (
+	FileCheckMaxSize     = "max_size"
+	FileCheckEmpty       = "empty"
+	FileCheckContentType = "content_type"
+	FileCheckMetadata    = "metadata"
+)
+
+// FileCheckError reports that the file of an event failed one of the checks evaluated before it is parsed
+type FileCheckError struct {
+	Check  string
+	Reason string
+}
+
+func (e *FileCheckError) Error() string {
+	return fmt.Sprintf("file rejected by %s check: %s", e.Check, e.Reason)
+}
+
+// FileChecks are the checks the file of an event must pass before it is parsed. The zero value checks nothing.
+type FileChecks struct {
+	// MaxSize is the maximum size of the file in bytes, if positive
+	MaxSize int64
+	// RejectEmpty rejects files without content
+	RejectEmpty bool
+	// AllowedContentTypes are the media types the content type of the file may have, ignoring its parameters, if any
+	AllowedContentTypes []string
+	// RequiredMetadata are the keys of the metadata the file must have
+	RequiredMetadata []string
+}
+
+// check returns a FileCheckError if the file fails one of the checks. The size is only checked if it is known.
+func (checks FileChecks) check(file *FileInfo) error {
+	if err := checks.checkSize(file); err != nil {
+		return err
+	}
+
+	if len(checks.AllowedContentTypes) > 0 {
+		mediaType, _, err := mime.ParseMediaType(file.ContentType)
+		if err != nil || !slices.ContainsFunc(checks.AllowedContentTypes, func(allowed string) bool {
+			return strings.EqualFold(allowed, mediaType)
+		}) {
+			return &FileCheckError{
+				Check:  FileCheckContentType,
+				Reason: fmt.Sprintf("content type '%s' is not one of %v", file.ContentType, checks.AllowedContentTypes),
+			}
+		}
+	}
+
+	for _, key := range checks.RequiredMetadata {
+		if !hasMetadata(file.Metadata, key) {
+			return &FileCheckError{Check: FileCheckMetadata, Reason: fmt.Sprintf("metadata '%s' is missing", key)}
+		}
+	}
+	return nil
+}
+
+// checkSize returns a FileCheckError if the file is larger than the maximum size or empty, if its size is known
+func (checks FileChecks) checkSize(file *FileInfo) error {
+	if file.Size < 0 {
+		return nil
+	}
+	if checks.MaxSize > 0 && file.Size > checks.MaxSize {
+		return &FileCheckError{
+			Check:  FileCheckMaxSize,
+			Reason: fmt.Sprintf("size of %d bytes exceeds the maximum of %d bytes", file.Size, checks.MaxSize),
+		}
+	}
+	if checks.RejectEmpty && file.Size == 0 {
+		return &FileCheckError{Check: FileCheckEmpty, Reason: "the file is empty"}
+	}
+	return nil
+}
+
+// hasMetadata returns whether the metadata has a non-empty value for the key. Keys are case-insensitive, as S3 lowercases them.
+func hasMetadata(metadata map[string]string, key string) bool {
+	for k, v := range metadata {
+		if strings.EqualFold(k, key) && v != "" {
+			return true
+		}
+	}
+	return false
+}
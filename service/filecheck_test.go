@@ -0,0 +1,112 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-extractor/service"
+	"github.com/ONSdigital/dp-dimension-extractor/service/mock"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFileChecks(t *testing.T) {
+	Convey("Given a service checking the files before they are parsed", t, func() {
+		head := &s3.HeadObjectOutput{
+			ETag:          aws.String(validETag),
+			ContentLength: aws.Int64(int64(len(validCsvContent))),
+			ContentType:   aws.String("text/csv; charset=utf-8"),
+			Metadata:      map[string]string{"collection-id": "collection"},
+		}
+		mockDatasetClient := &mock.DatasetClientMock{
+			GetInstanceFunc:            mockGetInstanceFunc,
+			PostInstanceDimensionsFunc: mockPostInstanceFunc,
+			PutInstanceDataFunc:        mockPutInstanceFunc,
+		}
+		mockS3Client := &mock.S3ClientMock{
+			GetFunc: mockGetFunc,
+			HeadFunc: func(ctx context.Context, key string) (*s3.HeadObjectOutput, error) {
+				return head, nil
+			},
+		}
+		svc := &service.Service{
+			AuthToken:                  validAuthToken,
+			DimensionExtractedProducer: &mock.KafkaProducerMock{SendWithKeyFunc: mockSendFunc},
+			EncryptionDisabled:         true,
+			DatasetClient:              mockDatasetClient,
+			S3Clients:                  map[string]service.S3Client{validBucket: mockS3Client},
+			FileChecks: service.FileChecks{
+				MaxSize:             1000,
+				RejectEmpty:         true,
+				AllowedContentTypes: []string{"text/csv", "application/octet-stream"},
+				RequiredMetadata:    []string{"Collection-ID"},
+			},
+		}
+		event := &service.InputFileAvailable{FileURL: validFileURL, InstanceID: validInstanceID}
+
+		rejectedBy := func(check, reason string) {
+			_, err := svc.HandleEvent(ctx, event)
+			So(err, ShouldResemble, &service.ClassifiedError{
+				Class: service.ErrorClassFileCheck,
+				Err:   &service.FileCheckError{Check: check, Reason: reason},
+			})
+			So(mockS3Client.GetCalls(), ShouldBeEmpty)
+			So(mockDatasetClient.PostInstanceDimensionsCalls(), ShouldBeEmpty)
+		}
+
+		Convey("When the file passes every check, its dimensions are extracted", func() {
+			_, err := svc.HandleEvent(ctx, event)
+			So(err, ShouldBeNil)
+			So(mockDatasetClient.PostInstanceDimensionsCalls(), ShouldHaveLength, 3)
+		})
+
+		Convey("When the file is larger than the maximum size, it is rejected without being read", func() {
+			head.ContentLength = aws.Int64(1001)
+			rejectedBy(service.FileCheckMaxSize, "size of 1001 bytes exceeds the maximum of 1000 bytes")
+		})
+
+		Convey("When the file is empty, it is rejected without being read", func() {
+			head.ContentLength = aws.Int64(0)
+			rejectedBy(service.FileCheckEmpty, "the file is empty")
+		})
+
+		Convey("When the content type of the file is not allowed, it is rejected without being read", func() {
+			head.ContentType = aws.String("application/pdf")
+			rejectedBy(service.FileCheckContentType, "content type 'application/pdf' is not one of [text/csv application/octet-stream]")
+		})
+
+		Convey("When the file lacks required metadata, it is rejected without being read", func() {
+			head.Metadata = map[string]string{"other": "value"}
+			rejectedBy(service.FileCheckMetadata, "metadata 'Collection-ID' is missing")
+		})
+
+		Convey("When a file which fails a check is validated, the failure is reported as a validation error", func() {
+			head.ContentType = aws.String("application/pdf")
+
+			report, err := svc.Validate(ctx, event)
+			So(err, ShouldBeNil)
+			So(report.Valid, ShouldBeFalse)
+			So(report.Errors, ShouldResemble, []service.ValidationError{{
+				Class:   service.ErrorClassFileCheck,
+				Message: "file rejected by content_type check: content type 'application/pdf' is not one of [text/csv application/octet-stream]",
+			}})
+		})
+
+		Convey("When the size of the file is only returned once it is opened, it is checked before the file is parsed", func() {
+			head.ContentLength = nil
+			mockS3Client.GetFunc = func(ctx context.Context, key string) (io.ReadCloser, *int64, error) {
+				return io.NopCloser(bytes.NewReader(nil)), aws.Int64(0), nil
+			}
+
+			_, err := svc.HandleEvent(ctx, event)
+			var checkErr *service.FileCheckError
+			So(errors.As(err, &checkErr), ShouldBeTrue)
+			So(checkErr.Check, ShouldEqual, service.FileCheckEmpty)
+			So(mockDatasetClient.PostInstanceDimensionsCalls(), ShouldBeEmpty)
+		})
+	})
+}
@@ -2,10 +2,13 @@ package service
 
 import (
 	"io"
+	"time"
 
 	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	"github.com/ONSdigital/dp-dimension-extractor/datasetapi"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
-	kafka "github.com/ONSdigital/dp-kafka/v2"
+	"github.com/ONSdigital/dp-kafka/v2/avro"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"golang.org/x/net/context"
 )
 
@@ -13,6 +16,9 @@ import (
 //go:generate moq -out ./mock/s3.go -pkg mock . S3Client
 //go:generate moq -out ./mock/dataset.go -pkg mock . DatasetClient
 //go:generate moq -out ./mock/kafka.go -pkg mock . KafkaProducer
+//go:generate moq -out ./mock/metrics.go -pkg mock . Metrics
+//go:generate moq -out ./mock/processed_events.go -pkg mock . ProcessedEventStore
+//go:generate moq -out ./mock/schema_registry.go -pkg mock . SchemaRegistry
 
 // VaultClient is an interface to represent methods called to action upon Vault
 type VaultClient interface {
@@ -23,6 +29,8 @@ type VaultClient interface {
 type S3Client interface {
 	Get(ctx context.Context, key string) (io.ReadCloser, *int64, error)
 	GetWithPSK(ctx context.Context, key string, psk []byte) (io.ReadCloser, *int64, error)
+	GetRange(ctx context.Context, key, eTag string, offset, length int64) (io.ReadCloser, error)
+	Head(ctx context.Context, key string) (*s3.HeadObjectOutput, error)
 	Checker(ctx context.Context, state *healthcheck.CheckState) error
 }
 
@@ -31,10 +39,36 @@ type DatasetClient interface {
 	GetInstance(ctx context.Context, userAuthToken, serviceAuthToken, collectionID, instanceID, ifMatch string) (m dataset.Instance, eTag string, err error)
 	PostInstanceDimensions(ctx context.Context, serviceAuthToken, instanceID string, data dataset.OptionPost, ifMatch string) (eTag string, err error)
 	PutInstanceData(ctx context.Context, serviceAuthToken, instanceID string, data dataset.JobInstance, ifMatch string) (eTag string, err error)
+	PutInstanceFailed(ctx context.Context, serviceAuthToken, instanceID string, failure datasetapi.ImportFailure, ifMatch string) (eTag string, err error)
 }
 
-// KafkaProducer is an interface to represent methods called to action upon Kafka to produce messages
+// KafkaProducer is an interface to represent methods called to action upon Kafka to produce messages.
+// Messages with the same key are sent to the same partition.
+// SendWithKey only returns once the outcome of the delivery of the message is known: it returns an error if the message was not delivered.
 type KafkaProducer interface {
-	Channels() *kafka.ProducerChannels
+	SendWithKey(ctx context.Context, key string, message []byte) error
 	Close(ctx context.Context) (err error)
 }
+
+// Metrics is an interface to represent methods called to record metrics about the extraction of dimensions,
+// so that the service is not coupled to any metrics backend
+type Metrics interface {
+	RowsScanned(count int)
+	OptionsPosted(count int)
+	DatasetAPIRequest(endpoint, status string, duration time.Duration)
+	BytesRead(source string, count int)
+}
+
+// ProcessedEventStore is an interface to represent methods called to record the events which have been processed,
+// so that repeated events can be skipped
+type ProcessedEventStore interface {
+	Seen(ctx context.Context, key string) (bool, error)
+	Record(ctx context.Context, key string) error
+}
+
+// SchemaRegistry is an interface to represent methods called to encode and decode messages
+// with the wire format of a schema registry, where the payload is prefixed with the ID of its schema
+type SchemaRegistry interface {
+	Encode(ctx context.Context, subject string, schema *avro.Schema, value interface{}) ([]byte, error)
+	Decode(ctx context.Context, message []byte) (writerSchema *avro.Schema, payload []byte, err error)
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"io"
+	"time"
+)
+
+// nopMetrics is used when the service is not given a metrics recorder
+type nopMetrics struct{}
+
+func (nopMetrics) RowsScanned(count int)                                             {}
+func (nopMetrics) OptionsPosted(count int)                                           {}
+func (nopMetrics) DatasetAPIRequest(endpoint, status string, duration time.Duration) {}
+func (nopMetrics) BytesRead(source string, count int)                                {}
+
+// countingReadCloser reports the number of bytes returned by each Read
+type countingReadCloser struct {
+	io.ReadCloser
+	count func(int)
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 {
+		c.count(n)
+	}
+	return n, err
+}
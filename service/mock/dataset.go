@@ -5,6 +5,7 @@ package mock
 
 import (
 	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	"github.com/ONSdigital/dp-dimension-extractor/datasetapi"
 	"github.com/ONSdigital/dp-dimension-extractor/service"
 	"golang.org/x/net/context"
 	"sync"
@@ -29,6 +30,9 @@ var _ service.DatasetClient = &DatasetClientMock{}
 //			PutInstanceDataFunc: func(ctx context.Context, serviceAuthToken string, instanceID string, data dataset.JobInstance, ifMatch string) (string, error) {
 //				panic("mock out the PutInstanceData method")
 //			},
+//			PutInstanceFailedFunc: func(ctx context.Context, serviceAuthToken string, instanceID string, failure datasetapi.ImportFailure, ifMatch string) (string, error) {
+//				panic("mock out the PutInstanceFailed method")
+//			},
 //		}
 //
 //		// use mockedDatasetClient in code that requires service.DatasetClient
@@ -45,6 +49,9 @@ type DatasetClientMock struct {
 	// PutInstanceDataFunc mocks the PutInstanceData method.
 	PutInstanceDataFunc func(ctx context.Context, serviceAuthToken string, instanceID string, data dataset.JobInstance, ifMatch string) (string, error)
 
+	// PutInstanceFailedFunc mocks the PutInstanceFailed method.
+	PutInstanceFailedFunc func(ctx context.Context, serviceAuthToken string, instanceID string, failure datasetapi.ImportFailure, ifMatch string) (string, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// GetInstance holds details about calls to the GetInstance method.
@@ -88,10 +95,24 @@ type DatasetClientMock struct {
 			// IfMatch is the ifMatch argument value.
 			IfMatch string
 		}
+		// PutInstanceFailed holds details about calls to the PutInstanceFailed method.
+		PutInstanceFailed []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ServiceAuthToken is the serviceAuthToken argument value.
+			ServiceAuthToken string
+			// InstanceID is the instanceID argument value.
+			InstanceID string
+			// Failure is the failure argument value.
+			Failure datasetapi.ImportFailure
+			// IfMatch is the ifMatch argument value.
+			IfMatch string
+		}
 	}
 	lockGetInstance            sync.RWMutex
 	lockPostInstanceDimensions sync.RWMutex
 	lockPutInstanceData        sync.RWMutex
+	lockPutInstanceFailed      sync.RWMutex
 }
 
 // GetInstance calls GetInstanceFunc.
@@ -241,3 +262,51 @@ func (mock *DatasetClientMock) PutInstanceDataCalls() []struct {
 	mock.lockPutInstanceData.RUnlock()
 	return calls
 }
+
+// PutInstanceFailed calls PutInstanceFailedFunc.
+func (mock *DatasetClientMock) PutInstanceFailed(ctx context.Context, serviceAuthToken string, instanceID string, failure datasetapi.ImportFailure, ifMatch string) (string, error) {
+	if mock.PutInstanceFailedFunc == nil {
+		panic("DatasetClientMock.PutInstanceFailedFunc: method is nil but DatasetClient.PutInstanceFailed was just called")
+	}
+	callInfo := struct {
+		Ctx              context.Context
+		ServiceAuthToken string
+		InstanceID       string
+		Failure          datasetapi.ImportFailure
+		IfMatch          string
+	}{
+		Ctx:              ctx,
+		ServiceAuthToken: serviceAuthToken,
+		InstanceID:       instanceID,
+		Failure:          failure,
+		IfMatch:          ifMatch,
+	}
+	mock.lockPutInstanceFailed.Lock()
+	mock.calls.PutInstanceFailed = append(mock.calls.PutInstanceFailed, callInfo)
+	mock.lockPutInstanceFailed.Unlock()
+	return mock.PutInstanceFailedFunc(ctx, serviceAuthToken, instanceID, failure, ifMatch)
+}
+
+// PutInstanceFailedCalls gets all the calls that were made to PutInstanceFailed.
+// Check the length with:
+//
+//	len(mockedDatasetClient.PutInstanceFailedCalls())
+func (mock *DatasetClientMock) PutInstanceFailedCalls() []struct {
+	Ctx              context.Context
+	ServiceAuthToken string
+	InstanceID       string
+	Failure          datasetapi.ImportFailure
+	IfMatch          string
+} {
+	var calls []struct {
+		Ctx              context.Context
+		ServiceAuthToken string
+		InstanceID       string
+		Failure          datasetapi.ImportFailure
+		IfMatch          string
+	}
+	mock.lockPutInstanceFailed.RLock()
+	calls = mock.calls.PutInstanceFailed
+	mock.lockPutInstanceFailed.RUnlock()
+	return calls
+}
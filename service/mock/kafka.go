@@ -5,7 +5,6 @@ package mock
 
 import (
 	"github.com/ONSdigital/dp-dimension-extractor/service"
-	kafka "github.com/ONSdigital/dp-kafka/v2"
 	"golang.org/x/net/context"
 	"sync"
 )
@@ -20,12 +19,12 @@ var _ service.KafkaProducer = &KafkaProducerMock{}
 //
 //		// make and configure a mocked service.KafkaProducer
 //		mockedKafkaProducer := &KafkaProducerMock{
-//			ChannelsFunc: func() *kafka.ProducerChannels {
-//				panic("mock out the Channels method")
-//			},
 //			CloseFunc: func(ctx context.Context) error {
 //				panic("mock out the Close method")
 //			},
+//			SendWithKeyFunc: func(ctx context.Context, key string, message []byte) error {
+//				panic("mock out the SendWithKey method")
+//			},
 //		}
 //
 //		// use mockedKafkaProducer in code that requires service.KafkaProducer
@@ -33,52 +32,31 @@ var _ service.KafkaProducer = &KafkaProducerMock{}
 //
 //	}
 type KafkaProducerMock struct {
-	// ChannelsFunc mocks the Channels method.
-	ChannelsFunc func() *kafka.ProducerChannels
-
 	// CloseFunc mocks the Close method.
 	CloseFunc func(ctx context.Context) error
 
+	// SendWithKeyFunc mocks the SendWithKey method.
+	SendWithKeyFunc func(ctx context.Context, key string, message []byte) error
+
 	// calls tracks calls to the methods.
 	calls struct {
-		// Channels holds details about calls to the Channels method.
-		Channels []struct {
-		}
 		// Close holds details about calls to the Close method.
 		Close []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
+		// SendWithKey holds details about calls to the SendWithKey method.
+		SendWithKey []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+			// Message is the message argument value.
+			Message []byte
+		}
 	}
-	lockChannels sync.RWMutex
-	lockClose    sync.RWMutex
-}
-
-// Channels calls ChannelsFunc.
-func (mock *KafkaProducerMock) Channels() *kafka.ProducerChannels {
-	if mock.ChannelsFunc == nil {
-		panic("KafkaProducerMock.ChannelsFunc: method is nil but KafkaProducer.Channels was just called")
-	}
-	callInfo := struct {
-	}{}
-	mock.lockChannels.Lock()
-	mock.calls.Channels = append(mock.calls.Channels, callInfo)
-	mock.lockChannels.Unlock()
-	return mock.ChannelsFunc()
-}
-
-// ChannelsCalls gets all the calls that were made to Channels.
-// Check the length with:
-//
-//	len(mockedKafkaProducer.ChannelsCalls())
-func (mock *KafkaProducerMock) ChannelsCalls() []struct {
-} {
-	var calls []struct {
-	}
-	mock.lockChannels.RLock()
-	calls = mock.calls.Channels
-	mock.lockChannels.RUnlock()
-	return calls
+	lockClose       sync.RWMutex
+	lockSendWithKey sync.RWMutex
 }
 
 // Close calls CloseFunc.
@@ -112,3 +90,43 @@ func (mock *KafkaProducerMock) CloseCalls() []struct {
 	mock.lockClose.RUnlock()
 	return calls
 }
+
+// SendWithKey calls SendWithKeyFunc.
+func (mock *KafkaProducerMock) SendWithKey(ctx context.Context, key string, message []byte) error {
+	if mock.SendWithKeyFunc == nil {
+		panic("KafkaProducerMock.SendWithKeyFunc: method is nil but KafkaProducer.SendWithKey was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Key     string
+		Message []byte
+	}{
+		Ctx:     ctx,
+		Key:     key,
+		Message: message,
+	}
+	mock.lockSendWithKey.Lock()
+	mock.calls.SendWithKey = append(mock.calls.SendWithKey, callInfo)
+	mock.lockSendWithKey.Unlock()
+	return mock.SendWithKeyFunc(ctx, key, message)
+}
+
+// SendWithKeyCalls gets all the calls that were made to SendWithKey.
+// Check the length with:
+//
+//	len(mockedKafkaProducer.SendWithKeyCalls())
+func (mock *KafkaProducerMock) SendWithKeyCalls() []struct {
+	Ctx     context.Context
+	Key     string
+	Message []byte
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Key     string
+		Message []byte
+	}
+	mock.lockSendWithKey.RLock()
+	calls = mock.calls.SendWithKey
+	mock.lockSendWithKey.RUnlock()
+	return calls
+}
@@ -0,0 +1,226 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"github.com/ONSdigital/dp-dimension-extractor/service"
+	"sync"
+	"time"
+)
+
+// Ensure, that MetricsMock does implement service.Metrics.
+// If this is not the case, regenerate this file with moq.
+var _ service.Metrics = &MetricsMock{}
+
+// MetricsMock is a mock implementation of service.Metrics.
+//
+//	func TestSomethingThatUsesMetrics(t *testing.T) {
+//
+//		// make and configure a mocked service.Metrics
+//		mockedMetrics := &MetricsMock{
+//			BytesReadFunc: func(source string, count int)  {
+//				panic("mock out the BytesRead method")
+//			},
+//			DatasetAPIRequestFunc: func(endpoint string, status string, duration time.Duration)  {
+//				panic("mock out the DatasetAPIRequest method")
+//			},
+//			OptionsPostedFunc: func(count int)  {
+//				panic("mock out the OptionsPosted method")
+//			},
+//			RowsScannedFunc: func(count int)  {
+//				panic("mock out the RowsScanned method")
+//			},
+//		}
+//
+//		// use mockedMetrics in code that requires service.Metrics
+//		// and then make assertions.
+//
+//	}
+type MetricsMock struct {
+	// BytesReadFunc mocks the BytesRead method.
+	BytesReadFunc func(source string, count int)
+
+	// DatasetAPIRequestFunc mocks the DatasetAPIRequest method.
+	DatasetAPIRequestFunc func(endpoint string, status string, duration time.Duration)
+
+	// OptionsPostedFunc mocks the OptionsPosted method.
+	OptionsPostedFunc func(count int)
+
+	// RowsScannedFunc mocks the RowsScanned method.
+	RowsScannedFunc func(count int)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// BytesRead holds details about calls to the BytesRead method.
+		BytesRead []struct {
+			// Source is the source argument value.
+			Source string
+			// Count is the count argument value.
+			Count int
+		}
+		// DatasetAPIRequest holds details about calls to the DatasetAPIRequest method.
+		DatasetAPIRequest []struct {
+			// Endpoint is the endpoint argument value.
+			Endpoint string
+			// Status is the status argument value.
+			Status string
+			// Duration is the duration argument value.
+			Duration time.Duration
+		}
+		// OptionsPosted holds details about calls to the OptionsPosted method.
+		OptionsPosted []struct {
+			// Count is the count argument value.
+			Count int
+		}
+		// RowsScanned holds details about calls to the RowsScanned method.
+		RowsScanned []struct {
+			// Count is the count argument value.
+			Count int
+		}
+	}
+	lockBytesRead         sync.RWMutex
+	lockDatasetAPIRequest sync.RWMutex
+	lockOptionsPosted     sync.RWMutex
+	lockRowsScanned       sync.RWMutex
+}
+
+// BytesRead calls BytesReadFunc.
+func (mock *MetricsMock) BytesRead(source string, count int) {
+	if mock.BytesReadFunc == nil {
+		panic("MetricsMock.BytesReadFunc: method is nil but Metrics.BytesRead was just called")
+	}
+	callInfo := struct {
+		Source string
+		Count  int
+	}{
+		Source: source,
+		Count:  count,
+	}
+	mock.lockBytesRead.Lock()
+	mock.calls.BytesRead = append(mock.calls.BytesRead, callInfo)
+	mock.lockBytesRead.Unlock()
+	mock.BytesReadFunc(source, count)
+}
+
+// BytesReadCalls gets all the calls that were made to BytesRead.
+// Check the length with:
+//
+//	len(mockedMetrics.BytesReadCalls())
+func (mock *MetricsMock) BytesReadCalls() []struct {
+	Source string
+	Count  int
+} {
+	var calls []struct {
+		Source string
+		Count  int
+	}
+	mock.lockBytesRead.RLock()
+	calls = mock.calls.BytesRead
+	mock.lockBytesRead.RUnlock()
+	return calls
+}
+
+// DatasetAPIRequest calls DatasetAPIRequestFunc.
+func (mock *MetricsMock) DatasetAPIRequest(endpoint string, status string, duration time.Duration) {
+	if mock.DatasetAPIRequestFunc == nil {
+		panic("MetricsMock.DatasetAPIRequestFunc: method is nil but Metrics.DatasetAPIRequest was just called")
+	}
+	callInfo := struct {
+		Endpoint string
+		Status   string
+		Duration time.Duration
+	}{
+		Endpoint: endpoint,
+		Status:   status,
+		Duration: duration,
+	}
+	mock.lockDatasetAPIRequest.Lock()
+	mock.calls.DatasetAPIRequest = append(mock.calls.DatasetAPIRequest, callInfo)
+	mock.lockDatasetAPIRequest.Unlock()
+	mock.DatasetAPIRequestFunc(endpoint, status, duration)
+}
+
+// DatasetAPIRequestCalls gets all the calls that were made to DatasetAPIRequest.
+// Check the length with:
+//
+//	len(mockedMetrics.DatasetAPIRequestCalls())
+func (mock *MetricsMock) DatasetAPIRequestCalls() []struct {
+	Endpoint string
+	Status   string
+	Duration time.Duration
+} {
+	var calls []struct {
+		Endpoint string
+		Status   string
+		Duration time.Duration
+	}
+	mock.lockDatasetAPIRequest.RLock()
+	calls = mock.calls.DatasetAPIRequest
+	mock.lockDatasetAPIRequest.RUnlock()
+	return calls
+}
+
+// OptionsPosted calls OptionsPostedFunc.
+func (mock *MetricsMock) OptionsPosted(count int) {
+	if mock.OptionsPostedFunc == nil {
+		panic("MetricsMock.OptionsPostedFunc: method is nil but Metrics.OptionsPosted was just called")
+	}
+	callInfo := struct {
+		Count int
+	}{
+		Count: count,
+	}
+	mock.lockOptionsPosted.Lock()
+	mock.calls.OptionsPosted = append(mock.calls.OptionsPosted, callInfo)
+	mock.lockOptionsPosted.Unlock()
+	mock.OptionsPostedFunc(count)
+}
+
+// OptionsPostedCalls gets all the calls that were made to OptionsPosted.
+// Check the length with:
+//
+//	len(mockedMetrics.OptionsPostedCalls())
+func (mock *MetricsMock) OptionsPostedCalls() []struct {
+	Count int
+} {
+	var calls []struct {
+		Count int
+	}
+	mock.lockOptionsPosted.RLock()
+	calls = mock.calls.OptionsPosted
+	mock.lockOptionsPosted.RUnlock()
+	return calls
+}
+
+// RowsScanned calls RowsScannedFunc.
+func (mock *MetricsMock) RowsScanned(count int) {
+	if mock.RowsScannedFunc == nil {
+		panic("MetricsMock.RowsScannedFunc: method is nil but Metrics.RowsScanned was just called")
+	}
+	callInfo := struct {
+		Count int
+	}{
+		Count: count,
+	}
+	mock.lockRowsScanned.Lock()
+	mock.calls.RowsScanned = append(mock.calls.RowsScanned, callInfo)
+	mock.lockRowsScanned.Unlock()
+	mock.RowsScannedFunc(count)
+}
+
+// RowsScannedCalls gets all the calls that were made to RowsScanned.
+// Check the length with:
+//
+//	len(mockedMetrics.RowsScannedCalls())
+func (mock *MetricsMock) RowsScannedCalls() []struct {
+	Count int
+} {
+	var calls []struct {
+		Count int
+	}
+	mock.lockRowsScanned.RLock()
+	calls = mock.calls.RowsScanned
+	mock.lockRowsScanned.RUnlock()
+	return calls
+}
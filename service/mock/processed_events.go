@@ -0,0 +1,132 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"github.com/ONSdigital/dp-dimension-extractor/service"
+	"golang.org/x/net/context"
+	"sync"
+)
+
+// Ensure, that ProcessedEventStoreMock does implement service.ProcessedEventStore.
+// If this is not the case, regenerate this file with moq.
+var _ service.ProcessedEventStore = &ProcessedEventStoreMock{}
+
+// ProcessedEventStoreMock is a mock implementation of service.ProcessedEventStore.
+//
+//	func TestSomethingThatUsesProcessedEventStore(t *testing.T) {
+//
+//		// make and configure a mocked service.ProcessedEventStore
+//		mockedProcessedEventStore := &ProcessedEventStoreMock{
+//			RecordFunc: func(ctx context.Context, key string) error {
+//				panic("mock out the Record method")
+//			},
+//			SeenFunc: func(ctx context.Context, key string) (bool, error) {
+//				panic("mock out the Seen method")
+//			},
+//		}
+//
+//		// use mockedProcessedEventStore in code that requires service.ProcessedEventStore
+//		// and then make assertions.
+//
+//	}
+type ProcessedEventStoreMock struct {
+	// RecordFunc mocks the Record method.
+	RecordFunc func(ctx context.Context, key string) error
+
+	// SeenFunc mocks the Seen method.
+	SeenFunc func(ctx context.Context, key string) (bool, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Record holds details about calls to the Record method.
+		Record []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+		}
+		// Seen holds details about calls to the Seen method.
+		Seen []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+		}
+	}
+	lockRecord sync.RWMutex
+	lockSeen   sync.RWMutex
+}
+
+// Record calls RecordFunc.
+func (mock *ProcessedEventStoreMock) Record(ctx context.Context, key string) error {
+	if mock.RecordFunc == nil {
+		panic("ProcessedEventStoreMock.RecordFunc: method is nil but ProcessedEventStore.Record was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Key string
+	}{
+		Ctx: ctx,
+		Key: key,
+	}
+	mock.lockRecord.Lock()
+	mock.calls.Record = append(mock.calls.Record, callInfo)
+	mock.lockRecord.Unlock()
+	return mock.RecordFunc(ctx, key)
+}
+
+// RecordCalls gets all the calls that were made to Record.
+// Check the length with:
+//
+//	len(mockedProcessedEventStore.RecordCalls())
+func (mock *ProcessedEventStoreMock) RecordCalls() []struct {
+	Ctx context.Context
+	Key string
+} {
+	var calls []struct {
+		Ctx context.Context
+		Key string
+	}
+	mock.lockRecord.RLock()
+	calls = mock.calls.Record
+	mock.lockRecord.RUnlock()
+	return calls
+}
+
+// Seen calls SeenFunc.
+func (mock *ProcessedEventStoreMock) Seen(ctx context.Context, key string) (bool, error) {
+	if mock.SeenFunc == nil {
+		panic("ProcessedEventStoreMock.SeenFunc: method is nil but ProcessedEventStore.Seen was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Key string
+	}{
+		Ctx: ctx,
+		Key: key,
+	}
+	mock.lockSeen.Lock()
+	mock.calls.Seen = append(mock.calls.Seen, callInfo)
+	mock.lockSeen.Unlock()
+	return mock.SeenFunc(ctx, key)
+}
+
+// SeenCalls gets all the calls that were made to Seen.
+// Check the length with:
+//
+//	len(mockedProcessedEventStore.SeenCalls())
+func (mock *ProcessedEventStoreMock) SeenCalls() []struct {
+	Ctx context.Context
+	Key string
+} {
+	var calls []struct {
+		Ctx context.Context
+		Key string
+	}
+	mock.lockSeen.RLock()
+	calls = mock.calls.Seen
+	mock.lockSeen.RUnlock()
+	return calls
+}
@@ -6,6 +6,7 @@ package mock
 import (
 	"github.com/ONSdigital/dp-dimension-extractor/service"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
 	"golang.org/x/net/context"
 	"io"
 	"sync"
@@ -27,9 +28,15 @@ var _ service.S3Client = &S3ClientMock{}
 //			GetFunc: func(ctx context.Context, key string) (io.ReadCloser, *int64, error) {
 //				panic("mock out the Get method")
 //			},
+//			GetRangeFunc: func(ctx context.Context, key string, eTag string, offset int64, length int64) (io.ReadCloser, error) {
+//				panic("mock out the GetRange method")
+//			},
 //			GetWithPSKFunc: func(ctx context.Context, key string, psk []byte) (io.ReadCloser, *int64, error) {
 //				panic("mock out the GetWithPSK method")
 //			},
+//			HeadFunc: func(ctx context.Context, key string) (*awsS3.HeadObjectOutput, error) {
+//				panic("mock out the Head method")
+//			},
 //		}
 //
 //		// use mockedS3Client in code that requires service.S3Client
@@ -43,9 +50,15 @@ type S3ClientMock struct {
 	// GetFunc mocks the Get method.
 	GetFunc func(ctx context.Context, key string) (io.ReadCloser, *int64, error)
 
+	// GetRangeFunc mocks the GetRange method.
+	GetRangeFunc func(ctx context.Context, key string, eTag string, offset int64, length int64) (io.ReadCloser, error)
+
 	// GetWithPSKFunc mocks the GetWithPSK method.
 	GetWithPSKFunc func(ctx context.Context, key string, psk []byte) (io.ReadCloser, *int64, error)
 
+	// HeadFunc mocks the Head method.
+	HeadFunc func(ctx context.Context, key string) (*awsS3.HeadObjectOutput, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// Checker holds details about calls to the Checker method.
@@ -62,6 +75,19 @@ type S3ClientMock struct {
 			// Key is the key argument value.
 			Key string
 		}
+		// GetRange holds details about calls to the GetRange method.
+		GetRange []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+			// ETag is the eTag argument value.
+			ETag string
+			// Offset is the offset argument value.
+			Offset int64
+			// Length is the length argument value.
+			Length int64
+		}
 		// GetWithPSK holds details about calls to the GetWithPSK method.
 		GetWithPSK []struct {
 			// Ctx is the ctx argument value.
@@ -71,10 +97,19 @@ type S3ClientMock struct {
 			// Psk is the psk argument value.
 			Psk []byte
 		}
+		// Head holds details about calls to the Head method.
+		Head []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+		}
 	}
 	lockChecker    sync.RWMutex
 	lockGet        sync.RWMutex
+	lockGetRange   sync.RWMutex
 	lockGetWithPSK sync.RWMutex
+	lockHead       sync.RWMutex
 }
 
 // Checker calls CheckerFunc.
@@ -149,6 +184,54 @@ func (mock *S3ClientMock) GetCalls() []struct {
 	return calls
 }
 
+// GetRange calls GetRangeFunc.
+func (mock *S3ClientMock) GetRange(ctx context.Context, key string, eTag string, offset int64, length int64) (io.ReadCloser, error) {
+	if mock.GetRangeFunc == nil {
+		panic("S3ClientMock.GetRangeFunc: method is nil but S3Client.GetRange was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Key    string
+		ETag   string
+		Offset int64
+		Length int64
+	}{
+		Ctx:    ctx,
+		Key:    key,
+		ETag:   eTag,
+		Offset: offset,
+		Length: length,
+	}
+	mock.lockGetRange.Lock()
+	mock.calls.GetRange = append(mock.calls.GetRange, callInfo)
+	mock.lockGetRange.Unlock()
+	return mock.GetRangeFunc(ctx, key, eTag, offset, length)
+}
+
+// GetRangeCalls gets all the calls that were made to GetRange.
+// Check the length with:
+//
+//	len(mockedS3Client.GetRangeCalls())
+func (mock *S3ClientMock) GetRangeCalls() []struct {
+	Ctx    context.Context
+	Key    string
+	ETag   string
+	Offset int64
+	Length int64
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Key    string
+		ETag   string
+		Offset int64
+		Length int64
+	}
+	mock.lockGetRange.RLock()
+	calls = mock.calls.GetRange
+	mock.lockGetRange.RUnlock()
+	return calls
+}
+
 // GetWithPSK calls GetWithPSKFunc.
 func (mock *S3ClientMock) GetWithPSK(ctx context.Context, key string, psk []byte) (io.ReadCloser, *int64, error) {
 	if mock.GetWithPSKFunc == nil {
@@ -188,3 +271,39 @@ func (mock *S3ClientMock) GetWithPSKCalls() []struct {
 	mock.lockGetWithPSK.RUnlock()
 	return calls
 }
+
+// Head calls HeadFunc.
+func (mock *S3ClientMock) Head(ctx context.Context, key string) (*awsS3.HeadObjectOutput, error) {
+	if mock.HeadFunc == nil {
+		panic("S3ClientMock.HeadFunc: method is nil but S3Client.Head was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Key string
+	}{
+		Ctx: ctx,
+		Key: key,
+	}
+	mock.lockHead.Lock()
+	mock.calls.Head = append(mock.calls.Head, callInfo)
+	mock.lockHead.Unlock()
+	return mock.HeadFunc(ctx, key)
+}
+
+// HeadCalls gets all the calls that were made to Head.
+// Check the length with:
+//
+//	len(mockedS3Client.HeadCalls())
+func (mock *S3ClientMock) HeadCalls() []struct {
+	Ctx context.Context
+	Key string
+} {
+	var calls []struct {
+		Ctx context.Context
+		Key string
+	}
+	mock.lockHead.RLock()
+	calls = mock.calls.Head
+	mock.lockHead.RUnlock()
+	return calls
+}
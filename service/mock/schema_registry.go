@@ -0,0 +1,145 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"github.com/ONSdigital/dp-dimension-extractor/service"
+	"github.com/ONSdigital/dp-kafka/v2/avro"
+	"golang.org/x/net/context"
+	"sync"
+)
+
+// Ensure, that SchemaRegistryMock does implement service.SchemaRegistry.
+// If this is not the case, regenerate this file with moq.
+var _ service.SchemaRegistry = &SchemaRegistryMock{}
+
+// SchemaRegistryMock is a mock implementation of service.SchemaRegistry.
+//
+//	func TestSomethingThatUsesSchemaRegistry(t *testing.T) {
+//
+//		// make and configure a mocked service.SchemaRegistry
+//		mockedSchemaRegistry := &SchemaRegistryMock{
+//			DecodeFunc: func(ctx context.Context, message []byte) (*avro.Schema, []byte, error) {
+//				panic("mock out the Decode method")
+//			},
+//			EncodeFunc: func(ctx context.Context, subject string, schema *avro.Schema, value interface{}) ([]byte, error) {
+//				panic("mock out the Encode method")
+//			},
+//		}
+//
+//		// use mockedSchemaRegistry in code that requires service.SchemaRegistry
+//		// and then make assertions.
+//
+//	}
+type SchemaRegistryMock struct {
+	// DecodeFunc mocks the Decode method.
+	DecodeFunc func(ctx context.Context, message []byte) (*avro.Schema, []byte, error)
+
+	// EncodeFunc mocks the Encode method.
+	EncodeFunc func(ctx context.Context, subject string, schema *avro.Schema, value interface{}) ([]byte, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Decode holds details about calls to the Decode method.
+		Decode []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Message is the message argument value.
+			Message []byte
+		}
+		// Encode holds details about calls to the Encode method.
+		Encode []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Subject is the subject argument value.
+			Subject string
+			// Schema is the schema argument value.
+			Schema *avro.Schema
+			// Value is the value argument value.
+			Value interface{}
+		}
+	}
+	lockDecode sync.RWMutex
+	lockEncode sync.RWMutex
+}
+
+// Decode calls DecodeFunc.
+func (mock *SchemaRegistryMock) Decode(ctx context.Context, message []byte) (*avro.Schema, []byte, error) {
+	if mock.DecodeFunc == nil {
+		panic("SchemaRegistryMock.DecodeFunc: method is nil but SchemaRegistry.Decode was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Message []byte
+	}{
+		Ctx:     ctx,
+		Message: message,
+	}
+	mock.lockDecode.Lock()
+	mock.calls.Decode = append(mock.calls.Decode, callInfo)
+	mock.lockDecode.Unlock()
+	return mock.DecodeFunc(ctx, message)
+}
+
+// DecodeCalls gets all the calls that were made to Decode.
+// Check the length with:
+//
+//	len(mockedSchemaRegistry.DecodeCalls())
+func (mock *SchemaRegistryMock) DecodeCalls() []struct {
+	Ctx     context.Context
+	Message []byte
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Message []byte
+	}
+	mock.lockDecode.RLock()
+	calls = mock.calls.Decode
+	mock.lockDecode.RUnlock()
+	return calls
+}
+
+// Encode calls EncodeFunc.
+func (mock *SchemaRegistryMock) Encode(ctx context.Context, subject string, schema *avro.Schema, value interface{}) ([]byte, error) {
+	if mock.EncodeFunc == nil {
+		panic("SchemaRegistryMock.EncodeFunc: method is nil but SchemaRegistry.Encode was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Subject string
+		Schema  *avro.Schema
+		Value   interface{}
+	}{
+		Ctx:     ctx,
+		Subject: subject,
+		Schema:  schema,
+		Value:   value,
+	}
+	mock.lockEncode.Lock()
+	mock.calls.Encode = append(mock.calls.Encode, callInfo)
+	mock.lockEncode.Unlock()
+	return mock.EncodeFunc(ctx, subject, schema, value)
+}
+
+// EncodeCalls gets all the calls that were made to Encode.
+// Check the length with:
+//
+//	len(mockedSchemaRegistry.EncodeCalls())
+func (mock *SchemaRegistryMock) EncodeCalls() []struct {
+	Ctx     context.Context
+	Subject string
+	Schema  *avro.Schema
+	Value   interface{}
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Subject string
+		Schema  *avro.Schema
+		Value   interface{}
+	}
+	mock.lockEncode.RLock()
+	calls = mock.calls.Encode
+	mock.lockEncode.RUnlock()
+	return calls
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/headers"
+	"github.com/ONSdigital/dp-dimension-extractor/datasetapi"
+	"github.com/ONSdigital/log.go/v2/log"
+	"golang.org/x/net/context"
+)
+
+// errorCodeUnknown is the error code of failures caused by an error which does not carry its class
+const errorCodeUnknown = "unknown"
+
+// InstanceFailureReporter marks the import of instances as failed in the dataset API, with an event describing
+// the error, so that the failure is reflected even when the import reporter is not processing report events
+type InstanceFailureReporter struct {
+	AuthToken     string
+	DatasetClient DatasetClient
+}
+
+// Notify sets the state of the instance to failed and adds an event with the class of the error as error code,
+// the error message and the rows of the csv file which caused it, if any
+func (r *InstanceFailureReporter) Notify(ctx context.Context, instanceID, errContext string, err error) error {
+	failure := importFailure(errContext, err)
+	logData := log.Data{"instance_id": instanceID, "error_code": failure.ErrorCode, "rows": failure.Rows}
+
+	if _, err := r.DatasetClient.PutInstanceFailed(ctx, r.AuthToken, instanceID, failure, headers.IfMatchAnyETag); err != nil {
+		log.Error(ctx, "failed to mark the instance import as failed", err, logData)
+		return err
+	}
+
+	log.Info(ctx, "instance import marked as failed", logData)
+	return nil
+}
+
+// importFailure describes the failure caused by err
+func importFailure(errContext string, err error) datasetapi.ImportFailure {
+	failure := datasetapi.ImportFailure{
+		ErrorCode: errorCodeUnknown,
+		Message:   errContext + ": " + err.Error(),
+	}
+
+	var classifiedErr *ClassifiedError
+	if errors.As(err, &classifiedErr) {
+		failure.ErrorCode = classifiedErr.Class
+	}
+
+	var rowErr *RowError
+	if errors.As(err, &rowErr) {
+		failure.Rows = []int{rowErr.Row}
+	}
+
+	return failure
+}
@@ -0,0 +1,77 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-extractor/datasetapi"
+	"github.com/ONSdigital/dp-dimension-extractor/service"
+	"github.com/ONSdigital/dp-dimension-extractor/service/mock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInstanceFailureReporter_Notify(t *testing.T) {
+	Convey("Given an InstanceFailureReporter", t, func() {
+		datasetClient := &mock.DatasetClientMock{
+			PutInstanceFailedFunc: func(ctx context.Context, serviceAuthToken string, instanceID string, failure datasetapi.ImportFailure, ifMatch string) (string, error) {
+				return "", nil
+			},
+		}
+		r := &service.InstanceFailureReporter{AuthToken: validAuthToken, DatasetClient: datasetClient}
+
+		Convey("When Notify is called with a classified error caused by a csv row", func() {
+			err := r.Notify(ctx, validInstanceID, "event failed to process", &service.ClassifiedError{
+				Class: service.ErrorClassCSV,
+				Err:   &service.RowError{Row: 3, Err: errors.New("bork")},
+			})
+
+			Convey("Then the instance is marked as failed with the error class and the row", func() {
+				So(err, ShouldBeNil)
+				So(datasetClient.PutInstanceFailedCalls(), ShouldHaveLength, 1)
+				call := datasetClient.PutInstanceFailedCalls()[0]
+				So(call.ServiceAuthToken, ShouldEqual, validAuthToken)
+				So(call.InstanceID, ShouldEqual, validInstanceID)
+				So(call.Failure.ErrorCode, ShouldEqual, service.ErrorClassCSV)
+				So(call.Failure.Rows, ShouldResemble, []int{3})
+				So(call.Failure.Message, ShouldEqual, "event failed to process: bork")
+			})
+		})
+
+		Convey("When Notify is called with a file rejected before being parsed", func() {
+			err := r.Notify(ctx, validInstanceID, "event failed to process", &service.ClassifiedError{
+				Class: service.ErrorClassFileCheck,
+				Err:   &service.FileCheckError{Check: service.FileCheckMaxSize, Reason: "size of 1001 bytes exceeds the maximum of 1000 bytes"},
+			})
+
+			Convey("Then the instance is marked as failed with the error class and the check which failed", func() {
+				So(err, ShouldBeNil)
+				call := datasetClient.PutInstanceFailedCalls()[0]
+				So(call.Failure.ErrorCode, ShouldEqual, service.ErrorClassFileCheck)
+				So(call.Failure.Message, ShouldEqual, "event failed to process: file rejected by max_size check: size of 1001 bytes exceeds the maximum of 1000 bytes")
+			})
+		})
+
+		Convey("When Notify is called with an unclassified error", func() {
+			err := r.Notify(ctx, validInstanceID, "event failed to process", errors.New("bork"))
+
+			Convey("Then the instance is marked as failed with an unknown error code and no rows", func() {
+				So(err, ShouldBeNil)
+				call := datasetClient.PutInstanceFailedCalls()[0]
+				So(call.Failure.ErrorCode, ShouldEqual, "unknown")
+				So(call.Failure.Rows, ShouldBeNil)
+			})
+		})
+
+		Convey("When the dataset API fails", func() {
+			datasetClient.PutInstanceFailedFunc = func(ctx context.Context, serviceAuthToken string, instanceID string, failure datasetapi.ImportFailure, ifMatch string) (string, error) {
+				return "", errors.New("dataset api unavailable")
+			}
+			err := r.Notify(ctx, validInstanceID, "event failed to process", errors.New("bork"))
+
+			Convey("Then the error is returned", func() {
+				So(err, ShouldResemble, errors.New("dataset api unavailable"))
+			})
+		})
+	})
+}
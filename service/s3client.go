@@ -0,0 +1,54 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	s3client "github.com/ONSdigital/dp-s3/v3"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/net/context"
+)
+
+// RangeClient is a dp-s3 client which can also get an object from an offset, so that reading it can be resumed
+type RangeClient struct {
+	*s3client.Client
+	sdkClient  *s3.Client
+	bucketName string
+}
+
+var _ S3Client = (*RangeClient)(nil)
+
+// NewS3Client creates an S3 client for the bucket, with the AWS config and the options of the AWS S3 client
+func NewS3Client(bucketName string, cfg aws.Config, optFns ...func(*s3.Options)) *RangeClient {
+	return &RangeClient{
+		Client:     s3client.NewClientWithConfig(bucketName, cfg, optFns...),
+		sdkClient:  s3.NewFromConfig(cfg, optFns...),
+		bucketName: bucketName,
+	}
+}
+
+// GetRange returns length bytes of the content of the object from the offset, or the rest of it if length is not positive,
+// if its ETag is still the provided one
+func (cli *RangeClient) GetRange(ctx context.Context, key, eTag string, offset, length int64) (io.ReadCloser, error) {
+	byteRange := fmt.Sprintf("bytes=%d-", offset)
+	if length > 0 {
+		byteRange += strconv.FormatInt(offset+length-1, 10)
+	}
+	result, err := cli.sdkClient.GetObject(ctx, &s3.GetObjectInput{
+		Bucket:  aws.String(cli.bucketName),
+		Key:     aws.String(key),
+		Range:   aws.String(byteRange),
+		IfMatch: aws.String(`"` + eTag + `"`),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting object range from s3: %w", err)
+	}
+	if resultETag := strings.Trim(aws.ToString(result.ETag), `"`); resultETag != eTag {
+		result.Body.Close()
+		return nil, fmt.Errorf("%w: etag %s, expected %s", errObjectChanged, resultETag, eTag)
+	}
+	return result.Body, nil
+}
@@ -1,52 +1,76 @@
 package service
 
 import (
+	"crypto/md5"
+	"crypto/sha256"
 	"encoding/csv"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"hash"
 	"io"
+	"maps"
+	"mime"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
 	"github.com/ONSdigital/dp-api-clients-go/v2/headers"
 	"github.com/ONSdigital/dp-dimension-extractor/config"
+	"github.com/ONSdigital/dp-dimension-extractor/dedup"
 	"github.com/ONSdigital/dp-dimension-extractor/dimension"
+	"github.com/ONSdigital/dp-dimension-extractor/jobs"
+	"github.com/ONSdigital/dp-dimension-extractor/registry"
 	"github.com/ONSdigital/dp-dimension-extractor/schema"
 	kafka "github.com/ONSdigital/dp-kafka/v2"
+	"github.com/ONSdigital/dp-kafka/v2/avro"
 	s3client "github.com/ONSdigital/dp-s3/v3"
 	"github.com/ONSdigital/log.go/v2/log"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/context"
 )
 
-// DimensionExtracted represents a kafka avro model for a dimension extracted file for an instance
+var tracer = otel.Tracer("github.com/ONSdigital/dp-dimension-extractor/service")
+
+// errDuplicateEvent is returned by retrieveData when the same event has already been processed
+var errDuplicateEvent = errors.New("event has already been processed")
+
+// DimensionExtracted represents a kafka avro or JSON model for a dimension extracted file for an instance
 type DimensionExtracted struct {
-	FileURL    string `avro:"file_url"`
-	InstanceID string `avro:"instance_id"`
+	FileURL               string            `avro:"file_url" json:"file_url"`
+	InstanceID            string            `avro:"instance_id" json:"instance_id"`
+	HeaderNames           []string          `avro:"header_names" json:"header_names"`
+	NumberOfObservations  int64             `avro:"number_of_observations" json:"number_of_observations"`
+	DimensionOptionCounts map[string]string `avro:"dimension_option_counts" json:"dimension_option_counts"`
+	FileChecksum          string            `avro:"file_checksum" json:"file_checksum"`
+	S3ETag                string            `avro:"s3_etag" json:"s3_etag"`
+	ExtractorVersion      string            `avro:"extractor_version" json:"extractor_version"`
 }
 
-// InputFileAvailable represents a kafka avro model for an available input file fo an instance
+// InputFileAvailable represents a kafka avro or JSON model for an available input file fo an instance.
+// All the fields but the file URL and instance ID are optional.
 type InputFileAvailable struct {
-	FileURL    string `avro:"file_url"`
-	InstanceID string `avro:"instance_id"`
+	FileURL           string            `avro:"file_url" json:"file_url"`
+	InstanceID        string            `avro:"instance_id" json:"instance_id"`
+	DatasetID         string            `avro:"dataset_id" json:"dataset_id"`
+	Edition           string            `avro:"edition" json:"edition"`
+	Version           string            `avro:"version" json:"version"`
+	ExpectedChecksum  string            `avro:"expected_checksum" json:"expected_checksum"`
+	ContentEncoding   string            `avro:"content_encoding" json:"content_encoding"`
+	CSVDialect        CSVDialect        `avro:"csv_dialect" json:"csv_dialect"`
+	ProcessingOptions map[string]string `avro:"processing_options" json:"processing_options"`
 }
 
 // S3URL parses the fileURL into an S3Url struct. s3:// prefix is interpreted as
 // DNS-Alias-virtual-hosted style. Otherwise, path-style is assumed.
 func (inputFileAvailable *InputFileAvailable) S3URL() (*s3client.S3Url, error) {
-	if strings.HasPrefix(inputFileAvailable.FileURL, "s3:") {
-		// Assume DNS Alias Virtual Hosted style URL (e.g. s3://bucket/key)
-		return s3client.ParseAliasVirtualHostedURL(inputFileAvailable.FileURL)
-	}
-	// Assume Path-style / Global-path-style URL (e.g. https://https://s3-eu-west-1.amazonaws.com/bucket/key)
-	s3URL, err := s3client.ParseGlobalPathStyleURL(inputFileAvailable.FileURL)
-	if err != nil {
-		return nil, err
-	}
-	s3URL.Scheme = "s3"
-	return s3URL, nil
+	return parseS3URL(inputFileAvailable.FileURL)
 }
 
 // Service handles incoming messages.
@@ -57,51 +81,336 @@ type Service struct {
 	DatasetClient              DatasetClient
 	AwsConfig                  *aws.Config
 	S3Clients                  map[string]S3Client
+	FileSources                FileSources
+	FileChecks                 FileChecks
 	VaultClient                VaultClient
 	VaultPath                  string
+	Metrics                    Metrics
+	ProcessedEvents            ProcessedEventStore
+	ExtractorVersion           string
+	SchemaRegistry             SchemaRegistry
+	DimensionsExtractedSubject string
+	MessageEncoding            string
+	ProduceMaxRetries          int
+	ProduceRetryBackoff        time.Duration
+	Jobs                       *jobs.Registry
 }
 
+// Dataset API endpoints, used to label the requests recorded in metrics
+const (
+	endpointGetInstance            = "get_instance"
+	endpointPostInstanceDimensions = "post_instance_dimensions"
+	endpointPutInstanceData        = "put_instance_data"
+)
+
 // HandleMessage handles a message by sending requests to the dataset API
 // before producing a new message to confirm successful completion
 func (svc *Service) HandleMessage(ctx context.Context, message kafka.Message) (string, error) {
 
-	producerMessage, instanceID, file, err := svc.retrieveData(ctx, message)
+	event, err := svc.readMessage(ctx, message)
+	if err != nil {
+		log.Error(ctx, "error reading message", err, log.Data{"schema": "failed to unmarshal event"})
+		return "", err
+	}
+	return svc.HandleEvent(ctx, event)
+}
+
+// HandleEvent handles an input file available event, whether it was consumed from kafka or requested through the admin API,
+// by sending requests to the dataset API before producing a new message to confirm successful completion.
+// For a dry run, the file is only validated: the errors caused by the event or the file are recorded in the
+// validation report of its job, rather than returned.
+func (svc *Service) HandleEvent(ctx context.Context, event *InputFileAvailable) (string, error) {
+	if event.DryRun() {
+		_, err := svc.Validate(ctx, event)
+		return event.InstanceID, err
+	}
+
+	job := svc.Jobs.Start(event.InstanceID, event.FileURL)
+	instanceID, err := svc.handleEvent(ctx, event, job, nil)
+	job.Finish(err)
+	return instanceID, err
+}
+
+// Validate runs a dry run of the extraction of the file of the event, and returns its validation report.
+// An error is only returned if the file could not be validated, e.g. because S3 or the dataset API is unavailable.
+func (svc *Service) Validate(ctx context.Context, event *InputFileAvailable) (*ValidationReport, error) {
+	dryRun := *event
+	dryRun.ProcessingOptions = maps.Clone(event.ProcessingOptions)
+	if dryRun.ProcessingOptions == nil {
+		dryRun.ProcessingOptions = make(map[string]string)
+	}
+	dryRun.ProcessingOptions[ProcessingOptionDryRun] = "true"
+
+	job := svc.Jobs.Start(event.InstanceID, event.FileURL)
+	report := newValidationReport(event)
+	_, err := svc.handleEvent(ctx, &dryRun, job, report)
+	if err != nil && report.addError(err) {
+		err = nil
+	}
+	job.SetReport(report)
+	job.Finish(err)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info(ctx, "dry run, the file was validated without sending anything to the dataset API", log.Data{
+		"instance_id":                 event.InstanceID,
+		"valid":                       report.Valid,
+		"errors":                      report.Errors,
+		"number_of_observations":      report.NumberOfObservations,
+		"number_of_dimension_options": report.Statistics.DimensionOptions,
+		"conflicts":                   len(report.Conflicts),
+		"file_checksum":               report.FileChecksum,
+	})
+	return report, nil
+}
+
+// handleEvent extracts the dimensions of the file of the event, recording its progress in the job.
+// For a dry run, the content of the file is recorded in the report, and nothing is sent.
+func (svc *Service) handleEvent(ctx context.Context, event *InputFileAvailable, job *jobs.Job, report *ValidationReport) (string, error) {
+	instanceID := event.InstanceID
+
+	eventKey, file, err := svc.retrieveData(ctx, event, job)
+	if err == errDuplicateEvent {
+		job.SetPhase(jobs.PhaseSkipped)
+		return instanceID, nil
+	}
 	if err != nil {
 		return instanceID, err
 	}
 	defer file.Close()
+	if report != nil {
+		report.S3ETag = file.eTag
+	}
 
-	codeLists, _, err := svc.DatasetClient.GetInstance(ctx, "", svc.AuthToken, "", instanceID, headers.IfMatchAnyETag)
+	instance, err := svc.getInstance(ctx, instanceID)
+	if err != nil {
+		return instanceID, classify(ErrorClassDatasetAPI, err)
+	}
+	// a dry run does not update the instance, so the file can be validated whatever the state of the instance
+	if reason := notExtractableReason(instance); reason != "" && !event.DryRun() {
+		log.Info(ctx, "instance is not in an extractable state, the event is skipped", log.Data{"instance_id": instanceID, "reason": reason})
+		job.SetPhase(jobs.PhaseSkipped)
+		return instanceID, nil
+	}
+
+	scanned, err := svc.extract(ctx, event, file, instance, job, report)
 	if err != nil {
-		log.Error(ctx, "encountered error immediately when requesting data from the dataset api", err, log.Data{"instance_id": instanceID})
 		return instanceID, err
 	}
+	headerRow, dimensionOptions, numberOfObservations := scanned.headerRow, scanned.dimensionOptions, scanned.numberOfObservations
+
+	if event.DryRun() {
+		return instanceID, nil
+	}
+
+	job.SetPhase(jobs.PhasePostingOptions)
+	if err := svc.postDimensionOptions(ctx, instanceID, dimensionOptions, job); err != nil {
+		return instanceID, classify(ErrorClassDatasetAPI, err)
+	}
+
+	// Check the state again, so that an import which was cancelled or completed during the extraction is not overwritten
+	job.SetPhase(jobs.PhaseUpdatingInstance)
+	instance, err = svc.getInstance(ctx, instanceID)
+	if err != nil {
+		return instanceID, classify(ErrorClassDatasetAPI, err)
+	}
+	if reason := notExtractableReason(instance); reason != "" {
+		log.Warn(ctx, "instance state changed during the extraction, the instance data is not updated", log.Data{"instance_id": instanceID, "reason": reason})
+		job.SetPhase(jobs.PhaseSkipped)
+		return instanceID, nil
+	}
+
+	// PUT request to dataset API to pass the header row and the number of observations that exist against this job instance
+	putCtx, span := tracer.Start(ctx, "dataset api put instance data", trace.WithAttributes(
+		attribute.String("instance_id", instanceID),
+		attribute.Int("observations", numberOfObservations),
+	))
+	start := time.Now()
+	_, err = svc.DatasetClient.PutInstanceData(
+		putCtx,
+		svc.AuthToken,
+		instanceID,
+		dataset.JobInstance{
+			HeaderNames:          headerRow,
+			NumberOfObservations: numberOfObservations,
+		}, headers.IfMatchAnyETag)
+	svc.observeDatasetAPIRequest(endpointPutInstanceData, start, err)
+	endSpan(span, err)
+	if err != nil {
+		log.Error(ctx, "encountered error sending request to the dataset api", err, log.Data{"instance_id": instanceID, "number_of_observations": numberOfObservations})
+		return instanceID, classify(ErrorClassDatasetAPI, err)
+	}
+	log.Info(ctx, "successfully sent request to dataset API", log.Data{"instance_id": instanceID, "number_of_observations": numberOfObservations})
+
+	job.SetPhase(jobs.PhaseProducing)
+
+	// Once csv file has been iterated over and there were no errors,
+	// send a completed messsage to the dimensions-extracted topic and wait for the outcome of its delivery
+	err = svc.produceDimensionsExtracted(ctx, &DimensionExtracted{
+		FileURL:               file.s3URL,
+		InstanceID:            instanceID,
+		HeaderNames:           headerRow,
+		NumberOfObservations:  int64(numberOfObservations),
+		DimensionOptionCounts: dimensionOptionCounts(dimensionOptions),
+		FileChecksum:          file.checksum(),
+		S3ETag:                file.eTag,
+		ExtractorVersion:      svc.ExtractorVersion,
+	})
+	if err != nil {
+		log.Error(ctx, "encountered error producing dimensions extracted message", err, log.Data{"instance_id": instanceID})
+		return instanceID, err
+	}
+	log.Info(ctx, "dimensions extracted message delivered to kafka", log.Data{"instance_id": instanceID, "file_checksum": file.checksum()})
+
+	if eventKey != "" {
+		if err := svc.ProcessedEvents.Record(ctx, eventKey); err != nil {
+			log.Warn(ctx, "failed to record processed event, a repeated event will be processed again", log.FormatErrors([]error{err}), log.Data{"instance_id": instanceID, "event_key": eventKey})
+		}
+	}
 
+	return instanceID, nil
+}
+
+// extract reads the file and extracts the dimension options of the instance from it, checking that the file
+// matches the event and, in strict mode, the instance. For a dry run, the content of the file is recorded in the report.
+func (svc *Service) extract(ctx context.Context, event *InputFileAvailable, file *sourceFile, instance dataset.Instance, job *jobs.Job, report *ValidationReport) (*scanResult, error) {
+	instanceID := event.InstanceID
+
+	content, err := decodeContent(file, event.ContentEncoding)
+	if err != nil {
+		log.Error(ctx, "unable to decode the csv file", err, log.Data{"instance_id": instanceID, "content_encoding": event.ContentEncoding})
+		return nil, classify(ErrorClassMessage, err)
+	}
+
+	if err := event.checkInstance(instance); err != nil {
+		log.Error(ctx, "the event does not match the instance", err, log.Data{"instance_id": instanceID})
+		return nil, classify(ErrorClassMessage, err)
+	}
+
+	job.SetPhase(jobs.PhaseScanning)
+	scanned, err := svc.scan(ctx, instanceID, content, event.CSVDialect, codelists(instance), job)
+	if err != nil {
+		return nil, classify(ErrorClassCSV, err)
+	}
+	if report != nil {
+		report.addScan(scanned)
+	}
+
+	// the checksums cover the whole file, even if its content ends before the end of the file
+	if _, err := io.Copy(io.Discard, file); err != nil {
+		log.Error(ctx, "unable to read the end of the csv file", err, log.Data{"instance_id": instanceID})
+		return nil, classify(ErrorClassCSV, err)
+	}
+	if err := event.verifyChecksum(file.checksum()); err != nil {
+		log.Error(ctx, "the checksum of the csv file does not match the expected checksum", err, log.Data{"instance_id": instanceID})
+		return nil, classify(ErrorClassChecksum, err)
+	}
+	if err := file.verifyChecksums(); err != nil {
+		log.Error(ctx, "the checksum of the csv file does not match the checksum of its source", err, log.Data{"instance_id": instanceID})
+		return nil, classify(ErrorClassChecksum, err)
+	}
+	if report != nil {
+		report.FileChecksum = file.checksum()
+	}
+	log.Info(ctx, "csv file checksum verified", log.Data{
+		"instance_id":       instanceID,
+		"file_checksum":     file.checksum(),
+		"expected_checksum": event.ExpectedChecksum,
+		"source_checksum":   file.expectedChecksum,
+		"etag_verified":     file.md5 != nil,
+	})
+
+	if event.Strict() {
+		if err := checkStrict(instance, scanned.headerRow, scanned.numberOfObservations); err != nil {
+			log.Error(ctx, "the csv file is rejected in strict mode", err, log.Data{"instance_id": instanceID})
+			return nil, classify(ErrorClassCSV, err)
+		}
+	}
+	return scanned, nil
+}
+
+// getInstance requests the instance from the dataset API
+func (svc *Service) getInstance(ctx context.Context, instanceID string) (instance dataset.Instance, err error) {
+	ctx, span := tracer.Start(ctx, "dataset api get instance", trace.WithAttributes(attribute.String("instance_id", instanceID)))
+	defer func() {
+		span.SetAttributes(attribute.String("instance_state", instance.State))
+		endSpan(span, err)
+	}()
+
+	start := time.Now()
+	instance, _, err = svc.DatasetClient.GetInstance(ctx, "", svc.AuthToken, "", instanceID, headers.IfMatchAnyETag)
+	svc.observeDatasetAPIRequest(endpointGetInstance, start, err)
+	if err != nil {
+		log.Error(ctx, "encountered error immediately when requesting data from the dataset api", err, log.Data{"instance_id": instanceID})
+		return dataset.Instance{}, err
+	}
+	return instance, nil
+}
+
+// dimensionOptionCounts returns the number of unique options extracted for each dimension, keyed by dimension name
+func dimensionOptionCounts(dimensionOptions map[string]dataset.OptionPost) map[string]string {
+	counts := make(map[string]int)
+	for _, option := range dimensionOptions {
+		counts[option.Name]++
+	}
+
+	optionCounts := make(map[string]string, len(counts))
+	for name, count := range counts {
+		optionCounts[name] = strconv.Itoa(count)
+	}
+	return optionCounts
+}
+
+// codelists returns the code list IDs of the instance keyed by dimension name
+func codelists(instance dataset.Instance) map[string]string {
 	codelistMap := make(map[string]string)
-	for _, cl := range codeLists.Dimensions {
+	for _, cl := range instance.Dimensions {
 		codelistMap[cl.Name] = cl.ID
 	}
+	return codelistMap
+}
 
-	csvReader := csv.NewReader(file)
+// scan reads the whole csv file, returning its header row, the unique dimension options found and the number of observations,
+// along with the options found with different labels. For encrypted files, decryption happens while the file is being read,
+// so it is accounted for in this span.
+func (svc *Service) scan(ctx context.Context, instanceID string, file io.Reader, dialect CSVDialect, codelistMap map[string]string, job *jobs.Job) (result *scanResult, err error) {
+	result = &scanResult{dimensionOptions: make(map[string]dataset.OptionPost), conflictingLabels: make(map[string][]string)}
+	ctx, span := tracer.Start(ctx, "scan csv", trace.WithAttributes(attribute.String("instance_id", instanceID)))
+	defer func() {
+		svc.metrics().RowsScanned(result.rowsScanned)
+		span.SetAttributes(
+			attribute.Int("observations", result.numberOfObservations),
+			attribute.Int("dimension_options", len(result.dimensionOptions)),
+		)
+		endSpan(span, err)
+	}()
+
+	csvReader, err := dialect.newReader(file)
+	if err != nil {
+		log.Error(ctx, "invalid csv dialect", err, log.Data{"instance_id": instanceID, "csv_dialect": dialect})
+		return result, err
+	}
 
 	// Scan for header row, this information will need to be sent to the
 	// dataset API with the number of observations in a PUT request
 	headerRow, err := csvReader.Read()
 	if err != nil {
 		log.Error(ctx, "encountered error immediately when processing header row", err, log.Data{"instance_id": instanceID})
-		return instanceID, err
+		return result, parseRowError(err)
 	}
+	result.headerRow = headerRow
 
 	metaData := strings.Split(headerRow[0], "_")
 	if len(metaData) < 2 {
 		err = errors.New("no underscore in header row")
 		log.Error(ctx, "encountered badly-formatted header row", err, log.Data{"instance_id": instanceID})
-		return instanceID, err
+		return result, recordRowError(csvReader, err)
 	}
 	dimensionColumnOffset, err := strconv.Atoi(metaData[1])
 	if err != nil {
 		log.Error(ctx, "encountered error distinguishing dimension column offset", err, log.Data{"instance_id": instanceID})
-		return instanceID, err
+		return result, recordRowError(csvReader, err)
 	}
 
 	// Meta data for dimension column offset does not consider the observation column, so add 1 to value
@@ -109,10 +418,6 @@ func (svc *Service) HandleMessage(ctx context.Context, message kafka.Message) (s
 
 	log.Info(ctx, "a list of headers", log.Data{"instance_id": instanceID, "header_row": headerRow})
 
-	dimensionOptions := make(map[string]dataset.OptionPost)
-	numberOfObservations := 0
-	numberOfOptions := 0
-
 	// Iterate over csv file pulling out unique dimensions
 	for {
 		line, err := csvReader.Read()
@@ -121,8 +426,10 @@ func (svc *Service) HandleMessage(ctx context.Context, message kafka.Message) (s
 		}
 		if err != nil {
 			log.Error(ctx, "encountered error reading csv", err, log.Data{"instance_id": instanceID, "csv_line": line})
-			return instanceID, err
+			return result, parseRowError(err)
 		}
+		result.rowsScanned++
+		job.RowScanned()
 
 		dim := dimension.Extract{
 			DimensionColumnOffset: dimensionColumnOffset,
@@ -135,156 +442,468 @@ func (svc *Service) HandleMessage(ctx context.Context, message kafka.Message) (s
 		lineDimensions, err := dim.Extract()
 		if err != nil {
 			log.Error(ctx, "encountered error retrieving dimensions", err, log.Data{"instance_id": instanceID, "csv_line": line})
-			return instanceID, err
+			return result, recordRowError(csvReader, err)
 		}
 
-		// loop through each dimension option identified in the CSV row
-		for optionKey, optionToPost := range lineDimensions {
+		// only the first occurrence of each dimension option is kept
+		result.addOptions(lineDimensions)
 
-			// if the option is already in the full map of options then do not add it
-			if _, ok := dimensionOptions[optionKey]; ok {
-				continue
-			}
-
-			dimensionOptions[optionKey] = optionToPost
-			numberOfOptions++
+		if len(line) > 0 && line[0] == "" {
+			result.blankObservations++
 		}
-
-		numberOfObservations++
+		result.numberOfObservations++
 	}
 
-	log.Info(ctx, "dimensions extracted from the import file", log.Data{
+	logData := log.Data{
 		"instance_id":                 instanceID,
-		"number_of_observations":      numberOfObservations,
-		"number_of_dimension_options": numberOfOptions,
-	})
+		"number_of_observations":      result.numberOfObservations,
+		"number_of_dimension_options": len(result.dimensionOptions),
+	}
+	if len(result.conflictingLabels) > 0 {
+		logData["conflicts"] = result.optionConflicts()
+		log.Warn(ctx, "dimension options found with different labels, only the label of their first row is kept", logData)
+	}
+	log.Info(ctx, "dimensions extracted from the import file", logData)
+
+	return result, nil
+}
+
+// parseRowError wraps a csv parsing error with the line at which the faulty row starts.
+// Any other error, such as a failure to read the file, is returned as it is.
+func parseRowError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &RowError{Row: parseErr.StartLine, Err: err}
+	}
+	return err
+}
+
+// recordRowError wraps err with the line of the last row read by csvReader
+func recordRowError(csvReader *csv.Reader, err error) error {
+	line, _ := csvReader.FieldPos(0)
+	return &RowError{Row: line, Err: err}
+}
+
+// postDimensionOptions sends a POST request to the dataset API for each of the dimension options
+func (svc *Service) postDimensionOptions(ctx context.Context, instanceID string, dimensionOptions map[string]dataset.OptionPost, job *jobs.Job) (err error) {
+	posted := 0
+	job.SetOptionsTotal(len(dimensionOptions))
+	ctx, span := tracer.Start(ctx, "dataset api post dimension options", trace.WithAttributes(
+		attribute.String("instance_id", instanceID),
+		attribute.Int("dimension_options", len(dimensionOptions)),
+	))
+	defer func() {
+		svc.metrics().OptionsPosted(posted)
+		span.SetAttributes(attribute.Int("dimension_options_posted", posted))
+		endSpan(span, err)
+	}()
 
 	for optionKey, optionToPost := range dimensionOptions {
-		if _, err := svc.DatasetClient.PostInstanceDimensions(ctx, svc.AuthToken, instanceID, optionToPost, headers.IfMatchAnyETag); err != nil {
+		start := time.Now()
+		_, err := svc.DatasetClient.PostInstanceDimensions(ctx, svc.AuthToken, instanceID, optionToPost, headers.IfMatchAnyETag)
+		svc.observeDatasetAPIRequest(endpointPostInstanceDimensions, start, err)
+		if err != nil {
 			log.Error(ctx, "encountered error sending request to dataset api", err, log.Data{"instance_id": instanceID, "dimension_option": optionKey})
-			return instanceID, err
+			return err
 		}
+		posted++
+		job.OptionPosted()
 	}
+	return nil
+}
 
-	// PUT request to dataset API to pass the header row and the number of observations that exist against this job instance
-	_, err = svc.DatasetClient.PutInstanceData(
-		ctx,
-		svc.AuthToken,
-		instanceID,
-		dataset.JobInstance{
-			HeaderNames:          headerRow,
-			NumberOfObservations: numberOfObservations,
-		}, headers.IfMatchAnyETag)
-	if err != nil {
-		log.Error(ctx, "encountered error sending request to the dataset api", err, log.Data{"instance_id": instanceID, "number_of_observations": numberOfObservations})
-		return instanceID, err
-	}
-	log.Info(ctx, "successfully sent request to dataset API", log.Data{"instance_id": instanceID, "number_of_observations": numberOfObservations})
-
-	// Once csv file has been iterated over and there were no errors,
-	// send a completed messsage to the dimensions-extracted topic
+// sourceFile is the csv file the dimensions are extracted from. The checksums of its content are computed as it is read:
+// its SHA-256 checksum, and its MD5 checksum if the source expects the content to have one.
+type sourceFile struct {
+	io.ReadCloser
+	s3URL            string
+	eTag             string
+	hash             hash.Hash
+	expectedChecksum string
+	md5              hash.Hash
+	expectedMD5      string
+}
 
-	svc.DimensionExtractedProducer.Channels().Output <- producerMessage
+func newSourceFile(file io.ReadCloser, info *FileInfo) *sourceFile {
+	f := &sourceFile{
+		ReadCloser:       file,
+		s3URL:            info.URL,
+		eTag:             info.ETag,
+		hash:             sha256.New(),
+		expectedChecksum: info.Checksum,
+		expectedMD5:      info.ContentMD5,
+	}
+	if f.expectedMD5 != "" {
+		f.md5 = md5.New()
+	}
+	return f
+}
 
-	return instanceID, nil
+func (f *sourceFile) Read(p []byte) (int, error) {
+	n, err := f.ReadCloser.Read(p)
+	f.hash.Write(p[:n])
+	if f.md5 != nil {
+		f.md5.Write(p[:n])
+	}
+	return n, err
 }
 
-func (svc *Service) retrieveData(ctx context.Context, message kafka.Message) ([]byte, string, io.ReadCloser, error) {
+// checksum returns the hex encoded SHA-256 checksum of the (decrypted) content read so far
+func (f *sourceFile) checksum() string {
+	return hex.EncodeToString(f.hash.Sum(nil))
+}
 
-	event, err := readMessage(message.GetData())
-	if err != nil {
-		log.Error(ctx, "error reading message", err, log.Data{"schema": "failed to unmarshal event"})
-		return nil, "", nil, err
+// verifyChecksums returns an error if the checksums of the content read differ from those the source expects it to have
+func (f *sourceFile) verifyChecksums() error {
+	if f.expectedChecksum != "" && !strings.EqualFold(f.expectedChecksum, f.checksum()) {
+		return fmt.Errorf("file checksum '%s' does not match the checksum '%s' of the source", f.checksum(), f.expectedChecksum)
 	}
+	if f.md5 != nil {
+		if md5Checksum := hex.EncodeToString(f.md5.Sum(nil)); !strings.EqualFold(f.expectedMD5, md5Checksum) {
+			return fmt.Errorf("file md5 checksum '%s' does not match the etag '%s'", md5Checksum, f.expectedMD5)
+		}
+	}
+	return nil
+}
+
+// retrieveData returns the key identifying the event (if processed events are recorded) and the file to extract the dimensions from.
+// errDuplicateEvent is returned if the same event has already been processed.
+func (svc *Service) retrieveData(ctx context.Context, event *InputFileAvailable, job *jobs.Job) (string, *sourceFile, error) {
 
 	logData := log.Data{"instance_id": event.InstanceID, "event": event}
 
-	s3URL, err := event.S3URL()
+	scheme, source, err := svc.fileSources().fileSource(event.FileURL)
 	if err != nil {
 		log.Error(ctx, "encountered error parsing file url", err, logData)
-		return nil, event.InstanceID, nil, err
-	}
-	s3URLStr, err := s3URL.String(s3client.AliasVirtualHostedStyle)
-	if err != nil {
-		log.Error(ctx, "unable to represent s3 url from parsed file url", err, logData)
-		return nil, event.InstanceID, nil, err
+		return "", nil, classify(ErrorClassMessage, err)
 	}
 
 	logData["file_url"] = event.FileURL
-	logData["s3_url"] = s3URLStr
-	logData["bucket"] = s3URL.BucketName
-	logData["filename"] = s3URL.Key
-
 	log.Info(ctx, "event received", logData)
 
-	// Get S3 Client corresponding to the Bucket extracted from URL, or create one if not available
-	s3, ok := svc.S3Clients[s3URL.BucketName]
-	if !ok {
-		log.Warn(ctx, "retreiving data from unexpected s3 bucket", log.Data{"RequestedBucket": s3URL.BucketName})
-		cfg, err := config.Get()
+	info, err := source.Stat(ctx, event.FileURL)
+	if err != nil {
+		return "", nil, err
+	}
+	logData["source_url"] = info.URL
+	logData["etag"] = info.ETag
+	logData["size"] = info.Size
+	logData["content_type"] = info.ContentType
+
+	if err := svc.FileChecks.check(info); err != nil {
+		log.Error(ctx, "the csv file is rejected before being parsed", err, logData)
+		return "", nil, classify(ErrorClassFileCheck, err)
+	}
+
+	// a dry run does not prevent the same file from being processed afterwards
+	var eventKey string
+	if !event.DryRun() {
+		eventKey, err = svc.checkDuplicate(ctx, event.InstanceID, info.URL, info.ETag)
 		if err != nil {
-			log.Error(ctx, "unable to retrieve config", err, logData)
-			return nil, event.InstanceID, nil, err
+			return "", nil, err
 		}
+	}
 
-		if cfg.LocalstackHost != "" {
-			s3 = s3client.NewClientWithConfig(s3URL.BucketName, *svc.AwsConfig, func(o *awsS3.Options) {
-				o.BaseEndpoint = aws.String(cfg.LocalstackHost)
-				o.UsePathStyle = true
-			})
-		} else {
-			s3 = s3client.NewClientWithConfig(s3URL.BucketName, *svc.AwsConfig)
+	var psk []byte
+	if !svc.EncryptionDisabled {
+		if psk, err = svc.readPSK(ctx, info.Key); err != nil {
+			return "", nil, classify(ErrorClassVault, err)
 		}
 	}
 
-	var output io.ReadCloser
+	output, err := source.Open(ctx, info, psk)
+	if err != nil {
+		return "", nil, err
+	}
+	// the size may only be known once the file is opened
+	if err := svc.FileChecks.checkSize(info); err != nil {
+		output.Close()
+		log.Error(ctx, "the csv file is rejected before being parsed", err, logData)
+		return "", nil, classify(ErrorClassFileCheck, err)
+	}
 
-	if !svc.EncryptionDisabled {
-		path := svc.VaultPath + "/" + s3URL.Key
-		vaultKey := "key"
+	log.Info(ctx, "file successfully read from source", logData)
 
-		pskStr, err := svc.VaultClient.ReadKey(path, vaultKey)
-		if err != nil {
-			return nil, event.InstanceID, nil, err
-		}
-		psk, err := hex.DecodeString(pskStr)
-		if err != nil {
-			return nil, event.InstanceID, nil, err
-		}
+	// count the bytes of the (decrypted) file as they are read
+	output = &countingReadCloser{ReadCloser: output, count: func(count int) {
+		svc.metrics().BytesRead(scheme, count)
+		job.AddBytesRead(count)
+	}}
 
-		output, _, err = s3.GetWithPSK(ctx, s3URL.Key, psk)
-		if err != nil {
-			log.Error(ctx, "encountered error retrieving and decrypting csv file", err, logData)
-			return nil, event.InstanceID, nil, err
+	return eventKey, newSourceFile(output, info), nil
+}
+
+// checkDuplicate returns the key identifying the event, from the normalised S3 URL and the ETag of the S3 object, or
+// errDuplicateEvent if an event with the same key has already been processed. An empty key is returned if processed events are not recorded.
+func (svc *Service) checkDuplicate(ctx context.Context, instanceID, s3URL, eTag string) (string, error) {
+	if svc.ProcessedEvents == nil {
+		return "", nil
+	}
+
+	eventKey := dedup.Key(instanceID, s3URL, eTag)
+
+	seen, err := svc.ProcessedEvents.Seen(ctx, eventKey)
+	if err != nil {
+		log.Warn(ctx, "failed to check whether the event has already been processed, it will be processed", log.FormatErrors([]error{err}), log.Data{"instance_id": instanceID, "event_key": eventKey})
+		return eventKey, nil
+	}
+	if seen {
+		log.Info(ctx, "duplicate event, the file has already been processed for this instance", log.Data{"instance_id": instanceID, "event_key": eventKey})
+		return eventKey, errDuplicateEvent
+	}
+	return eventKey, nil
+}
+
+// readPSK reads the pre-shared key used to encrypt the S3 object from vault, and decodes it
+func (svc *Service) readPSK(ctx context.Context, s3Key string) (psk []byte, err error) {
+	_, span := tracer.Start(ctx, "vault read psk", trace.WithAttributes(attribute.String("s3.key", s3Key)))
+	defer func() { endSpan(span, err) }()
+
+	path := svc.VaultPath + "/" + s3Key
+	vaultKey := "key"
+
+	pskStr, err := svc.VaultClient.ReadKey(path, vaultKey)
+	if err != nil {
+		return nil, err
+	}
+	return DecodePSK(pskStr)
+}
+
+// observeDatasetAPIRequest records the duration and status of a request to the dataset API
+func (svc *Service) observeDatasetAPIRequest(endpoint string, start time.Time, err error) {
+	svc.metrics().DatasetAPIRequest(endpoint, datasetAPIStatus(err), time.Since(start))
+}
+
+// datasetAPIStatus returns the status code of the dataset API response which caused err,
+// '2xx' if there was no error, or 'error' if no response was received
+func datasetAPIStatus(err error) string {
+	if err == nil {
+		return "2xx"
+	}
+	var responseErr interface{ Code() int }
+	if errors.As(err, &responseErr) {
+		return strconv.Itoa(responseErr.Code())
+	}
+	return "error"
+}
+
+// metrics returns the metrics recorder of the service, or one which discards everything if none was provided
+func (svc *Service) metrics() Metrics {
+	if svc.Metrics == nil {
+		return nopMetrics{}
+	}
+	return svc.Metrics
+}
+
+// fileSources returns the file sources of the service, or only the S3 source of its clients if none are set
+func (svc *Service) fileSources() FileSources {
+	if svc.FileSources == nil {
+		return FileSources{FileSchemeS3: &S3Source{Clients: svc.S3Clients, AwsConfig: svc.AwsConfig}}
+	}
+	return svc.FileSources
+}
+
+// endSpan records the error on the span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// readMessage decodes the event, as JSON or Avro according to its content type header, or to the configured encoding if it has none.
+// If a schema registry is used, Avro events are decoded with the schema they were written with, otherwise with the extended schema,
+// or with the original one if they were produced without the optional fields.
+func (svc *Service) readMessage(ctx context.Context, message kafka.Message) (*InputFileAvailable, error) {
+	encoding, err := svc.messageEncoding(message)
+	if err != nil {
+		return nil, classify(ErrorClassMessage, err)
+	}
+
+	eventValue := message.GetData()
+	if encoding == config.MessageEncodingJSON {
+		var event InputFileAvailable
+		if err := schema.InputFileAvailableJSONSchema.Unmarshal(eventValue, &event); err != nil {
+			return nil, classify(ErrorClassMessage, err)
 		}
-	} else {
-		output, _, err = s3.Get(ctx, s3URL.Key)
+		return &event, nil
+	}
+
+	if svc.SchemaRegistry == nil {
+		event, err := readMessage(eventValue)
 		if err != nil {
-			log.Error(ctx, "encountered error retrieving csv file", err, logData)
-			return nil, event.InstanceID, nil, err
+			return nil, classify(ErrorClassMessage, err)
 		}
+		return event, nil
 	}
 
-	log.Info(ctx, "file successfully read from aws", logData)
+	writerSchema, payload, err := svc.SchemaRegistry.Decode(ctx, eventValue)
+	if errors.Is(err, registry.ErrInvalidWireFormat) {
+		return nil, classify(ErrorClassMessage, err)
+	}
+	if err != nil {
+		// only the failures to reach the registry are retried, an unknown schema ID would still be unknown
+		if registry.IsTransient(err) {
+			err = &RetryableError{Err: err}
+		}
+		return nil, classify(ErrorClassRegistry, err)
+	}
 
-	producerMessage, err := schema.DimensionsExtractedSchema.Marshal(&DimensionExtracted{
-		FileURL:    s3URLStr,
-		InstanceID: event.InstanceID,
-	})
+	fields, err := schema.FieldNames(writerSchema)
 	if err != nil {
-		output.Close()
-		return nil, event.InstanceID, nil, err
+		return nil, classify(ErrorClassMessage, err)
+	}
+	event, err := readMessageWithSchema(writerSchema, fields, payload)
+	if err != nil {
+		return nil, classify(ErrorClassMessage, err)
 	}
+	return event, nil
+}
 
-	return producerMessage, event.InstanceID, output, nil
+// messageEncoding returns the encoding of the message given by its content type header, or the configured encoding if it has none
+func (svc *Service) messageEncoding(message kafka.Message) (string, error) {
+	contentType := message.GetHeader(schema.ContentTypeHeaderKey)
+	if contentType == "" {
+		if svc.MessageEncoding == config.MessageEncodingJSON {
+			return config.MessageEncodingJSON, nil
+		}
+		return config.MessageEncodingAvro, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("invalid content type '%s': %w", contentType, err)
+	}
+	switch mediaType {
+	case schema.ContentTypeJSON:
+		return config.MessageEncodingJSON, nil
+	case schema.ContentTypeAvro:
+		return config.MessageEncodingAvro, nil
+	default:
+		return "", fmt.Errorf("content type not supported: '%s' Supported content types: %v", contentType, []string{schema.ContentTypeAvro, schema.ContentTypeJSON})
+	}
 }
 
+// readMessage decodes the event with the extended schema, or with the original one if it was produced without the optional fields
 func readMessage(eventValue []byte) (*InputFileAvailable, error) {
 	var i InputFileAvailable
 
-	if err := schema.InputFileAvailableSchema.Unmarshal(eventValue, &i); err != nil {
+	if err := schema.InputFileAvailableV2Schema.Unmarshal(eventValue, &i); err == nil {
+		return &i, nil
+	}
+
+	return readOriginalMessage(schema.InputFileAvailableSchema, eventValue)
+}
+
+// readMessageWithSchema decodes the event with the schema it was written with, which must have at least the fields
+// of the original schema. The optional fields are only decoded if the schema has all of them.
+func readMessageWithSchema(writerSchema *avro.Schema, fields []string, eventValue []byte) (*InputFileAvailable, error) {
+	v2Fields, err := schema.FieldNames(schema.InputFileAvailableV2Schema)
+	if err != nil {
+		return nil, err
+	}
+	v1Fields, err := schema.FieldNames(schema.InputFileAvailableSchema)
+	if err != nil {
 		return nil, err
 	}
 
-	return &i, nil
+	switch {
+	case containsAll(fields, v2Fields):
+		var i InputFileAvailable
+		if err := writerSchema.Unmarshal(eventValue, &i); err != nil {
+			return nil, err
+		}
+		return &i, nil
+	case containsAll(fields, v1Fields):
+		return readOriginalMessage(writerSchema, eventValue)
+	default:
+		return nil, fmt.Errorf("the schema of the message is not an input-file-available schema, its fields are %v", fields)
+	}
+}
+
+// readOriginalMessage decodes the fields of the original schema only
+func readOriginalMessage(writerSchema *avro.Schema, eventValue []byte) (*InputFileAvailable, error) {
+	var v1 struct {
+		FileURL    string `avro:"file_url"`
+		InstanceID string `avro:"instance_id"`
+	}
+	if err := writerSchema.Unmarshal(eventValue, &v1); err != nil {
+		return nil, err
+	}
+
+	return &InputFileAvailable{FileURL: v1.FileURL, InstanceID: v1.InstanceID}, nil
+}
+
+func containsAll(fields, expected []string) bool {
+	for _, e := range expected {
+		if !slices.Contains(fields, e) {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeDimensionsExtracted encodes the dimensions extracted message as JSON, if configured, or with the v2 Avro schema,
+// prefixed with the ID of the schema if a schema registry is used
+func (svc *Service) encodeDimensionsExtracted(ctx context.Context, dimensionExtracted *DimensionExtracted) ([]byte, error) {
+	if svc.MessageEncoding == config.MessageEncodingJSON {
+		message, err := schema.DimensionsExtractedJSONSchema.Marshal(dimensionExtracted)
+		if err != nil {
+			return nil, classify(ErrorClassMessage, err)
+		}
+		return message, nil
+	}
+
+	if svc.SchemaRegistry == nil {
+		message, err := schema.DimensionsExtractedV2Schema.Marshal(dimensionExtracted)
+		if err != nil {
+			return nil, classify(ErrorClassMessage, err)
+		}
+		return message, nil
+	}
+
+	message, err := svc.SchemaRegistry.Encode(ctx, svc.DimensionsExtractedSubject, schema.DimensionsExtractedV2Schema, dimensionExtracted)
+	if err != nil {
+		return nil, classify(ErrorClassRegistry, err)
+	}
+	return message, nil
+}
+
+// produceDimensionsExtracted encodes the dimensions extracted message and sends it. If the schema registry or kafka fail,
+// only the encoding and sending are tried again, after ProduceRetryBackoff and up to ProduceMaxRetries times,
+// so that the file is not extracted again and the dataset API is not updated again. If they still fail,
+// a retryable error is returned, so that the message is handled again up to HandlerMaxRetries times.
+func (svc *Service) produceDimensionsExtracted(ctx context.Context, dimensionExtracted *DimensionExtracted) error {
+	var message []byte
+	for attempt := 1; ; attempt++ {
+		var err error
+		if message == nil {
+			message, err = svc.encodeDimensionsExtracted(ctx, dimensionExtracted)
+		}
+		if err == nil {
+			err = classify(ErrorClassKafka, svc.DimensionExtractedProducer.SendWithKey(ctx, dimensionExtracted.InstanceID, message))
+		}
+
+		var classifiedErr *ClassifiedError
+		if err == nil || !errors.As(err, &classifiedErr) || classifiedErr.Class == ErrorClassMessage {
+			return err
+		}
+		if attempt > svc.ProduceMaxRetries {
+			return classify(classifiedErr.Class, &RetryableError{Err: classifiedErr.Err})
+		}
+
+		log.Warn(ctx, "failed to produce dimensions extracted message, it will be produced again", log.FormatErrors([]error{err}), log.Data{
+			"instance_id":   dimensionExtracted.InstanceID,
+			"attempt":       attempt,
+			"max_retries":   svc.ProduceMaxRetries,
+			"retry_backoff": svc.ProduceRetryBackoff.String(),
+		})
+
+		select {
+		case <-time.After(svc.ProduceRetryBackoff):
+		case <-ctx.Done():
+			return err
+		}
+	}
 }
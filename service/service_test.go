@@ -2,19 +2,32 @@ package service_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"io"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	"github.com/ONSdigital/dp-dimension-extractor/config"
+	"github.com/ONSdigital/dp-dimension-extractor/dedup"
+	"github.com/ONSdigital/dp-dimension-extractor/jobs"
+	"github.com/ONSdigital/dp-dimension-extractor/registry"
 	"github.com/ONSdigital/dp-dimension-extractor/schema"
 	"github.com/ONSdigital/dp-dimension-extractor/service"
 	"github.com/ONSdigital/dp-dimension-extractor/service/mock"
 	kafka "github.com/ONSdigital/dp-kafka/v2"
 	"github.com/ONSdigital/dp-kafka/v2/kafkatest"
+	dphttp "github.com/ONSdigital/dp-net/v2/http"
+	"github.com/ONSdigital/dp-net/v2/request"
 	s3client "github.com/ONSdigital/dp-s3/v3"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -27,6 +40,8 @@ const (
 	validInstanceID = "123"
 	validAuthToken  = "myAuthToken"
 	validVaultPath  = "myVaultPath"
+	validETag       = `"etag"`
+	validVersion    = "1.2.3"
 )
 
 // testing variables
@@ -36,6 +51,7 @@ var (
 		Version: dataset.Version{
 			ID:         "versionId",
 			InstanceID: validInstanceID,
+			State:      dataset.StateSubmitted.String(),
 			Dimensions: []dataset.VersionDimension{
 				{ID: "Time", Name: "time"},
 				{ID: "Geography", Name: "geography"},
@@ -47,14 +63,13 @@ var (
 V4_0,Time,Time,UK-only,Geography,Cpih1dim1aggid,Aggregate
 93.7,Month,Mar-12,K02000001,          ,cpih1dim1T80000,08 Communication
 `
-	testChannels = kafka.CreateProducerChannels()
 )
 
 var ctx = context.Background()
 
 // mock functions for testing
 var (
-	mockChannelsFunc    = func() *kafka.ProducerChannels { return testChannels }
+	mockSendFunc        = func(ctx context.Context, key string, message []byte) error { return nil }
 	mockGetInstanceFunc = func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, instanceID string, ifMatch string) (dataset.Instance, string, error) {
 		if instanceID == validInstanceID {
 			return testInstance, "", nil
@@ -74,6 +89,12 @@ var (
 		}
 		return io.NopCloser(bytes.NewReader([]byte(validCsvContent))), nil, nil
 	}
+	mockHeadFunc = func(ctx context.Context, key string) (*s3.HeadObjectOutput, error) {
+		if key != validS3ObjKey {
+			return nil, errors.New("wrong S3 Key")
+		}
+		return &s3.HeadObjectOutput{ETag: aws.String(validETag)}, nil
+	}
 	mockGetWithPskFunc = func(ctx context.Context, key string, psk []byte) (io.ReadCloser, *int64, error) {
 		if key != validS3ObjKey {
 			return nil, nil, errors.New("wrong S3 Key")
@@ -147,7 +168,7 @@ func TestHandleMessage(t *testing.T) {
 			PostInstanceDimensionsFunc: mockPostInstanceFunc,
 			PutInstanceDataFunc:        mockPutInstanceFunc,
 		}
-		mockS3Client := &mock.S3ClientMock{GetFunc: mockGetFunc, GetWithPSKFunc: mockGetWithPskFunc}
+		mockS3Client := &mock.S3ClientMock{GetFunc: mockGetFunc, GetWithPSKFunc: mockGetWithPskFunc, HeadFunc: mockHeadFunc}
 
 		Convey("With a service which does not expect any external call", func() {
 			svc := &service.Service{
@@ -174,11 +195,11 @@ func TestHandleMessage(t *testing.T) {
 					FileURL:    "wrongS3PathFormat",
 					InstanceID: validInstanceID,
 				}
-				msgPayload, err := schema.InputFileAvailableSchema.Marshal(msgIn)
+				msgPayload, err := schema.InputFileAvailableV2Schema.Marshal(msgIn)
 				So(err, ShouldBeNil)
 				msg := kafkatest.NewMessage(msgPayload, 1)
 				_, err = svc.HandleMessage(ctx, msg)
-				So(err, ShouldResemble, errors.New("could not find bucket or filename in file path-style url wrongS3PathFormat"))
+				So(err, ShouldResemble, &service.ClassifiedError{Class: service.ErrorClassMessage, Err: errors.New("could not find bucket or filename in file path-style url wrongS3PathFormat")})
 			})
 
 		})
@@ -187,13 +208,14 @@ func TestHandleMessage(t *testing.T) {
 
 			svc := &service.Service{
 				AuthToken:                  validAuthToken,
-				DimensionExtractedProducer: &mock.KafkaProducerMock{ChannelsFunc: mockChannelsFunc},
+				DimensionExtractedProducer: &mock.KafkaProducerMock{SendWithKeyFunc: mockSendFunc},
 				EncryptionDisabled:         true,
 				DatasetClient:              mockDatasetClient,
 				AwsConfig:                  nil,
 				S3Clients:                  map[string]service.S3Client{validBucket: mockS3Client},
 				VaultClient:                mockVaultClient,
 				VaultPath:                  validVaultPath,
+				ExtractorVersion:           validVersion,
 			}
 
 			Convey("When a valid message with unexpected instance ID is received, HandleMessage returns error trying to get the instance", func() {
@@ -201,11 +223,11 @@ func TestHandleMessage(t *testing.T) {
 					FileURL:    validS3URL,
 					InstanceID: "wrongInstance",
 				}
-				msgPayload, err := schema.InputFileAvailableSchema.Marshal(msgIn)
+				msgPayload, err := schema.InputFileAvailableV2Schema.Marshal(msgIn)
 				So(err, ShouldBeNil)
 				msg := kafkatest.NewMessage(msgPayload, 1)
 				_, err = svc.HandleMessage(ctx, msg)
-				So(err, ShouldResemble, errors.New("unexpected instance ID"))
+				So(err, ShouldResemble, &service.ClassifiedError{Class: service.ErrorClassDatasetAPI, Err: errors.New("unexpected instance ID")})
 				validateS3Get(mockS3Client, validS3ObjKey)
 				So(len(mockVaultClient.ReadKeyCalls()), ShouldEqual, 0)
 				So(len(mockDatasetClient.GetInstanceCalls()), ShouldEqual, 1)
@@ -214,15 +236,18 @@ func TestHandleMessage(t *testing.T) {
 			})
 
 			Convey("When a valid message pointing to an inexistent S3 Key is received, HandleMessage returns error while trying to get the object from S3", func() {
+				mockS3Client.HeadFunc = func(ctx context.Context, key string) (*s3.HeadObjectOutput, error) {
+					return &s3.HeadObjectOutput{ETag: aws.String(validETag)}, nil
+				}
 				msgIn := &service.InputFileAvailable{
 					FileURL:    "s3://csv-exported/dir1/inexistent.csv",
 					InstanceID: validInstanceID,
 				}
-				msgPayload, err := schema.InputFileAvailableSchema.Marshal(msgIn)
+				msgPayload, err := schema.InputFileAvailableV2Schema.Marshal(msgIn)
 				So(err, ShouldBeNil)
 				msg := kafkatest.NewMessage(msgPayload, 1)
 				_, err = svc.HandleMessage(ctx, msg)
-				So(err, ShouldResemble, errors.New("wrong S3 Key"))
+				So(err, ShouldResemble, &service.ClassifiedError{Class: service.ErrorClassS3, Err: errors.New("wrong S3 Key")})
 				validateS3Get(mockS3Client, "dir1/inexistent.csv")
 				So(len(mockVaultClient.ReadKeyCalls()), ShouldEqual, 0)
 				So(len(mockDatasetClient.GetInstanceCalls()), ShouldEqual, 0)
@@ -230,17 +255,21 @@ func TestHandleMessage(t *testing.T) {
 				So(len(mockDatasetClient.PutInstanceDataCalls()), ShouldEqual, 0)
 			})
 
-			Convey("When a valid message is received, HandleMessage extracts the dimensions, perform a POST for each one, and a PUT to update the instance", func(c C) {
-				go func() {
-					expectedProducerMessage, err := schema.DimensionsExtractedSchema.Marshal(&service.DimensionExtracted{
-						FileURL:    validS3URL,
-						InstanceID: validInstanceID,
-					})
-					c.So(err, ShouldBeNil)
-					producedMessage := <-testChannels.Output
-					c.So(producedMessage, ShouldResemble, expectedProducerMessage)
-				}()
+			Convey("When the metadata of the S3 object cannot be retrieved, HandleMessage returns an error without getting the object", func() {
+				msgIn := &service.InputFileAvailable{
+					FileURL:    "s3://csv-exported/dir1/inexistent.csv",
+					InstanceID: validInstanceID,
+				}
+				msgPayload, err := schema.InputFileAvailableV2Schema.Marshal(msgIn)
+				So(err, ShouldBeNil)
+				_, err = svc.HandleMessage(ctx, kafkatest.NewMessage(msgPayload, 1))
+				So(err, ShouldResemble, &service.ClassifiedError{Class: service.ErrorClassS3, Err: errors.New("wrong S3 Key")})
+				So(len(mockS3Client.HeadCalls()), ShouldEqual, 1)
+				So(len(mockS3Client.GetCalls()), ShouldEqual, 0)
+				So(len(mockDatasetClient.GetInstanceCalls()), ShouldEqual, 0)
+			})
 
+			Convey("When a valid message is received, HandleMessage extracts the dimensions, perform a POST for each one, and a PUT to update the instance", func() {
 				_, err := svc.HandleMessage(ctx, createValidMessage())
 				So(err, ShouldBeNil)
 
@@ -256,33 +285,256 @@ func TestHandleMessage(t *testing.T) {
 				validatePutInstance(mockDatasetClient, &dataset.JobInstance{
 					HeaderNames:          []string{"V4_0", "Time", "Time", "UK-only", "Geography", "Cpih1dim1aggid", "Aggregate"},
 					NumberOfObservations: 1})
+				validateProduced(svc.DimensionExtractedProducer.(*mock.KafkaProducerMock))
+			})
+
+			Convey("When a valid message is handled with a context carrying a request ID, the same context is used for every outbound call", func() {
+				reqCtx := request.WithRequestId(ctx, "abcdef123456")
+				_, err := svc.HandleMessage(reqCtx, createValidMessage())
+				So(err, ShouldBeNil)
+
+				So(request.GetRequestId(mockS3Client.GetCalls()[0].Ctx), ShouldEqual, "abcdef123456")
+				So(request.GetRequestId(mockDatasetClient.GetInstanceCalls()[0].Ctx), ShouldEqual, "abcdef123456")
+				for _, call := range mockDatasetClient.PostInstanceDimensionsCalls() {
+					So(request.GetRequestId(call.Ctx), ShouldEqual, "abcdef123456")
+				}
+				So(request.GetRequestId(mockDatasetClient.PutInstanceDataCalls()[0].Ctx), ShouldEqual, "abcdef123456")
+				So(request.GetRequestId(svc.DimensionExtractedProducer.(*mock.KafkaProducerMock).SendWithKeyCalls()[0].Ctx), ShouldEqual, "abcdef123456")
+			})
+
+			Convey("When a valid message is received but kafka fails to deliver the dimensions extracted message once, only the message is sent again", func() {
+				errProduce := errors.New("kafka: not enough in-sync replicas")
+				mockProducer := &mock.KafkaProducerMock{}
+				mockProducer.SendWithKeyFunc = func(ctx context.Context, key string, message []byte) error {
+					if len(mockProducer.SendWithKeyCalls()) == 1 {
+						return errProduce
+					}
+					return nil
+				}
+				svc.DimensionExtractedProducer = mockProducer
+				svc.ProduceMaxRetries = 3
+
+				instanceID, err := svc.HandleMessage(ctx, createValidMessage())
+				So(err, ShouldBeNil)
+				So(instanceID, ShouldEqual, validInstanceID)
+				So(len(mockS3Client.GetCalls()), ShouldEqual, 1)
+				So(len(mockDatasetClient.PostInstanceDimensionsCalls()), ShouldEqual, 3)
+				So(len(mockDatasetClient.PutInstanceDataCalls()), ShouldEqual, 1)
+				So(len(mockProducer.SendWithKeyCalls()), ShouldEqual, 2)
+				So(mockProducer.SendWithKeyCalls()[1].Message, ShouldResemble, mockProducer.SendWithKeyCalls()[0].Message)
+			})
+
+			Convey("When a valid message is received but kafka keeps failing to deliver the dimensions extracted message, HandleMessage returns a retryable kafka error", func() {
+				errProduce := errors.New("kafka: not enough in-sync replicas")
+				svc.DimensionExtractedProducer = &mock.KafkaProducerMock{
+					SendWithKeyFunc: func(ctx context.Context, key string, message []byte) error { return errProduce },
+				}
+				svc.ProduceMaxRetries = 2
+
+				instanceID, err := svc.HandleMessage(ctx, createValidMessage())
+				So(instanceID, ShouldEqual, validInstanceID)
+				So(err, ShouldResemble, &service.ClassifiedError{Class: service.ErrorClassKafka, Err: &service.RetryableError{Err: errProduce}})
+				So(errors.Is(err, errProduce), ShouldBeTrue)
+				So(len(mockDatasetClient.PutInstanceDataCalls()), ShouldEqual, 1)
+				So(len(svc.DimensionExtractedProducer.(*mock.KafkaProducerMock).SendWithKeyCalls()), ShouldEqual, 3)
+			})
+
+			Convey("When a valid message is received for an instance which is already completed, the event is skipped", func() {
+				mockDatasetClient.GetInstanceFunc = func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, instanceID string, ifMatch string) (dataset.Instance, string, error) {
+					return dataset.Instance{Version: dataset.Version{InstanceID: instanceID, State: dataset.StateCompleted.String()}}, "", nil
+				}
+
+				instanceID, err := svc.HandleMessage(ctx, createValidMessage())
+				So(err, ShouldBeNil)
+				So(instanceID, ShouldEqual, validInstanceID)
+				So(len(mockDatasetClient.GetInstanceCalls()), ShouldEqual, 1)
+				So(len(mockDatasetClient.PostInstanceDimensionsCalls()), ShouldEqual, 0)
+				So(len(mockDatasetClient.PutInstanceDataCalls()), ShouldEqual, 0)
+				So(len(svc.DimensionExtractedProducer.(*mock.KafkaProducerMock).SendWithKeyCalls()), ShouldEqual, 0)
+			})
+
+			Convey("When a valid message is received for an instance whose observations import has failed, the event is skipped", func() {
+				mockDatasetClient.GetInstanceFunc = func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, instanceID string, ifMatch string) (dataset.Instance, string, error) {
+					instance := testInstance
+					instance.ImportTasks = &dataset.InstanceImportTasks{
+						ImportObservations: &dataset.ImportObservationsTask{State: dataset.StateFailed.String()},
+					}
+					return instance, "", nil
+				}
+
+				_, err := svc.HandleMessage(ctx, createValidMessage())
+				So(err, ShouldBeNil)
+				So(len(mockDatasetClient.PostInstanceDimensionsCalls()), ShouldEqual, 0)
+				So(len(mockDatasetClient.PutInstanceDataCalls()), ShouldEqual, 0)
+				So(len(svc.DimensionExtractedProducer.(*mock.KafkaProducerMock).SendWithKeyCalls()), ShouldEqual, 0)
+			})
+
+			Convey("When the import of the instance fails while its dimensions are being extracted, the instance data is not updated", func() {
+				mockDatasetClient.GetInstanceFunc = func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, instanceID string, ifMatch string) (dataset.Instance, string, error) {
+					instance := testInstance
+					if len(mockDatasetClient.GetInstanceCalls()) > 1 {
+						instance.State = dataset.StateFailed.String()
+					}
+					return instance, "", nil
+				}
+
+				_, err := svc.HandleMessage(ctx, createValidMessage())
+				So(err, ShouldBeNil)
+				So(len(mockDatasetClient.GetInstanceCalls()), ShouldEqual, 2)
+				So(len(mockDatasetClient.PostInstanceDimensionsCalls()), ShouldEqual, 3)
+				So(len(mockDatasetClient.PutInstanceDataCalls()), ShouldEqual, 0)
+				So(len(svc.DimensionExtractedProducer.(*mock.KafkaProducerMock).SendWithKeyCalls()), ShouldEqual, 0)
+			})
+
+			Convey("When a valid message pointing to a csv file with a malformed row is received, HandleMessage returns an error carrying the row", func() {
+				mockS3Client.GetFunc = func(ctx context.Context, key string) (io.ReadCloser, *int64, error) {
+					return io.NopCloser(bytes.NewReader([]byte(validCsvContent + "93.7,Month,Mar-12\n"))), nil, nil
+				}
+
+				_, err := svc.HandleMessage(ctx, createValidMessage())
+				var rowErr *service.RowError
+				So(errors.As(err, &rowErr), ShouldBeTrue)
+				So(rowErr.Row, ShouldEqual, 4)
+				var classifiedErr *service.ClassifiedError
+				So(errors.As(err, &classifiedErr), ShouldBeTrue)
+				So(classifiedErr.Class, ShouldEqual, service.ErrorClassCSV)
+				So(len(mockDatasetClient.PutInstanceDataCalls()), ShouldEqual, 0)
 			})
 
+			Convey("When the same valid message is received twice by a service recording processed events, the second one is skipped", func() {
+				svc.ProcessedEvents = dedup.NewMemoryStore(10, time.Hour)
+
+				instanceID, err := svc.HandleMessage(ctx, createValidMessage())
+				So(err, ShouldBeNil)
+				So(instanceID, ShouldEqual, validInstanceID)
+				instanceID, err = svc.HandleMessage(ctx, createValidMessage())
+				So(err, ShouldBeNil)
+				So(instanceID, ShouldEqual, validInstanceID)
+
+				So(len(mockS3Client.HeadCalls()), ShouldEqual, 2)
+				So(len(mockS3Client.GetCalls()), ShouldEqual, 1)
+				So(len(mockDatasetClient.GetInstanceCalls()), ShouldEqual, 2)
+				So(len(mockDatasetClient.PutInstanceDataCalls()), ShouldEqual, 1)
+				So(len(svc.DimensionExtractedProducer.(*mock.KafkaProducerMock).SendWithKeyCalls()), ShouldEqual, 1)
+			})
+
+			Convey("When the same valid message is received again after the S3 object was replaced, it is processed again", func() {
+				eTag := validETag
+				mockS3Client.HeadFunc = func(ctx context.Context, key string) (*s3.HeadObjectOutput, error) {
+					return &s3.HeadObjectOutput{ETag: &eTag}, nil
+				}
+				svc.ProcessedEvents = dedup.NewMemoryStore(10, time.Hour)
+
+				_, err := svc.HandleMessage(ctx, createValidMessage())
+				So(err, ShouldBeNil)
+				eTag = `"another-etag"`
+				_, err = svc.HandleMessage(ctx, createValidMessage())
+				So(err, ShouldBeNil)
+
+				So(len(mockS3Client.GetCalls()), ShouldEqual, 2)
+				So(len(svc.DimensionExtractedProducer.(*mock.KafkaProducerMock).SendWithKeyCalls()), ShouldEqual, 2)
+			})
+
+			Convey("When a valid message fails to be processed by a service recording processed events, the same message is processed again", func() {
+				processedEvents := &mock.ProcessedEventStoreMock{
+					SeenFunc:   func(ctx context.Context, key string) (bool, error) { return false, nil },
+					RecordFunc: func(ctx context.Context, key string) error { return nil },
+				}
+				svc.ProcessedEvents = processedEvents
+				svc.DimensionExtractedProducer = &mock.KafkaProducerMock{
+					SendWithKeyFunc: func(ctx context.Context, key string, message []byte) error {
+						return errors.New("kafka: not enough in-sync replicas")
+					},
+				}
+
+				_, err := svc.HandleMessage(ctx, createValidMessage())
+				So(err, ShouldNotBeNil)
+
+				So(len(processedEvents.SeenCalls()), ShouldEqual, 1)
+				So(processedEvents.SeenCalls()[0].Key, ShouldEqual, validInstanceID+"|"+validS3URL+"|etag")
+				So(len(processedEvents.RecordCalls()), ShouldEqual, 0)
+			})
+
+			Convey("When a valid message is received by a service with a metrics recorder, the extraction is recorded", func() {
+				mockMetrics := &mock.MetricsMock{
+					RowsScannedFunc:       func(count int) {},
+					OptionsPostedFunc:     func(count int) {},
+					DatasetAPIRequestFunc: func(endpoint string, status string, duration time.Duration) {},
+					BytesReadFunc:         func(source string, count int) {},
+				}
+				svc.Metrics = mockMetrics
+
+				_, err := svc.HandleMessage(ctx, createValidMessage())
+				So(err, ShouldBeNil)
+
+				So(len(mockMetrics.RowsScannedCalls()), ShouldEqual, 1)
+				So(mockMetrics.RowsScannedCalls()[0].Count, ShouldEqual, 1)
+				So(len(mockMetrics.OptionsPostedCalls()), ShouldEqual, 1)
+				So(mockMetrics.OptionsPostedCalls()[0].Count, ShouldEqual, 3)
+				bytesRead := 0
+				for _, call := range mockMetrics.BytesReadCalls() {
+					So(call.Source, ShouldEqual, service.FileSchemeS3)
+					bytesRead += call.Count
+				}
+				So(bytesRead, ShouldEqual, len(validCsvContent))
+				endpoints := map[string]int{}
+				for _, call := range mockMetrics.DatasetAPIRequestCalls() {
+					So(call.Status, ShouldEqual, "2xx")
+					endpoints[call.Endpoint]++
+				}
+				So(endpoints, ShouldResemble, map[string]int{"get_instance": 2, "post_instance_dimensions": 3, "put_instance_data": 1})
+			})
+
+			Convey("When the dataset API rejects a dimension option, the status of its response is recorded", func() {
+				mockMetrics := &mock.MetricsMock{
+					RowsScannedFunc:       func(count int) {},
+					OptionsPostedFunc:     func(count int) {},
+					DatasetAPIRequestFunc: func(endpoint string, status string, duration time.Duration) {},
+					BytesReadFunc:         func(source string, count int) {},
+				}
+				svc.Metrics = mockMetrics
+				mockDatasetClient.PostInstanceDimensionsFunc = func(ctx context.Context, serviceAuthToken string, instanceID string, data dataset.OptionPost, ifMatch string) (string, error) {
+					return "", dataset.NewDatasetAPIResponse(&http.Response{StatusCode: http.StatusConflict}, "/instances/123/dimensions")
+				}
+
+				_, err := svc.HandleMessage(ctx, createValidMessage())
+				So(errors.As(err, new(*service.ClassifiedError)), ShouldBeTrue)
+				So(err.(*service.ClassifiedError).Class, ShouldEqual, service.ErrorClassDatasetAPI)
+
+				So(mockMetrics.OptionsPostedCalls()[0].Count, ShouldEqual, 0)
+				calls := mockMetrics.DatasetAPIRequestCalls()
+				So(calls[len(calls)-1].Endpoint, ShouldEqual, "post_instance_dimensions")
+				So(calls[len(calls)-1].Status, ShouldEqual, "409")
+			})
 		})
 
 		Convey("Given a service with encryption enabled", func() {
 
 			svc := &service.Service{
 				AuthToken:                  validAuthToken,
-				DimensionExtractedProducer: &mock.KafkaProducerMock{ChannelsFunc: mockChannelsFunc},
+				DimensionExtractedProducer: &mock.KafkaProducerMock{SendWithKeyFunc: mockSendFunc},
 				EncryptionDisabled:         false,
 				DatasetClient:              mockDatasetClient,
 				AwsConfig:                  nil,
 				S3Clients:                  map[string]service.S3Client{validBucket: mockS3Client},
 				VaultClient:                mockVaultClient,
 				VaultPath:                  validVaultPath,
+				ExtractorVersion:           validVersion,
 			}
 
 			Convey("When a valid message pointing to an inexistent S3 Key is received, HandleMessage returns error while trying to read the PSK from vault", func() {
+				mockS3Client.HeadFunc = func(ctx context.Context, key string) (*s3.HeadObjectOutput, error) {
+					return &s3.HeadObjectOutput{ETag: aws.String(validETag)}, nil
+				}
 				msgIn := &service.InputFileAvailable{
 					FileURL:    "s3://csv-exported/dir1/inexistent.csv",
 					InstanceID: validInstanceID,
 				}
-				msgPayload, err := schema.InputFileAvailableSchema.Marshal(msgIn)
+				msgPayload, err := schema.InputFileAvailableV2Schema.Marshal(msgIn)
 				So(err, ShouldBeNil)
 				msg := kafkatest.NewMessage(msgPayload, 1)
 				_, err = svc.HandleMessage(ctx, msg)
-				So(err, ShouldResemble, errors.New("wrong vault path"))
+				So(err, ShouldResemble, &service.ClassifiedError{Class: service.ErrorClassVault, Err: errors.New("wrong vault path")})
 				So(len(mockVaultClient.ReadKeyCalls()), ShouldEqual, 1)
 				So(len(mockS3Client.GetWithPSKCalls()), ShouldEqual, 0)
 				So(len(mockDatasetClient.GetInstanceCalls()), ShouldEqual, 0)
@@ -290,17 +542,7 @@ func TestHandleMessage(t *testing.T) {
 				So(len(mockDatasetClient.PutInstanceDataCalls()), ShouldEqual, 0)
 			})
 
-			Convey("When a valid message is received ", func(c C) {
-				go func() {
-					expectedProducerMessage, err := schema.DimensionsExtractedSchema.Marshal(&service.DimensionExtracted{
-						FileURL:    validS3URL,
-						InstanceID: validInstanceID,
-					})
-					c.So(err, ShouldBeNil)
-					producedMessage := <-testChannels.Output
-					c.So(producedMessage, ShouldResemble, expectedProducerMessage)
-				}()
-
+			Convey("When a valid message is received ", func() {
 				_, err := svc.HandleMessage(ctx, createValidMessage())
 				So(err, ShouldBeNil)
 
@@ -317,17 +559,379 @@ func TestHandleMessage(t *testing.T) {
 				validatePutInstance(mockDatasetClient, &dataset.JobInstance{
 					HeaderNames:          []string{"V4_0", "Time", "Time", "UK-only", "Geography", "Cpih1dim1aggid", "Aggregate"},
 					NumberOfObservations: 1})
+				validateProduced(svc.DimensionExtractedProducer.(*mock.KafkaProducerMock))
+			})
+		})
+	})
+}
+
+func TestHandleMessageOptionalFields(t *testing.T) {
+
+	Convey("Given a service with encryption disabled", t, func() {
+		csvContent := validCsvContent
+		mockDatasetClient := &mock.DatasetClientMock{
+			GetInstanceFunc:            mockGetInstanceFunc,
+			PostInstanceDimensionsFunc: mockPostInstanceFunc,
+			PutInstanceDataFunc:        mockPutInstanceFunc,
+		}
+		mockS3Client := &mock.S3ClientMock{
+			GetFunc: func(ctx context.Context, key string) (io.ReadCloser, *int64, error) {
+				return io.NopCloser(bytes.NewReader([]byte(csvContent))), nil, nil
+			},
+			HeadFunc: mockHeadFunc,
+		}
+		mockProducer := &mock.KafkaProducerMock{SendWithKeyFunc: mockSendFunc}
+		svc := &service.Service{
+			AuthToken:                  validAuthToken,
+			DimensionExtractedProducer: mockProducer,
+			EncryptionDisabled:         true,
+			DatasetClient:              mockDatasetClient,
+			S3Clients:                  map[string]service.S3Client{validBucket: mockS3Client},
+			ExtractorVersion:           validVersion,
+		}
+		msgIn := &service.InputFileAvailable{
+			FileURL:    validFileURL,
+			InstanceID: validInstanceID,
+		}
+
+		Convey("When a message produced with the original schema is received, the dimensions are extracted", func() {
+			msgPayload, err := schema.InputFileAvailableSchema.Marshal(&struct {
+				FileURL    string `avro:"file_url"`
+				InstanceID string `avro:"instance_id"`
+			}{FileURL: validFileURL, InstanceID: validInstanceID})
+			So(err, ShouldBeNil)
+
+			_, err = svc.HandleMessage(ctx, kafkatest.NewMessage(msgPayload, 1))
+			So(err, ShouldBeNil)
+			So(len(mockDatasetClient.PostInstanceDimensionsCalls()), ShouldEqual, 3)
+			validateProduced(mockProducer)
+		})
+
+		Convey("When a message with the dry run option is received, the file is scanned but nothing is sent", func() {
+			svc.ProcessedEvents = &mock.ProcessedEventStoreMock{}
+			msgIn.ProcessingOptions = map[string]string{service.ProcessingOptionDryRun: "true"}
+
+			_, err := svc.HandleMessage(ctx, createMessage(msgIn))
+			So(err, ShouldBeNil)
+			So(len(mockS3Client.GetCalls()), ShouldEqual, 1)
+			So(len(mockDatasetClient.PostInstanceDimensionsCalls()), ShouldEqual, 0)
+			So(len(mockDatasetClient.PutInstanceDataCalls()), ShouldEqual, 0)
+			So(len(mockProducer.SendWithKeyCalls()), ShouldEqual, 0)
+		})
+
+		Convey("When a message with the expected checksum of the file is received, the dimensions are extracted", func() {
+			checksum := sha256.Sum256([]byte(csvContent))
+			msgIn.ExpectedChecksum = strings.ToUpper(hex.EncodeToString(checksum[:]))
+
+			_, err := svc.HandleMessage(ctx, createMessage(msgIn))
+			So(err, ShouldBeNil)
+			validateProduced(mockProducer)
+		})
+
+		Convey("When a message with a different expected checksum is received, HandleMessage returns a checksum error and nothing is sent", func() {
+			msgIn.ExpectedChecksum = "0123456789abcdef"
+
+			_, err := svc.HandleMessage(ctx, createMessage(msgIn))
+			So(err, ShouldHaveSameTypeAs, &service.ClassifiedError{})
+			So(err.(*service.ClassifiedError).Class, ShouldEqual, service.ErrorClassChecksum)
+			So(len(mockDatasetClient.PostInstanceDimensionsCalls()), ShouldEqual, 0)
+			So(len(mockProducer.SendWithKeyCalls()), ShouldEqual, 0)
+		})
+
+		Convey("When a message for a gzip encoded file is received, the file is decompressed", func() {
+			var compressed bytes.Buffer
+			w := gzip.NewWriter(&compressed)
+			_, err := w.Write([]byte(validCsvContent))
+			So(err, ShouldBeNil)
+			So(w.Close(), ShouldBeNil)
+			csvContent = compressed.String()
+			msgIn.ContentEncoding = service.ContentEncodingGzip
+
+			_, err = svc.HandleMessage(ctx, createMessage(msgIn))
+			So(err, ShouldBeNil)
+			So(len(mockDatasetClient.PostInstanceDimensionsCalls()), ShouldEqual, 3)
+		})
+
+		Convey("When a message with an unsupported content encoding is received, HandleMessage returns an error", func() {
+			msgIn.ContentEncoding = "br"
+
+			_, err := svc.HandleMessage(ctx, createMessage(msgIn))
+			So(err, ShouldResemble, &service.ClassifiedError{
+				Class: service.ErrorClassMessage,
+				Err:   errors.New("content encoding not supported: 'br' Supported encodings: [identity gzip]"),
+			})
+		})
+
+		Convey("When a message with a csv dialect is received, the file is read with it", func() {
+			csvContent = strings.ReplaceAll(validCsvContent, ",", ";")
+			msgIn.CSVDialect = service.CSVDialect{Delimiter: ";"}
+
+			_, err := svc.HandleMessage(ctx, createMessage(msgIn))
+			So(err, ShouldBeNil)
+			So(len(mockDatasetClient.PostInstanceDimensionsCalls()), ShouldEqual, 3)
+		})
+
+		Convey("When a message for another dataset than the instance is received, HandleMessage returns an error", func() {
+			mockDatasetClient.GetInstanceFunc = func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, instanceID string, ifMatch string) (dataset.Instance, string, error) {
+				instance := testInstance
+				instance.Links.Dataset.ID = "cpih01"
+				return instance, "", nil
+			}
+			msgIn.DatasetID = "mid-year-pop-est"
+
+			_, err := svc.HandleMessage(ctx, createMessage(msgIn))
+			So(err, ShouldResemble, &service.ClassifiedError{
+				Class: service.ErrorClassMessage,
+				Err:   errors.New("event dataset ID 'mid-year-pop-est' does not match the instance dataset ID 'cpih01'"),
+			})
+			So(len(mockS3Client.GetCalls()), ShouldEqual, 1)
+			So(len(mockDatasetClient.PostInstanceDimensionsCalls()), ShouldEqual, 0)
+		})
+
+		Convey("When a message with the strict option is received for a file without a column for a dimension of the instance, HandleMessage returns an error", func() {
+			mockDatasetClient.GetInstanceFunc = func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, instanceID string, ifMatch string) (dataset.Instance, string, error) {
+				instance := testInstance
+				instance.Dimensions = append(instance.Dimensions, dataset.VersionDimension{ID: "Sex", Name: "sex"})
+				return instance, "", nil
+			}
+			msgIn.ProcessingOptions = map[string]string{service.ProcessingOptionStrict: "true"}
+
+			_, err := svc.HandleMessage(ctx, createMessage(msgIn))
+			So(err, ShouldResemble, &service.ClassifiedError{
+				Class: service.ErrorClassCSV,
+				Err:   errors.New("the csv file has no column for dimension 'sex'"),
+			})
+			So(len(mockDatasetClient.PostInstanceDimensionsCalls()), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestHandleMessageWithSchemaRegistry(t *testing.T) {
+
+	Convey("Given a service using a schema registry", t, func() {
+		local := registry.NewLocal()
+		mockDatasetClient := &mock.DatasetClientMock{
+			GetInstanceFunc:            mockGetInstanceFunc,
+			PostInstanceDimensionsFunc: mockPostInstanceFunc,
+			PutInstanceDataFunc:        mockPutInstanceFunc,
+		}
+		mockProducer := &mock.KafkaProducerMock{SendWithKeyFunc: mockSendFunc}
+		svc := &service.Service{
+			AuthToken:                  validAuthToken,
+			DimensionExtractedProducer: mockProducer,
+			EncryptionDisabled:         true,
+			DatasetClient:              mockDatasetClient,
+			S3Clients:                  map[string]service.S3Client{validBucket: &mock.S3ClientMock{GetFunc: mockGetFunc, HeadFunc: mockHeadFunc}},
+			ExtractorVersion:           validVersion,
+			SchemaRegistry:             registry.NewCodec(local),
+			DimensionsExtractedSubject: "dimensions-extracted-value",
+		}
+		producerCodec := registry.NewCodec(local)
+
+		Convey("When a message encoded with the original schema is received, it is decoded with it", func() {
+			msg, err := producerCodec.Encode(ctx, "input-file-available-value", schema.InputFileAvailableSchema, &struct {
+				FileURL    string `avro:"file_url"`
+				InstanceID string `avro:"instance_id"`
+			}{FileURL: validFileURL, InstanceID: validInstanceID})
+			So(err, ShouldBeNil)
+
+			_, err = svc.HandleMessage(ctx, kafkatest.NewMessage(msg, 1))
+			So(err, ShouldBeNil)
+			So(len(mockDatasetClient.PostInstanceDimensionsCalls()), ShouldEqual, 3)
+
+			Convey("Then the dimensions extracted message is encoded with the ID of the v2 schema", func() {
+				So(len(mockProducer.SendWithKeyCalls()), ShouldEqual, 1)
+				writerSchema, payload, err := producerCodec.Decode(ctx, mockProducer.SendWithKeyCalls()[0].Message)
+				So(err, ShouldBeNil)
+				So(writerSchema.Definition, ShouldEqual, schema.DimensionsExtractedV2Schema.Definition)
+
+				var producedMessage service.DimensionExtracted
+				So(schema.DimensionsExtractedV2Schema.Unmarshal(payload, &producedMessage), ShouldBeNil)
+				So(producedMessage.InstanceID, ShouldEqual, validInstanceID)
+				So(producedMessage.ExtractorVersion, ShouldEqual, validVersion)
+			})
+		})
+
+		Convey("When a message encoded with the v2 schema is received, its optional fields are decoded", func() {
+			msg, err := producerCodec.Encode(ctx, "input-file-available-value", schema.InputFileAvailableV2Schema, &service.InputFileAvailable{
+				FileURL:           validFileURL,
+				InstanceID:        validInstanceID,
+				ProcessingOptions: map[string]string{service.ProcessingOptionDryRun: "true"},
+			})
+			So(err, ShouldBeNil)
+
+			_, err = svc.HandleMessage(ctx, kafkatest.NewMessage(msg, 1))
+			So(err, ShouldBeNil)
+			So(len(mockDatasetClient.PostInstanceDimensionsCalls()), ShouldEqual, 0)
+			So(len(mockProducer.SendWithKeyCalls()), ShouldEqual, 0)
+		})
+
+		Convey("When a message without the wire format is received, HandleMessage returns a message error", func() {
+			_, err := svc.HandleMessage(ctx, createValidMessage())
+			So(err, ShouldResemble, &service.ClassifiedError{Class: service.ErrorClassMessage, Err: registry.ErrInvalidWireFormat})
+		})
+
+		Convey("When a message with a schema unknown to the registry is received, HandleMessage returns a registry error which is not retryable", func() {
+			_, err := svc.HandleMessage(ctx, kafkatest.NewMessage([]byte{0, 0, 0, 0, 9, 1}, 1))
+			So(err, ShouldHaveSameTypeAs, &service.ClassifiedError{})
+			So(err.(*service.ClassifiedError).Class, ShouldEqual, service.ErrorClassRegistry)
+			var respErr *registry.ResponseError
+			So(errors.As(err, &respErr), ShouldBeTrue)
+			So(respErr.StatusCode, ShouldEqual, http.StatusNotFound)
+			var retryableErr *service.RetryableError
+			So(errors.As(err, &retryableErr), ShouldBeFalse)
+		})
+
+		Convey("When the registry cannot be reached, HandleMessage returns a retryable registry error", func() {
+			clienter := dphttp.NewClient()
+			clienter.SetMaxRetries(0)
+			svc.SchemaRegistry = registry.NewCodec(registry.NewHTTPClient("http://localhost:0", clienter))
+			msg, err := producerCodec.Encode(ctx, "input-file-available-value", schema.InputFileAvailableV2Schema, &service.InputFileAvailable{FileURL: validFileURL, InstanceID: validInstanceID})
+			So(err, ShouldBeNil)
+
+			_, err = svc.HandleMessage(ctx, kafkatest.NewMessage(msg, 1))
+			So(err, ShouldHaveSameTypeAs, &service.ClassifiedError{})
+			So(err.(*service.ClassifiedError).Class, ShouldEqual, service.ErrorClassRegistry)
+			So(err.(*service.ClassifiedError).Err, ShouldHaveSameTypeAs, &service.RetryableError{})
+		})
+	})
+}
+
+func TestHandleMessageWithJSON(t *testing.T) {
+
+	Convey("Given a service with encryption disabled", t, func() {
+		mockDatasetClient := &mock.DatasetClientMock{
+			GetInstanceFunc:            mockGetInstanceFunc,
+			PostInstanceDimensionsFunc: mockPostInstanceFunc,
+			PutInstanceDataFunc:        mockPutInstanceFunc,
+		}
+		mockProducer := &mock.KafkaProducerMock{SendWithKeyFunc: mockSendFunc}
+		svc := &service.Service{
+			AuthToken:                  validAuthToken,
+			DimensionExtractedProducer: mockProducer,
+			EncryptionDisabled:         true,
+			DatasetClient:              mockDatasetClient,
+			S3Clients:                  map[string]service.S3Client{validBucket: &mock.S3ClientMock{GetFunc: mockGetFunc, HeadFunc: mockHeadFunc}},
+			ExtractorVersion:           validVersion,
+		}
+		jsonPayload := []byte(`{"file_url": "` + validFileURL + `", "instance_id": "` + validInstanceID + `"}`)
+
+		Convey("When a JSON message with a JSON content type header is received, it is decoded as JSON", func() {
+			msg := kafkatest.NewMessage(jsonPayload, 1, kafkatest.TestHeader{schema.ContentTypeHeaderKey: "application/json; charset=utf-8"})
+
+			_, err := svc.HandleMessage(ctx, msg)
+			So(err, ShouldBeNil)
+			So(len(mockDatasetClient.PostInstanceDimensionsCalls()), ShouldEqual, 3)
+			validateProduced(mockProducer)
+		})
+
+		Convey("When an Avro message with an Avro content type header is received by a service configured for JSON, it is decoded as Avro", func() {
+			svc.MessageEncoding = config.MessageEncodingJSON
+			msg := createValidMessage()
+			msgWithHeader := kafkatest.NewMessage(msg.GetData(), 1, kafkatest.TestHeader{schema.ContentTypeHeaderKey: schema.ContentTypeAvro})
+
+			_, err := svc.HandleMessage(ctx, msgWithHeader)
+			So(err, ShouldBeNil)
+			So(len(mockDatasetClient.PostInstanceDimensionsCalls()), ShouldEqual, 3)
+		})
+
+		Convey("When a message with an unsupported content type header is received, HandleMessage returns a message error", func() {
+			msg := kafkatest.NewMessage(jsonPayload, 1, kafkatest.TestHeader{schema.ContentTypeHeaderKey: "text/csv"})
+
+			_, err := svc.HandleMessage(ctx, msg)
+			So(err, ShouldResemble, &service.ClassifiedError{
+				Class: service.ErrorClassMessage,
+				Err:   errors.New("content type not supported: 'text/csv' Supported content types: [avro/binary application/json]"),
 			})
 		})
+
+		Convey("Given the service is configured for JSON", func() {
+			svc.MessageEncoding = config.MessageEncodingJSON
+
+			Convey("When a JSON message without a content type header is received, the dimensions extracted message is produced as JSON", func() {
+				_, err := svc.HandleMessage(ctx, kafkatest.NewMessage(jsonPayload, 1))
+				So(err, ShouldBeNil)
+				So(len(mockProducer.SendWithKeyCalls()), ShouldEqual, 1)
+
+				var producedMessage service.DimensionExtracted
+				So(schema.DimensionsExtractedJSONSchema.Unmarshal(mockProducer.SendWithKeyCalls()[0].Message, &producedMessage), ShouldBeNil)
+				So(producedMessage.FileURL, ShouldEqual, validS3URL)
+				So(producedMessage.InstanceID, ShouldEqual, validInstanceID)
+				So(producedMessage.NumberOfObservations, ShouldEqual, 1)
+				So(producedMessage.DimensionOptionCounts, ShouldResemble, map[string]string{"aggregate": "1", "geography": "1", "time": "1"})
+				So(producedMessage.ExtractorVersion, ShouldEqual, validVersion)
+			})
+
+			Convey("When a JSON message which is not valid against the schema is received, HandleMessage returns a message error", func() {
+				_, err := svc.HandleMessage(ctx, kafkatest.NewMessage([]byte(`{"file_url": "`+validFileURL+`"}`), 1))
+				So(err, ShouldHaveSameTypeAs, &service.ClassifiedError{})
+				So(err.(*service.ClassifiedError).Class, ShouldEqual, service.ErrorClassMessage)
+				So(len(mockDatasetClient.PostInstanceDimensionsCalls()), ShouldEqual, 0)
+				So(len(mockProducer.SendWithKeyCalls()), ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func TestHandleEventJobs(t *testing.T) {
+
+	Convey("Given a service tracking the extraction jobs", t, func() {
+		mockDatasetClient := &mock.DatasetClientMock{
+			GetInstanceFunc:            mockGetInstanceFunc,
+			PostInstanceDimensionsFunc: mockPostInstanceFunc,
+			PutInstanceDataFunc:        mockPutInstanceFunc,
+		}
+		svc := &service.Service{
+			AuthToken:                  validAuthToken,
+			DimensionExtractedProducer: &mock.KafkaProducerMock{SendWithKeyFunc: mockSendFunc},
+			EncryptionDisabled:         true,
+			DatasetClient:              mockDatasetClient,
+			S3Clients:                  map[string]service.S3Client{validBucket: &mock.S3ClientMock{GetFunc: mockGetFunc, HeadFunc: mockHeadFunc}},
+			Jobs:                       jobs.NewRegistry(10),
+		}
+		event := &service.InputFileAvailable{FileURL: validFileURL, InstanceID: validInstanceID}
+
+		Convey("When an event is handled successfully, its job is completed with the progress of the extraction", func() {
+			_, err := svc.HandleEvent(ctx, event)
+			So(err, ShouldBeNil)
+
+			status, ok := svc.Jobs.Get(validInstanceID)
+			So(ok, ShouldBeTrue)
+			So(status.Phase, ShouldEqual, jobs.PhaseCompleted)
+			So(status.FileURL, ShouldEqual, validFileURL)
+			So(status.RowsScanned, ShouldEqual, 1)
+			So(status.OptionsPosted, ShouldEqual, 3)
+			So(status.OptionsTotal, ShouldEqual, 3)
+			So(status.BytesRead, ShouldEqual, len(validCsvContent))
+			So(status.Finished(), ShouldBeTrue)
+		})
+
+		Convey("When an event fails to be handled, its job is failed with the error", func() {
+			mockDatasetClient.PutInstanceDataFunc = func(ctx context.Context, serviceAuthToken string, instanceID string, data dataset.JobInstance, ifMatch string) (string, error) {
+				return "", errors.New("dataset api unavailable")
+			}
+			_, err := svc.HandleEvent(ctx, event)
+			So(err, ShouldNotBeNil)
+
+			status, _ := svc.Jobs.Get(validInstanceID)
+			So(status.Phase, ShouldEqual, jobs.PhaseFailed)
+			So(status.Errors, ShouldResemble, []string{err.Error()})
+		})
 	})
 }
 
+func createMessage(msgIn *service.InputFileAvailable) kafka.Message {
+	msgPayload, err := schema.InputFileAvailableV2Schema.Marshal(msgIn)
+	So(err, ShouldBeNil)
+	return kafkatest.NewMessage(msgPayload, 1)
+}
+
 func createValidMessage() kafka.Message {
 	msgIn := &service.InputFileAvailable{
 		FileURL:    validFileURL,
 		InstanceID: validInstanceID,
 	}
-	msgPayload, err := schema.InputFileAvailableSchema.Marshal(msgIn)
+	msgPayload, err := schema.InputFileAvailableV2Schema.Marshal(msgIn)
 	So(err, ShouldBeNil)
 	return kafkatest.NewMessage(msgPayload, 1)
 }
@@ -353,13 +957,15 @@ func validateVault(mockVaultClient *mock.VaultClientMock) {
 	So(mockVaultClient.ReadKeyCalls()[0].Path, ShouldEqual, validVaultPath+"/"+validS3ObjKey)
 }
 
-// checks that GetInstance was called exactly once with expected paramters
+// checks that GetInstance was called twice, before and after the extraction, with expected paramters
 func validateGetInstance(mockDatasetClient *mock.DatasetClientMock) {
-	So(len(mockDatasetClient.GetInstanceCalls()), ShouldEqual, 1)
-	So(mockDatasetClient.GetInstanceCalls()[0].InstanceID, ShouldEqual, validInstanceID)
-	So(mockDatasetClient.GetInstanceCalls()[0].ServiceAuthToken, ShouldEqual, validAuthToken)
-	So(mockDatasetClient.GetInstanceCalls()[0].CollectionID, ShouldEqual, "")
-	So(mockDatasetClient.GetInstanceCalls()[0].UserAuthToken, ShouldEqual, "")
+	So(len(mockDatasetClient.GetInstanceCalls()), ShouldEqual, 2)
+	for _, call := range mockDatasetClient.GetInstanceCalls() {
+		So(call.InstanceID, ShouldEqual, validInstanceID)
+		So(call.ServiceAuthToken, ShouldEqual, validAuthToken)
+		So(call.CollectionID, ShouldEqual, "")
+		So(call.UserAuthToken, ShouldEqual, "")
+	}
 }
 
 // checks that postDimension was called exactly once for each item in the expected map, with the expected optionPosts depending on the name
@@ -372,6 +978,27 @@ func validatePostedDimensions(mockDatasetClient *mock.DatasetClientMock, expecte
 	}
 }
 
+// checks that the dimensions extracted message was sent exactly once, with the expected content.
+// The message is decoded, as the encoding of the dimension option counts depends on the iteration order of the map.
+func validateProduced(mockProducer *mock.KafkaProducerMock) {
+	checksum := sha256.Sum256([]byte(validCsvContent))
+	So(len(mockProducer.SendWithKeyCalls()), ShouldEqual, 1)
+	So(mockProducer.SendWithKeyCalls()[0].Key, ShouldEqual, validInstanceID)
+
+	var producedMessage service.DimensionExtracted
+	So(schema.DimensionsExtractedV2Schema.Unmarshal(mockProducer.SendWithKeyCalls()[0].Message, &producedMessage), ShouldBeNil)
+	So(producedMessage, ShouldResemble, service.DimensionExtracted{
+		FileURL:               validS3URL,
+		InstanceID:            validInstanceID,
+		HeaderNames:           []string{"V4_0", "Time", "Time", "UK-only", "Geography", "Cpih1dim1aggid", "Aggregate"},
+		NumberOfObservations:  1,
+		DimensionOptionCounts: map[string]string{"aggregate": "1", "geography": "1", "time": "1"},
+		FileChecksum:          hex.EncodeToString(checksum[:]),
+		S3ETag:                "etag",
+		ExtractorVersion:      validVersion,
+	})
+}
+
 // checks that PutInstance was called exactly once with expected paramters
 func validatePutInstance(mockDatasetClient *mock.DatasetClientMock, expectedData *dataset.JobInstance) {
 	So(len(mockDatasetClient.PutInstanceDataCalls()), ShouldEqual, 1)
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	dphttp "github.com/ONSdigital/dp-net/v2/http"
+	"github.com/ONSdigital/log.go/v2/log"
+	"golang.org/x/net/context"
+)
+
+// Schemes of the file URLs supported by the file sources
+const (
+	FileSchemeS3    = "s3"
+	FileSchemeFile  = "file"
+	FileSchemeHTTPS = "https"
+)
+
+// FileInfo describes a file of a FileSource
+type FileInfo struct {
+	// URL is the normalised URL of the file, which identifies it in the dimensions-extracted message and the processed events
+	URL string
+	// Key is the key of the pre-shared key of the file in vault, under VAULT_PATH
+	Key string
+	// ETag identifies the version of the file, if the source provides one
+	ETag string
+	// Size is the size of the file in bytes, or -1 if it is unknown
+	Size int64
+	// ContentType is the content type of the file, if the source provides one
+	ContentType string
+	// Metadata is the user-defined metadata of the file, if the source provides any
+	Metadata map[string]string
+	// Checksum is the hex encoded SHA-256 checksum the content of the file is expected to have, if the source provides one
+	Checksum string
+	// ContentMD5 is the hex encoded MD5 checksum the content of the file is expected to have, if the source provides one
+	ContentMD5 string
+}
+
+// FileSource retrieves the files of the input file available events from where their URL locates them
+type FileSource interface {
+	// Stat returns the description of the file at the URL, without reading its content
+	Stat(ctx context.Context, fileURL string) (*FileInfo, error)
+	// Open returns the content of the file, decrypted with the psk if it is not nil
+	Open(ctx context.Context, file *FileInfo, psk []byte) (io.ReadCloser, error)
+}
+
+// FileSources are the file sources supported by the service, keyed by the scheme of the URLs of their files
+type FileSources map[string]FileSource
+
+// fileSource returns the source of the file at the URL, and the scheme it is registered with, according to the scheme of the URL. URLs without scheme are S3 URLs, as are
+// http(s):// URLs of S3 hosts, or of any host if no source is registered for their scheme (i.e. path-style S3 URLs).
+func (sources FileSources) fileSource(fileURL string) (string, FileSource, error) {
+	scheme := FileSchemeS3
+	if u, err := url.Parse(fileURL); err == nil && u.Scheme != "" {
+		scheme = strings.ToLower(u.Scheme)
+		if _, ok := sources[scheme]; (scheme == "http" || scheme == FileSchemeHTTPS) && (!ok || isS3Host(u.Host)) {
+			scheme = FileSchemeS3
+		}
+	}
+
+	source, ok := sources[scheme]
+	if !ok {
+		supported := slices.Sorted(maps.Keys(sources))
+		return "", nil, fmt.Errorf("file url scheme '%s' not supported. Supported schemes: %v", scheme, supported)
+	}
+	return scheme, source, nil
+}
+
+// isS3Host returns whether the host is an S3 endpoint, e.g. s3-eu-west-1.amazonaws.com or s3.eu-west-1.amazonaws.com
+func isS3Host(host string) bool {
+	return strings.HasSuffix(host, ".amazonaws.com") && (strings.HasPrefix(host, "s3.") || strings.HasPrefix(host, "s3-"))
+}
+
+// decrypt returns the content of the file decrypted with the psk, if it is not nil
+func decrypt(file io.ReadCloser, psk []byte) (io.ReadCloser, error) {
+	if psk == nil {
+		return file, nil
+	}
+	decrypted, err := NewDecryptingReader(file, psk)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{decrypted, file}, nil
+}
+
+// LocalFileSource retrieves local files from file:// URLs, intended for local development and tests.
+// If Dir is set, only the files within it, once symbolic links are resolved, can be retrieved.
+type LocalFileSource struct {
+	Dir string
+}
+
+var _ FileSource = (*LocalFileSource)(nil)
+
+// Stat returns the URL of the file, its path as key and its modification time and size as ETag
+func (s *LocalFileSource) Stat(ctx context.Context, fileURL string) (*FileInfo, error) {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return nil, classify(ErrorClassMessage, err)
+	}
+	// symbolic links are resolved, so that they cannot point outside of Dir
+	path, err := filepath.EvalSymlinks(filepath.Clean(filepath.FromSlash(u.Path)))
+	if err != nil {
+		log.Error(ctx, "encountered error resolving local file path", err, log.Data{"file_url": fileURL})
+		return nil, classify(ErrorClassFileSource, err)
+	}
+	if s.Dir != "" {
+		dir := s.Dir
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = resolved
+		}
+		if rel, err := filepath.Rel(dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, classify(ErrorClassMessage, fmt.Errorf("file '%s' is not within %s", path, s.Dir))
+		}
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		log.Error(ctx, "encountered error retrieving local file metadata", err, log.Data{"file_url": fileURL})
+		return nil, classify(ErrorClassFileSource, err)
+	}
+	if stat.IsDir() {
+		return nil, classify(ErrorClassFileSource, fmt.Errorf("file '%s' is a directory", path))
+	}
+
+	return &FileInfo{
+		URL:         (&url.URL{Scheme: FileSchemeFile, Path: filepath.ToSlash(path)}).String(),
+		Key:         strings.TrimPrefix(filepath.ToSlash(path), "/"),
+		ETag:        fmt.Sprintf("%x-%x", stat.ModTime().UnixNano(), stat.Size()),
+		Size:        stat.Size(),
+		ContentType: mime.TypeByExtension(filepath.Ext(path)),
+	}, nil
+}
+
+// Open returns the content of the file, decrypted with the psk if it is not nil
+func (s *LocalFileSource) Open(ctx context.Context, file *FileInfo, psk []byte) (io.ReadCloser, error) {
+	u, err := url.Parse(file.URL)
+	if err != nil {
+		return nil, classify(ErrorClassMessage, err)
+	}
+	f, err := os.Open(filepath.FromSlash(u.Path))
+	if err != nil {
+		log.Error(ctx, "encountered error opening local file", err, log.Data{"file_url": file.URL})
+		return nil, classify(ErrorClassFileSource, err)
+	}
+	output, err := decrypt(f, psk)
+	if err != nil {
+		return nil, classify(ErrorClassFileSource, err)
+	}
+	return output, nil
+}
+
+// HTTPSFileSource retrieves files from https:// URLs with the HTTP client, which sends the request ID of the context
+// as the X-Request-Id header. Its timeout bounds both the request and the read of the file.
+type HTTPSFileSource struct {
+	Client dphttp.Clienter
+}
+
+var _ FileSource = (*HTTPSFileSource)(nil)
+
+// Stat requests the headers of the file, and returns its URL, the path of the URL as key and its ETag
+func (s *HTTPSFileSource) Stat(ctx context.Context, fileURL string) (*FileInfo, error) {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return nil, classify(ErrorClassMessage, err)
+	}
+	response, err := s.do(ctx, http.MethodHead, fileURL)
+	if err != nil {
+		return nil, err
+	}
+	response.Body.Close()
+
+	return &FileInfo{
+		URL:         u.String(),
+		Key:         strings.TrimPrefix(u.Path, "/"),
+		ETag:        strings.Trim(response.Header.Get("ETag"), `"`),
+		Size:        response.ContentLength,
+		ContentType: response.Header.Get("Content-Type"),
+	}, nil
+}
+
+// Open requests the file, and returns its content decrypted with the psk if it is not nil
+func (s *HTTPSFileSource) Open(ctx context.Context, file *FileInfo, psk []byte) (io.ReadCloser, error) {
+	response, err := s.do(ctx, http.MethodGet, file.URL)
+	if err != nil {
+		return nil, err
+	}
+	output, err := decrypt(response.Body, psk)
+	if err != nil {
+		return nil, classify(ErrorClassFileSource, err)
+	}
+	return output, nil
+}
+
+// do sends the request, and returns an error if it fails or its response is not successful
+func (s *HTTPSFileSource) do(ctx context.Context, method, fileURL string) (*http.Response, error) {
+	logData := log.Data{"file_url": fileURL, "method": method}
+	request, err := http.NewRequestWithContext(ctx, method, fileURL, http.NoBody)
+	if err != nil {
+		return nil, classify(ErrorClassMessage, err)
+	}
+	response, err := s.client().Do(ctx, request)
+	if err != nil {
+		log.Error(ctx, "encountered error requesting csv file", err, logData)
+		return nil, classify(ErrorClassFileSource, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		err = errors.New("unexpected response status: " + response.Status)
+		log.Error(ctx, "encountered error requesting csv file", err, logData)
+		return nil, classify(ErrorClassFileSource, err)
+	}
+	return response, nil
+}
+
+func (s *HTTPSFileSource) client() dphttp.Clienter {
+	if s.Client == nil {
+		return dphttp.DefaultClient
+	}
+	return s.Client
+}
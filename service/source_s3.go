@@ -0,0 +1,277 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	s3client "github.com/ONSdigital/dp-s3/v3"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+)
+
+// S3Source retrieves files from S3, with the client of their bucket. The files of other buckets are retrieved
+// with a new client. Both s3:// URLs and path-style https:// URLs of S3 are supported.
+// Reading an unencrypted file is resumed from where it failed up to MaxReadRetries times in a row, as long as it has not changed,
+// after ReadRetryBackoff, doubled after each attempt up to maxReadRetryBackoff.
+// If DownloadConcurrency is greater than 1, unencrypted files larger than DownloadPartSize are rather downloaded in concurrent
+// byte ranges to a temporary file in DownloadDir (or the default directory for temporary files), which is parsed from disk.
+// The content of a file is expected to have the SHA-256 checksum of its ChecksumMetadataKey metadata, if set, and the MD5
+// checksum of its ETag if VerifyETag is set and the ETag is one, i.e. for objects which are not encrypted or multipart uploads.
+type S3Source struct {
+	Clients             map[string]S3Client
+	AwsConfig           *aws.Config
+	LocalstackHost      string
+	MaxReadRetries      int
+	ReadRetryBackoff    time.Duration
+	DownloadConcurrency int
+	DownloadPartSize    int64
+	DownloadDir         string
+	ChecksumMetadataKey string
+	VerifyETag          bool
+}
+
+// maxReadRetryBackoff is the maximum period of time to wait before reading an S3 object again
+const maxReadRetryBackoff = 10 * time.Second
+
+// errObjectChanged is returned when the ETag of an S3 object is not the one it had when it was first read
+var errObjectChanged = errors.New("s3 object has changed")
+
+// md5ETag matches the ETags which are the MD5 checksum of the content of the object, unlike those of multipart uploads
+var md5ETag = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+
+var _ FileSource = (*S3Source)(nil)
+
+// parseS3URL parses the file URL into an S3Url struct. s3:// prefix is interpreted as
+// DNS-Alias-virtual-hosted style. Otherwise, path-style is assumed.
+func parseS3URL(fileURL string) (*s3client.S3Url, error) {
+	if strings.HasPrefix(fileURL, "s3:") {
+		// Assume DNS Alias Virtual Hosted style URL (e.g. s3://bucket/key)
+		return s3client.ParseAliasVirtualHostedURL(fileURL)
+	}
+	// Assume Path-style / Global-path-style URL (e.g. https://https://s3-eu-west-1.amazonaws.com/bucket/key)
+	s3URL, err := s3client.ParseGlobalPathStyleURL(fileURL)
+	if err != nil {
+		return nil, err
+	}
+	s3URL.Scheme = "s3"
+	return s3URL, nil
+}
+
+// Stat returns the s3:// URL of the file, its key and its ETag
+func (s *S3Source) Stat(ctx context.Context, fileURL string) (*FileInfo, error) {
+	logData := log.Data{"file_url": fileURL}
+
+	s3URL, err := parseS3URL(fileURL)
+	if err != nil {
+		log.Error(ctx, "encountered error parsing file url", err, logData)
+		return nil, classify(ErrorClassMessage, err)
+	}
+	s3URLStr, err := s3URL.String(s3client.AliasVirtualHostedStyle)
+	if err != nil {
+		log.Error(ctx, "unable to represent s3 url from parsed file url", err, logData)
+		return nil, classify(ErrorClassMessage, err)
+	}
+	logData["s3_url"] = s3URLStr
+	logData["bucket"] = s3URL.BucketName
+	logData["filename"] = s3URL.Key
+
+	headCtx, span := tracer.Start(ctx, "s3 head object", trace.WithAttributes(
+		attribute.String("s3.bucket", s3URL.BucketName),
+		attribute.String("s3.key", s3URL.Key),
+	))
+	head, err := s.client(ctx, s3URL.BucketName).Head(headCtx, s3URL.Key)
+	endSpan(span, err)
+	if err != nil {
+		log.Error(ctx, "encountered error retrieving csv file metadata", err, logData)
+		return nil, classify(ErrorClassS3, err)
+	}
+
+	size := int64(-1)
+	if head.ContentLength != nil {
+		size = *head.ContentLength
+	}
+	info := &FileInfo{
+		URL:         s3URLStr,
+		Key:         s3URL.Key,
+		ETag:        strings.Trim(aws.ToString(head.ETag), `"`),
+		Size:        size,
+		ContentType: aws.ToString(head.ContentType),
+		Metadata:    head.Metadata,
+	}
+	if s.ChecksumMetadataKey != "" {
+		for key, value := range head.Metadata {
+			if strings.EqualFold(key, s.ChecksumMetadataKey) {
+				info.Checksum = value
+			}
+		}
+	}
+	if s.VerifyETag && md5ETag.MatchString(info.ETag) {
+		info.ContentMD5 = info.ETag
+	}
+	return info, nil
+}
+
+// Open returns the content of the file, decrypted with the psk if it is not nil. The size of the file is updated with the
+// size of the object returned by S3, if any.
+func (s *S3Source) Open(ctx context.Context, file *FileInfo, psk []byte) (io.ReadCloser, error) {
+	s3URL, err := s3client.ParseAliasVirtualHostedURL(file.URL)
+	if err != nil {
+		return nil, classify(ErrorClassMessage, err)
+	}
+	logData := log.Data{"s3_url": file.URL, "bucket": s3URL.BucketName, "filename": s3URL.Key}
+	client := s.client(ctx, s3URL.BucketName)
+
+	var output io.ReadCloser
+	var size *int64
+	if psk != nil {
+		// the ETag of an encrypted object is the checksum of its encrypted content
+		file.ContentMD5 = ""
+
+		getCtx, span := tracer.Start(ctx, "s3 get and decrypt object", trace.WithAttributes(
+			attribute.String("s3.bucket", s3URL.BucketName),
+			attribute.String("s3.key", s3URL.Key),
+		))
+		output, size, err = client.GetWithPSK(getCtx, s3URL.Key, psk)
+		endSpan(span, err)
+		if err != nil {
+			log.Error(ctx, "encountered error retrieving and decrypting csv file", err, logData)
+			return nil, classify(ErrorClassS3, err)
+		}
+	} else if s.downloadsInParallel(file) {
+		return s.download(ctx, client, s3URL.Key, file)
+	} else {
+		getCtx, span := tracer.Start(ctx, "s3 get object", trace.WithAttributes(
+			attribute.String("s3.bucket", s3URL.BucketName),
+			attribute.String("s3.key", s3URL.Key),
+		))
+		output, size, err = client.Get(getCtx, s3URL.Key)
+		endSpan(span, err)
+		if err != nil {
+			log.Error(ctx, "encountered error retrieving csv file", err, logData)
+			return nil, classify(ErrorClassS3, err)
+		}
+		if s.MaxReadRetries > 0 && file.ETag != "" {
+			output = &resumableReader{ctx: ctx, client: client, key: s3URL.Key, eTag: file.ETag, body: output, maxRetries: s.MaxReadRetries, retryBackoff: s.ReadRetryBackoff}
+		}
+	}
+	if size != nil {
+		file.Size = *size
+	}
+	return output, nil
+}
+
+// resumableReader reads the body of an S3 object and, if reading it fails, gets the rest of the object from the offset
+// reached, as long as its ETag has not changed. Decrypting objects depends on the offset of their chunks, so encrypted
+// objects cannot be resumed. A failure to resume is retried like a failure to read, unless it is permanent.
+type resumableReader struct {
+	ctx          context.Context
+	client       S3Client
+	key          string
+	eTag         string
+	body         io.ReadCloser
+	offset       int64
+	retries      int
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+func (r *resumableReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.body.Read(p)
+		r.offset += int64(n)
+		if n > 0 {
+			r.retries = 0
+		}
+		if err == nil || err == io.EOF || r.ctx.Err() != nil || r.retries >= r.maxRetries || isPermanentReadError(err) {
+			return n, err
+		}
+
+		r.retries++
+		logData := log.Data{"s3_key": r.key, "etag": r.eTag, "offset": r.offset, "attempt": r.retries}
+		log.Warn(r.ctx, "failed to read s3 object, resuming from the offset reached", log.FormatErrors([]error{err}), logData)
+		r.body.Close()
+		r.body = io.NopCloser(errReader{err})
+		if !waitReadRetry(r.ctx, r.retryBackoff, r.retries) {
+			return n, err
+		}
+		body, rangeErr := r.client.GetRange(r.ctx, r.key, r.eTag, r.offset, 0)
+		if rangeErr != nil {
+			log.Warn(r.ctx, "failed to resume reading s3 object", log.FormatErrors([]error{rangeErr}), logData)
+			// the failure is returned by the next read, which tries to resume again unless it is permanent
+			body = io.NopCloser(errReader{fmt.Errorf("failed to resume reading s3 object at offset %d: %w", r.offset, rangeErr)})
+		}
+		r.body = body
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (r *resumableReader) Close() error {
+	return r.body.Close()
+}
+
+// errReader fails every read with its error
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// isPermanentReadError returns true if reading an S3 object again would fail in the same way: it has changed since it
+// was first read (its ETag differs, or the If-Match precondition failed with 412), access to it is denied (403),
+// or it no longer exists (404)
+func isPermanentReadError(err error) bool {
+	if errors.Is(err, errObjectChanged) {
+		return true
+	}
+	var respErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &respErr) {
+		switch respErr.HTTPStatusCode() {
+		case http.StatusPreconditionFailed, http.StatusForbidden, http.StatusNotFound:
+			return true
+		}
+	}
+	return false
+}
+
+// waitReadRetry waits before the provided attempt to read an S3 object again, for the backoff doubled after each
+// previous attempt, up to maxReadRetryBackoff. It returns false if the context is done in the meantime.
+func waitReadRetry(ctx context.Context, backoff time.Duration, attempt int) bool {
+	for i := 1; i < attempt && backoff < maxReadRetryBackoff; i++ {
+		backoff *= 2
+	}
+	select {
+	case <-time.After(min(backoff, maxReadRetryBackoff)):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// client returns the S3 client corresponding to the bucket, or creates one if not available
+func (s *S3Source) client(ctx context.Context, bucket string) S3Client {
+	if client, ok := s.Clients[bucket]; ok {
+		return client
+	}
+
+	log.Warn(ctx, "retreiving data from unexpected s3 bucket", log.Data{"RequestedBucket": bucket})
+	if s.LocalstackHost != "" {
+		return NewS3Client(bucket, *s.AwsConfig, func(o *awsS3.Options) {
+			o.BaseEndpoint = aws.String(s.LocalstackHost)
+			o.UsePathStyle = true
+		})
+	}
+	return NewS3Client(bucket, *s.AwsConfig)
+}
@@ -0,0 +1,587 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-dimension-extractor/service"
+	"github.com/ONSdigital/dp-dimension-extractor/service/mock"
+	dphttp "github.com/ONSdigital/dp-net/v2/http"
+	"github.com/ONSdigital/dp-net/v2/request"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLocalFileSource(t *testing.T) {
+	Convey("Given a local csv file", t, func() {
+		dir, err := filepath.EvalSymlinks(t.TempDir())
+		So(err, ShouldBeNil)
+		path := filepath.Join(dir, "input.csv")
+		So(os.WriteFile(path, []byte(validCsvContent), 0o600), ShouldBeNil)
+		fileURL := "file://" + filepath.ToSlash(path)
+		source := &service.LocalFileSource{}
+
+		Convey("When its description is requested, its URL, path and version are returned", func() {
+			info, err := source.Stat(ctx, fileURL)
+			So(err, ShouldBeNil)
+			So(info.URL, ShouldEqual, fileURL)
+			So("/"+info.Key, ShouldEqual, filepath.ToSlash(path))
+			So(info.ETag, ShouldNotBeEmpty)
+
+			Convey("And its content can be read", func() {
+				file, err := source.Open(ctx, info, nil)
+				So(err, ShouldBeNil)
+				defer file.Close()
+				content, err := io.ReadAll(file)
+				So(err, ShouldBeNil)
+				So(string(content), ShouldEqual, validCsvContent)
+			})
+		})
+
+		Convey("When the file is encrypted, its content is decrypted with the pre-shared key", func() {
+			psk := []byte("0123456789abcdef")
+			block, err := aes.NewCipher(psk)
+			So(err, ShouldBeNil)
+			encrypted := make([]byte, len(validCsvContent))
+			cipher.NewCFBEncrypter(block, psk).XORKeyStream(encrypted, []byte(validCsvContent))
+			So(os.WriteFile(path, encrypted, 0o600), ShouldBeNil)
+
+			info, err := source.Stat(ctx, fileURL)
+			So(err, ShouldBeNil)
+			file, err := source.Open(ctx, info, psk)
+			So(err, ShouldBeNil)
+			defer file.Close()
+			content, err := io.ReadAll(file)
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, validCsvContent)
+		})
+
+		Convey("When the file does not exist, a file source error is returned", func() {
+			_, err := source.Stat(ctx, "file://"+filepath.ToSlash(filepath.Join(dir, "missing.csv")))
+			var classified *service.ClassifiedError
+			So(errors.As(err, &classified), ShouldBeTrue)
+			So(classified.Class, ShouldEqual, service.ErrorClassFileSource)
+		})
+
+		Convey("When the source is restricted to the directory of the file, its content can be read", func() {
+			source.Dir = dir
+
+			info, err := source.Stat(ctx, fileURL)
+			So(err, ShouldBeNil)
+			So(info.URL, ShouldEqual, fileURL)
+		})
+
+		Convey("When a symbolic link within the restricted directory points to the file outside of it, a message error is returned", func() {
+			source.Dir = filepath.Join(dir, "restricted")
+			So(os.Mkdir(source.Dir, 0o700), ShouldBeNil)
+			link := filepath.Join(source.Dir, "link.csv")
+			So(os.Symlink(path, link), ShouldBeNil)
+
+			_, err := source.Stat(ctx, "file://"+filepath.ToSlash(link))
+			var classified *service.ClassifiedError
+			So(errors.As(err, &classified), ShouldBeTrue)
+			So(classified.Class, ShouldEqual, service.ErrorClassMessage)
+		})
+
+		Convey("When the source is restricted to another directory, a message error is returned", func() {
+			source.Dir = filepath.Join(dir, "other")
+
+			_, err := source.Stat(ctx, fileURL)
+			var classified *service.ClassifiedError
+			So(errors.As(err, &classified), ShouldBeTrue)
+			So(classified.Class, ShouldEqual, service.ErrorClassMessage)
+		})
+	})
+}
+
+func TestHTTPSFileSource(t *testing.T) {
+	Convey("Given a csv file served over https", t, func() {
+		var requestIDs []string
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestIDs = append(requestIDs, r.Header.Get(request.RequestHeaderKey))
+			if r.URL.Path != "/files/input.csv" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("ETag", validETag)
+			w.Header().Set("Content-Type", "text/csv")
+			io.WriteString(w, validCsvContent)
+		}))
+		defer server.Close()
+		source := &service.HTTPSFileSource{Client: dphttp.NewClientWithTransport(server.Client().Transport)}
+
+		Convey("When its description is requested, its URL, path and ETag are returned", func() {
+			info, err := source.Stat(request.WithRequestId(ctx, "abcdef123456"), server.URL+"/files/input.csv")
+			So(err, ShouldBeNil)
+			So(info, ShouldResemble, &service.FileInfo{
+				URL:         server.URL + "/files/input.csv",
+				Key:         "files/input.csv",
+				ETag:        "etag",
+				Size:        int64(len(validCsvContent)),
+				ContentType: "text/csv",
+			})
+			So(requestIDs, ShouldHaveLength, 1)
+			So(requestIDs[0], ShouldStartWith, "abcdef123456,")
+
+			Convey("And its content can be read", func() {
+				file, err := source.Open(ctx, info, nil)
+				So(err, ShouldBeNil)
+				defer file.Close()
+				content, err := io.ReadAll(file)
+				So(err, ShouldBeNil)
+				So(string(content), ShouldEqual, validCsvContent)
+			})
+		})
+
+		Convey("When the file is not found, a file source error is returned", func() {
+			_, err := source.Stat(ctx, server.URL+"/files/missing.csv")
+			So(err, ShouldResemble, &service.ClassifiedError{Class: service.ErrorClassFileSource, Err: errors.New("unexpected response status: 404 Not Found")})
+		})
+	})
+
+	Convey("Given a server which never responds", t, func() {
+		release := make(chan struct{})
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				io.WriteString(w, validCsvContent[:10])
+				w.(http.Flusher).Flush()
+			}
+			<-release
+		}))
+		defer server.Close()
+		defer close(release)
+		client := dphttp.ClientWithTimeout(dphttp.NewClientWithTransport(server.Client().Transport), 50*time.Millisecond)
+		client.SetMaxRetries(0)
+		source := &service.HTTPSFileSource{Client: client}
+
+		Convey("When the description of a file is requested, a file source error is returned once the client times out", func() {
+			start := time.Now()
+			_, err := source.Stat(ctx, server.URL+"/files/input.csv")
+			So(err, ShouldHaveSameTypeAs, &service.ClassifiedError{})
+			So(err.(*service.ClassifiedError).Class, ShouldEqual, service.ErrorClassFileSource)
+			So(time.Since(start), ShouldBeLessThan, time.Second)
+		})
+
+		Convey("When a file is read, the read fails once the client times out", func() {
+			file, err := source.Open(ctx, &service.FileInfo{URL: server.URL + "/files/input.csv"}, nil)
+			So(err, ShouldBeNil)
+			defer file.Close()
+			start := time.Now()
+			_, err = io.ReadAll(file)
+			So(err, ShouldNotBeNil)
+			So(time.Since(start), ShouldBeLessThan, time.Second)
+		})
+	})
+}
+
+// failingReader reads its content up to the failure offset, then fails
+type failingReader struct {
+	content []byte
+	failAt  int
+	err     error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.content) == 0 {
+		return 0, io.EOF
+	}
+	if r.failAt == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.content[:min(r.failAt, len(r.content))])
+	r.content, r.failAt = r.content[n:], r.failAt-n
+	return n, nil
+}
+
+// statusError is an error of a response with its HTTP status code, as returned by the AWS SDK
+type statusError int
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("response error StatusCode: %d", int(e))
+}
+
+func (e statusError) HTTPStatusCode() int {
+	return int(e)
+}
+
+func TestS3SourceResume(t *testing.T) {
+	Convey("Given an S3 object whose body fails mid-stream", t, func() {
+		content := []byte(validCsvContent)
+		const failAt = 20
+		errConnection := errors.New("connection reset by peer")
+		mockS3Client := &mock.S3ClientMock{
+			HeadFunc: mockHeadFunc,
+			GetFunc: func(ctx context.Context, key string) (io.ReadCloser, *int64, error) {
+				return io.NopCloser(&failingReader{content: content, failAt: failAt, err: errConnection}), nil, nil
+			},
+			GetWithPSKFunc: func(ctx context.Context, key string, psk []byte) (io.ReadCloser, *int64, error) {
+				return io.NopCloser(&failingReader{content: content, failAt: failAt, err: errConnection}), nil, nil
+			},
+			GetRangeFunc: func(ctx context.Context, key, eTag string, offset, length int64) (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(content[offset:])), nil
+			},
+		}
+		source := &service.S3Source{Clients: map[string]service.S3Client{validBucket: mockS3Client}, MaxReadRetries: 2}
+		info, err := source.Stat(ctx, validFileURL)
+		So(err, ShouldBeNil)
+
+		Convey("When it is read, the rest of the object is requested from the offset reached with its ETag", func() {
+			file, err := source.Open(ctx, info, nil)
+			So(err, ShouldBeNil)
+			read, err := io.ReadAll(file)
+			So(err, ShouldBeNil)
+			So(string(read), ShouldEqual, validCsvContent)
+			So(mockS3Client.GetRangeCalls(), ShouldHaveLength, 1)
+			So(mockS3Client.GetRangeCalls()[0].Key, ShouldEqual, validS3ObjKey)
+			So(mockS3Client.GetRangeCalls()[0].ETag, ShouldEqual, "etag")
+			So(mockS3Client.GetRangeCalls()[0].Offset, ShouldEqual, failAt)
+		})
+
+		Convey("When the object has changed since, reading it fails without resuming it again", func() {
+			errChanged := statusError(http.StatusPreconditionFailed)
+			mockS3Client.GetRangeFunc = func(ctx context.Context, key, eTag string, offset, length int64) (io.ReadCloser, error) {
+				return nil, errChanged
+			}
+
+			file, err := source.Open(ctx, info, nil)
+			So(err, ShouldBeNil)
+			_, err = io.ReadAll(file)
+			So(errors.Is(err, errChanged), ShouldBeTrue)
+			So(mockS3Client.GetRangeCalls(), ShouldHaveLength, 1)
+		})
+
+		Convey("When the object no longer exists, reading it fails without resuming it again", func() {
+			errNotFound := statusError(http.StatusNotFound)
+			mockS3Client.GetRangeFunc = func(ctx context.Context, key, eTag string, offset, length int64) (io.ReadCloser, error) {
+				return nil, errNotFound
+			}
+
+			file, err := source.Open(ctx, info, nil)
+			So(err, ShouldBeNil)
+			_, err = io.ReadAll(file)
+			So(errors.Is(err, errNotFound), ShouldBeTrue)
+			So(mockS3Client.GetRangeCalls(), ShouldHaveLength, 1)
+		})
+
+		Convey("When resuming it fails with a transient error, it is resumed again after a longer backoff", func() {
+			source.ReadRetryBackoff = 20 * time.Millisecond
+			errUnavailable := statusError(http.StatusServiceUnavailable)
+			mockS3Client.GetRangeFunc = func(ctx context.Context, key, eTag string, offset, length int64) (io.ReadCloser, error) {
+				if len(mockS3Client.GetRangeCalls()) == 1 {
+					return nil, errUnavailable
+				}
+				return io.NopCloser(bytes.NewReader(content[offset:])), nil
+			}
+
+			file, err := source.Open(ctx, info, nil)
+			So(err, ShouldBeNil)
+			start := time.Now()
+			read, err := io.ReadAll(file)
+			So(err, ShouldBeNil)
+			So(string(read), ShouldEqual, validCsvContent)
+			So(mockS3Client.GetRangeCalls(), ShouldHaveLength, 2)
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 60*time.Millisecond)
+		})
+
+		Convey("When the resumed body fails again without progress, reading it fails once the retries are exhausted", func() {
+			mockS3Client.GetRangeFunc = func(ctx context.Context, key, eTag string, offset, length int64) (io.ReadCloser, error) {
+				return io.NopCloser(&failingReader{content: content[offset:], err: errConnection}), nil
+			}
+
+			file, err := source.Open(ctx, info, nil)
+			So(err, ShouldBeNil)
+			_, err = io.ReadAll(file)
+			So(err, ShouldEqual, errConnection)
+			So(mockS3Client.GetRangeCalls(), ShouldHaveLength, 2)
+		})
+
+		Convey("When the object is encrypted, reading it is not resumed", func() {
+			file, err := source.Open(ctx, info, validPsk)
+			So(err, ShouldBeNil)
+			_, err = io.ReadAll(file)
+			So(err, ShouldEqual, errConnection)
+			So(mockS3Client.GetRangeCalls(), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestS3SourceDownload(t *testing.T) {
+	Convey("Given an S3 source downloading objects in concurrent byte ranges", t, func() {
+		content := []byte(validCsvContent)
+		const partSize = 10
+		mockS3Client := &mock.S3ClientMock{
+			HeadFunc: func(ctx context.Context, key string) (*s3.HeadObjectOutput, error) {
+				return &s3.HeadObjectOutput{ETag: aws.String(validETag), ContentLength: aws.Int64(int64(len(content)))}, nil
+			},
+			GetRangeFunc: func(ctx context.Context, key, eTag string, offset, length int64) (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(content[offset:min(offset+length, int64(len(content)))])), nil
+			},
+		}
+		dir := t.TempDir()
+		source := &service.S3Source{
+			Clients:             map[string]service.S3Client{validBucket: mockS3Client},
+			DownloadConcurrency: 3,
+			DownloadPartSize:    partSize,
+			DownloadDir:         dir,
+		}
+		info, err := source.Stat(ctx, validFileURL)
+		So(err, ShouldBeNil)
+
+		Convey("When an object larger than a part is opened, each of its parts is downloaded to a temporary file", func() {
+			file, err := source.Open(ctx, info, nil)
+			So(err, ShouldBeNil)
+			So(mockS3Client.GetRangeCalls(), ShouldHaveLength, (len(content)+partSize-1)/partSize)
+			for _, call := range mockS3Client.GetRangeCalls() {
+				So(call.ETag, ShouldEqual, "etag")
+				So(call.Length, ShouldEqual, min(partSize, int64(len(content))-call.Offset))
+			}
+			So(mockS3Client.GetCalls(), ShouldBeEmpty)
+
+			read, err := io.ReadAll(file)
+			So(err, ShouldBeNil)
+			So(string(read), ShouldEqual, validCsvContent)
+
+			Convey("And the file can be read again from disk", func() {
+				_, err := file.(io.Seeker).Seek(0, io.SeekStart)
+				So(err, ShouldBeNil)
+				read, err := io.ReadAll(file)
+				So(err, ShouldBeNil)
+				So(string(read), ShouldEqual, validCsvContent)
+			})
+
+			Convey("And the temporary file is removed once it is closed", func() {
+				So(file.Close(), ShouldBeNil)
+				entries, err := os.ReadDir(dir)
+				So(err, ShouldBeNil)
+				So(entries, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When a part cannot be downloaded, an S3 error is returned and the temporary file is removed", func() {
+			mockS3Client.GetRangeFunc = func(ctx context.Context, key, eTag string, offset, length int64) (io.ReadCloser, error) {
+				if offset == partSize {
+					return nil, errors.New("s3 object has changed")
+				}
+				return io.NopCloser(bytes.NewReader(content[offset:min(offset+length, int64(len(content)))])), nil
+			}
+
+			_, err := source.Open(ctx, info, nil)
+			So(err, ShouldResemble, &service.ClassifiedError{Class: service.ErrorClassS3, Err: errors.New("s3 object has changed")})
+			entries, err := os.ReadDir(dir)
+			So(err, ShouldBeNil)
+			So(entries, ShouldBeEmpty)
+		})
+
+		Convey("When a part fails to download with a transient error, it is downloaded again after a backoff", func() {
+			source.MaxReadRetries = 2
+			source.ReadRetryBackoff = 20 * time.Millisecond
+			var failed atomic.Bool
+			mockS3Client.GetRangeFunc = func(ctx context.Context, key, eTag string, offset, length int64) (io.ReadCloser, error) {
+				if offset == partSize && failed.CompareAndSwap(false, true) {
+					return nil, statusError(http.StatusServiceUnavailable)
+				}
+				return io.NopCloser(bytes.NewReader(content[offset:min(offset+length, int64(len(content)))])), nil
+			}
+
+			start := time.Now()
+			file, err := source.Open(ctx, info, nil)
+			So(err, ShouldBeNil)
+			defer file.Close()
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 20*time.Millisecond)
+			So(mockS3Client.GetRangeCalls(), ShouldHaveLength, (len(content)+partSize-1)/partSize+1)
+			read, err := io.ReadAll(file)
+			So(err, ShouldBeNil)
+			So(string(read), ShouldEqual, validCsvContent)
+		})
+
+		Convey("When a part fails to download because the object has changed, it is not downloaded again", func() {
+			source.MaxReadRetries = 2
+			mockS3Client.GetRangeFunc = func(ctx context.Context, key, eTag string, offset, length int64) (io.ReadCloser, error) {
+				if offset == partSize {
+					return nil, statusError(http.StatusPreconditionFailed)
+				}
+				return io.NopCloser(bytes.NewReader(content[offset:min(offset+length, int64(len(content)))])), nil
+			}
+
+			_, err := source.Open(ctx, info, nil)
+			So(errors.Is(err, statusError(http.StatusPreconditionFailed)), ShouldBeTrue)
+			calls := 0
+			for _, call := range mockS3Client.GetRangeCalls() {
+				if call.Offset == partSize {
+					calls++
+				}
+			}
+			So(calls, ShouldEqual, 1)
+		})
+
+		Convey("When an object is not larger than a part, it is streamed", func() {
+			mockS3Client.GetFunc = mockGetFunc
+			source.DownloadPartSize = int64(len(content))
+
+			file, err := source.Open(ctx, info, nil)
+			So(err, ShouldBeNil)
+			defer file.Close()
+			So(mockS3Client.GetCalls(), ShouldHaveLength, 1)
+			So(mockS3Client.GetRangeCalls(), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestSourceChecksums(t *testing.T) {
+	Convey("Given an S3 source verifying the checksum of the object metadata and the ETag", t, func() {
+		sha256Sum := sha256.Sum256([]byte(validCsvContent))
+		md5Sum := md5.Sum([]byte(validCsvContent))
+		checksum, contentMD5 := hex.EncodeToString(sha256Sum[:]), hex.EncodeToString(md5Sum[:])
+		head := &s3.HeadObjectOutput{
+			ETag:     aws.String(`"` + contentMD5 + `"`),
+			Metadata: map[string]string{"sha256": checksum},
+		}
+		mockDatasetClient := &mock.DatasetClientMock{
+			GetInstanceFunc:            mockGetInstanceFunc,
+			PostInstanceDimensionsFunc: mockPostInstanceFunc,
+			PutInstanceDataFunc:        mockPutInstanceFunc,
+		}
+		mockS3Client := &mock.S3ClientMock{
+			GetFunc: func(ctx context.Context, key string) (io.ReadCloser, *int64, error) {
+				return io.NopCloser(bytes.NewReader([]byte(validCsvContent))), nil, nil
+			},
+			GetWithPSKFunc: func(ctx context.Context, key string, psk []byte) (io.ReadCloser, *int64, error) {
+				return io.NopCloser(bytes.NewReader([]byte(validCsvContent))), nil, nil
+			},
+			HeadFunc: func(ctx context.Context, key string) (*s3.HeadObjectOutput, error) {
+				return head, nil
+			},
+		}
+		mockProducer := &mock.KafkaProducerMock{SendWithKeyFunc: mockSendFunc}
+		svc := &service.Service{
+			AuthToken:                  validAuthToken,
+			DimensionExtractedProducer: mockProducer,
+			EncryptionDisabled:         true,
+			DatasetClient:              mockDatasetClient,
+			FileSources: service.FileSources{service.FileSchemeS3: &service.S3Source{
+				Clients:             map[string]service.S3Client{validBucket: mockS3Client},
+				ChecksumMetadataKey: "SHA256",
+				VerifyETag:          true,
+			}},
+		}
+		event := &service.InputFileAvailable{FileURL: validFileURL, InstanceID: validInstanceID}
+
+		Convey("When the content matches both checksums, the checksum is sent in the dimensions-extracted message", func() {
+			_, err := svc.HandleEvent(ctx, event)
+			So(err, ShouldBeNil)
+			So(mockProducer.SendWithKeyCalls(), ShouldHaveLength, 1)
+			So(string(mockProducer.SendWithKeyCalls()[0].Message), ShouldContainSubstring, checksum)
+		})
+
+		Convey("When the content does not match the checksum of the metadata, nothing is sent", func() {
+			head.Metadata = map[string]string{"sha256": strings.Repeat("0", 64)}
+
+			_, err := svc.HandleEvent(ctx, event)
+			So(err, ShouldResemble, &service.ClassifiedError{
+				Class: service.ErrorClassChecksum,
+				Err:   fmt.Errorf("file checksum '%s' does not match the checksum '%s' of the source", checksum, strings.Repeat("0", 64)),
+			})
+			So(mockDatasetClient.PostInstanceDimensionsCalls(), ShouldBeEmpty)
+			So(mockProducer.SendWithKeyCalls(), ShouldBeEmpty)
+		})
+
+		Convey("When the content does not match the ETag, nothing is sent", func() {
+			head.ETag = aws.String(`"` + strings.Repeat("0", 32) + `"`)
+
+			_, err := svc.HandleEvent(ctx, event)
+			So(err, ShouldResemble, &service.ClassifiedError{
+				Class: service.ErrorClassChecksum,
+				Err:   fmt.Errorf("file md5 checksum '%s' does not match the etag '%s'", contentMD5, strings.Repeat("0", 32)),
+			})
+			So(mockProducer.SendWithKeyCalls(), ShouldBeEmpty)
+		})
+
+		Convey("When the ETag is the one of a multipart upload, it is not verified", func() {
+			head.ETag = aws.String(`"` + strings.Repeat("0", 32) + `-2"`)
+
+			_, err := svc.HandleEvent(ctx, event)
+			So(err, ShouldBeNil)
+		})
+
+		Convey("When the object is encrypted, the ETag is not verified", func() {
+			head.ETag = aws.String(`"` + strings.Repeat("0", 32) + `"`)
+			svc.EncryptionDisabled = false
+			svc.VaultClient = &mock.VaultClientMock{ReadKeyFunc: func(path string, key string) (string, error) {
+				return hex.EncodeToString(validPsk), nil
+			}}
+
+			_, err := svc.HandleEvent(ctx, event)
+			So(err, ShouldBeNil)
+			So(mockS3Client.GetWithPSKCalls(), ShouldHaveLength, 1)
+		})
+	})
+}
+
+func TestHandleEventFileSources(t *testing.T) {
+	Convey("Given a service with the S3, local file and https sources", t, func() {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "input.csv")
+		So(os.WriteFile(path, []byte(validCsvContent), 0o600), ShouldBeNil)
+
+		mockDatasetClient := &mock.DatasetClientMock{
+			GetInstanceFunc:            mockGetInstanceFunc,
+			PostInstanceDimensionsFunc: mockPostInstanceFunc,
+			PutInstanceDataFunc:        mockPutInstanceFunc,
+		}
+		mockS3Client := &mock.S3ClientMock{GetFunc: mockGetFunc, HeadFunc: mockHeadFunc}
+		mockProducer := &mock.KafkaProducerMock{SendWithKeyFunc: mockSendFunc}
+		svc := &service.Service{
+			AuthToken:                  validAuthToken,
+			DimensionExtractedProducer: mockProducer,
+			EncryptionDisabled:         true,
+			DatasetClient:              mockDatasetClient,
+			FileSources: service.FileSources{
+				service.FileSchemeS3:    &service.S3Source{Clients: map[string]service.S3Client{validBucket: mockS3Client}},
+				service.FileSchemeFile:  &service.LocalFileSource{Dir: dir},
+				service.FileSchemeHTTPS: &service.HTTPSFileSource{},
+			},
+		}
+
+		Convey("When an event for a local file is handled, its dimensions are extracted without S3", func() {
+			_, err := svc.HandleEvent(ctx, &service.InputFileAvailable{FileURL: "file://" + filepath.ToSlash(path), InstanceID: validInstanceID})
+			So(err, ShouldBeNil)
+			So(len(mockS3Client.HeadCalls()), ShouldEqual, 0)
+			So(len(mockDatasetClient.PostInstanceDimensionsCalls()), ShouldEqual, 3)
+			So(len(mockProducer.SendWithKeyCalls()), ShouldEqual, 1)
+		})
+
+		Convey("When an event for a path-style S3 URL is handled, the file is read from S3 rather than over https", func() {
+			_, err := svc.HandleEvent(ctx, &service.InputFileAvailable{FileURL: validFileURL, InstanceID: validInstanceID})
+			So(err, ShouldBeNil)
+			So(len(mockS3Client.HeadCalls()), ShouldEqual, 1)
+			So(len(mockS3Client.GetCalls()), ShouldEqual, 1)
+			So(len(mockProducer.SendWithKeyCalls()), ShouldEqual, 1)
+		})
+
+		Convey("When an event for an unsupported scheme is handled, a message error is returned", func() {
+			_, err := svc.HandleEvent(ctx, &service.InputFileAvailable{FileURL: "ftp://host/input.csv", InstanceID: validInstanceID})
+			So(err, ShouldResemble, &service.ClassifiedError{
+				Class: service.ErrorClassMessage,
+				Err:   errors.New("file url scheme 'ftp' not supported. Supported schemes: [file https s3]"),
+			})
+			So(len(mockDatasetClient.PostInstanceDimensionsCalls()), ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+)
+
+// notExtractableInstanceStates are the states of instances whose import has already finished, one way or another
+var notExtractableInstanceStates = map[string]bool{
+	dataset.StateCompleted.String():        true,
+	dataset.StateEditionConfirmed.String(): true,
+	dataset.StateFailed.String():           true,
+	dataset.StateAssociated.String():       true,
+	dataset.StatePublished.String():        true,
+	dataset.StateDetached.String():         true,
+}
+
+// notExtractableImportTaskStates are the states of the import observations task once it has finished
+var notExtractableImportTaskStates = map[string]bool{
+	dataset.StateCompleted.String(): true,
+	dataset.StateFailed.String():    true,
+}
+
+// notExtractableReason returns why dimensions must not be extracted for the instance,
+// or an empty string if the instance and its import observations task are in an extractable state
+func notExtractableReason(instance dataset.Instance) string {
+	if notExtractableInstanceStates[instance.State] {
+		return fmt.Sprintf("instance state is %s", instance.State)
+	}
+	if instance.ImportTasks != nil && instance.ImportTasks.ImportObservations != nil &&
+		notExtractableImportTaskStates[instance.ImportTasks.ImportObservations.State] {
+		return fmt.Sprintf("import observations task state is %s", instance.ImportTasks.ImportObservations.State)
+	}
+	return ""
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	"golang.org/x/net/context"
+)
+
+// ValidationReport is the result of a dry run: the file is read and fully validated as for an extraction,
+// but nothing is sent to the dataset API nor produced to kafka
+type ValidationReport struct {
+	InstanceID            string               `json:"instance_id"`
+	FileURL               string               `json:"file_url"`
+	Valid                 bool                 `json:"valid"`
+	Errors                []ValidationError    `json:"errors"`
+	FileChecksum          string               `json:"file_checksum,omitempty"`
+	S3ETag                string               `json:"s3_etag,omitempty"`
+	HeaderNames           []string             `json:"header_names,omitempty"`
+	NumberOfObservations  int                  `json:"number_of_observations"`
+	DimensionOptionCounts map[string]int       `json:"dimension_option_counts"`
+	Conflicts             []OptionConflict     `json:"conflicts"`
+	Warnings              []string             `json:"warnings"`
+	Statistics            ValidationStatistics `json:"statistics"`
+}
+
+// ValidationError is a reason why the file cannot be extracted. Row is the line of the faulty row, if any.
+type ValidationError struct {
+	Class   string `json:"class"`
+	Message string `json:"message"`
+	Row     int    `json:"row,omitempty"`
+}
+
+// OptionConflict is a dimension option code found with different labels in the file.
+// Only the label of its first row would be sent to the dataset API.
+type OptionConflict struct {
+	Dimension string   `json:"dimension"`
+	Code      string   `json:"code"`
+	Labels    []string `json:"labels"`
+}
+
+// ValidationStatistics describes the content of the file
+type ValidationStatistics struct {
+	RowsScanned       int `json:"rows_scanned"`
+	BlankObservations int `json:"blank_observations"`
+	Dimensions        int `json:"dimensions"`
+	DimensionOptions  int `json:"dimension_options"`
+}
+
+// validationErrorClasses are the classes of the errors caused by the event or the file, rather than by a dependency of the service
+var validationErrorClasses = map[string]bool{
+	ErrorClassMessage:   true,
+	ErrorClassCSV:       true,
+	ErrorClassChecksum:  true,
+	ErrorClassFileCheck: true,
+}
+
+func newValidationReport(event *InputFileAvailable) *ValidationReport {
+	return &ValidationReport{
+		InstanceID:            event.InstanceID,
+		FileURL:               event.FileURL,
+		Valid:                 true,
+		Errors:                []ValidationError{},
+		DimensionOptionCounts: map[string]int{},
+		Conflicts:             []OptionConflict{},
+		Warnings:              []string{},
+	}
+}
+
+// addScan records the content of the scanned file
+func (report *ValidationReport) addScan(result *scanResult) {
+	report.HeaderNames = result.headerRow
+	report.NumberOfObservations = result.numberOfObservations
+	for _, option := range result.dimensionOptions {
+		report.DimensionOptionCounts[option.Name]++
+	}
+	report.Conflicts = result.optionConflicts()
+	for _, conflict := range report.Conflicts {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("option '%s' of dimension '%s' has %d different labels, only '%s' would be kept",
+			conflict.Code, conflict.Dimension, len(conflict.Labels), conflict.Labels[0]))
+	}
+	if result.blankObservations > 0 {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%d observations are blank", result.blankObservations))
+	}
+	report.Statistics = ValidationStatistics{
+		RowsScanned:       result.rowsScanned,
+		BlankObservations: result.blankObservations,
+		Dimensions:        len(report.DimensionOptionCounts),
+		DimensionOptions:  len(result.dimensionOptions),
+	}
+}
+
+// addError records the error, and returns true, if it was caused by the event or the file.
+// Other errors, e.g. a dependency of the service being unavailable, are not recorded and false is returned.
+func (report *ValidationReport) addError(err error) bool {
+	var classifiedErr *ClassifiedError
+	if !errors.As(err, &classifiedErr) || !validationErrorClasses[classifiedErr.Class] {
+		return false
+	}
+	validationErr := ValidationError{Class: classifiedErr.Class, Message: err.Error()}
+	var rowErr *RowError
+	if errors.As(err, &rowErr) {
+		validationErr.Row = rowErr.Row
+	}
+	report.Valid = false
+	report.Errors = append(report.Errors, validationErr)
+	return true
+}
+
+// ValidateFile validates a csv file read from anywhere but S3, e.g. a local file, against the dimensions of the instance
+// provided instead of requested from the dataset API, and returns its validation report and the dimension options
+// extracted from it, sorted by dimension and code. The content encoding, csv dialect, expected checksum and strict option
+// of the event are applied. As for Validate, an error is only returned if the file could not be validated.
+func ValidateFile(ctx context.Context, file io.Reader, event *InputFileAvailable, instance dataset.Instance) (*ValidationReport, []dataset.OptionPost, error) {
+	return (&Service{}).validateFile(ctx, file, event, instance)
+}
+
+// ValidateUpload validates a csv file uploaded to the service against the dimensions provided or,
+// if there are none, against those of the instance of the event, requested from the dataset API
+func (svc *Service) ValidateUpload(ctx context.Context, file io.Reader, event *InputFileAvailable, dimensions []dataset.VersionDimension) (*ValidationReport, error) {
+	instance := dataset.Instance{Version: dataset.Version{Dimensions: dimensions}}
+	if len(dimensions) == 0 {
+		var err error
+		if instance, err = svc.getInstance(ctx, event.InstanceID); err != nil {
+			return nil, classify(ErrorClassDatasetAPI, err)
+		}
+	}
+	report, _, err := svc.validateFile(ctx, file, event, instance)
+	return report, err
+}
+
+func (svc *Service) validateFile(ctx context.Context, file io.Reader, event *InputFileAvailable, instance dataset.Instance) (*ValidationReport, []dataset.OptionPost, error) {
+	report := newValidationReport(event)
+	scanned, err := svc.extract(ctx, event, newSourceFile(io.NopCloser(file), &FileInfo{URL: event.FileURL}), instance, nil, report)
+	if err != nil {
+		if report.addError(err) {
+			return report, []dataset.OptionPost{}, nil
+		}
+		return nil, nil, err
+	}
+	return report, scanned.sortedOptions(), nil
+}
+
+// ParseDimensions parses a list of dimensions with their code list, either as a comma separated list of
+// dimension=codelist, e.g. time=mmm-yy,geography=uk-only, or as the JSON list of dimensions of an instance
+func ParseDimensions(value string) ([]dataset.VersionDimension, error) {
+	var dimensions []dataset.VersionDimension
+	if strings.HasPrefix(strings.TrimSpace(value), "[") {
+		if err := json.Unmarshal([]byte(value), &dimensions); err != nil {
+			return nil, fmt.Errorf("invalid JSON list of dimensions: %w", err)
+		}
+		return dimensions, nil
+	}
+
+	for _, dimension := range strings.Split(value, ",") {
+		name, codelist, ok := strings.Cut(dimension, "=")
+		name, codelist = strings.TrimSpace(name), strings.TrimSpace(codelist)
+		if !ok || name == "" || codelist == "" {
+			return nil, fmt.Errorf("invalid dimension %q, dimensions must be a comma separated list of dimension=codelist", dimension)
+		}
+		dimensions = append(dimensions, dataset.VersionDimension{Name: name, ID: codelist})
+	}
+	return dimensions, nil
+}
+
+// scanResult is the content of a scanned csv file
+type scanResult struct {
+	headerRow            []string
+	dimensionOptions     map[string]dataset.OptionPost
+	numberOfObservations int
+	rowsScanned          int
+	blankObservations    int
+	// conflictingLabels are the labels of the options found with different labels, by option key
+	conflictingLabels map[string][]string
+}
+
+// addOptions adds the options of a row which were not found yet, and records the labels of those found with a different label
+func (result *scanResult) addOptions(lineDimensions map[string]dataset.OptionPost) {
+	for optionKey, optionToPost := range lineDimensions {
+		existing, ok := result.dimensionOptions[optionKey]
+		if !ok {
+			result.dimensionOptions[optionKey] = optionToPost
+			continue
+		}
+		if existing.Label == optionToPost.Label {
+			continue
+		}
+		labels, ok := result.conflictingLabels[optionKey]
+		if !ok {
+			labels = []string{existing.Label}
+		}
+		if !slices.Contains(labels, optionToPost.Label) {
+			result.conflictingLabels[optionKey] = append(labels, optionToPost.Label)
+		}
+	}
+}
+
+// optionConflicts returns the options found with different labels, sorted by dimension and code
+func (result *scanResult) optionConflicts() []OptionConflict {
+	conflicts := make([]OptionConflict, 0, len(result.conflictingLabels))
+	for optionKey, labels := range result.conflictingLabels {
+		option := result.dimensionOptions[optionKey]
+		conflicts = append(conflicts, OptionConflict{Dimension: option.Name, Code: option.Code, Labels: labels})
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Dimension != conflicts[j].Dimension {
+			return conflicts[i].Dimension < conflicts[j].Dimension
+		}
+		return conflicts[i].Code < conflicts[j].Code
+	})
+	return conflicts
+}
+
+// sortedOptions returns the dimension options, sorted by dimension and code
+func (result *scanResult) sortedOptions() []dataset.OptionPost {
+	options := slices.Collect(maps.Values(result.dimensionOptions))
+	sort.Slice(options, func(i, j int) bool {
+		if options[i].Name != options[j].Name {
+			return options[i].Name < options[j].Name
+		}
+		return options[i].Code < options[j].Code
+	})
+	return options
+}
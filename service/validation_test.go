@@ -0,0 +1,252 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ONSdigital/dp-api-clients-go/v2/dataset"
+	"github.com/ONSdigital/dp-dimension-extractor/jobs"
+	"github.com/ONSdigital/dp-dimension-extractor/service"
+	"github.com/ONSdigital/dp-dimension-extractor/service/mock"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValidate(t *testing.T) {
+
+	Convey("Given a service with encryption disabled", t, func() {
+		csvContent := validCsvContent
+		mockDatasetClient := &mock.DatasetClientMock{
+			GetInstanceFunc:            mockGetInstanceFunc,
+			PostInstanceDimensionsFunc: mockPostInstanceFunc,
+			PutInstanceDataFunc:        mockPutInstanceFunc,
+		}
+		mockS3Client := &mock.S3ClientMock{
+			GetFunc: func(ctx context.Context, key string) (io.ReadCloser, *int64, error) {
+				return io.NopCloser(bytes.NewReader([]byte(csvContent))), nil, nil
+			},
+			HeadFunc: mockHeadFunc,
+		}
+		mockProducer := &mock.KafkaProducerMock{SendWithKeyFunc: mockSendFunc}
+		svc := &service.Service{
+			AuthToken:                  validAuthToken,
+			DimensionExtractedProducer: mockProducer,
+			EncryptionDisabled:         true,
+			DatasetClient:              mockDatasetClient,
+			S3Clients:                  map[string]service.S3Client{validBucket: mockS3Client},
+			Jobs:                       jobs.NewRegistry(10),
+		}
+		event := &service.InputFileAvailable{FileURL: validFileURL, InstanceID: validInstanceID}
+
+		noneSent := func() {
+			So(len(mockDatasetClient.PostInstanceDimensionsCalls()), ShouldEqual, 0)
+			So(len(mockDatasetClient.PutInstanceDataCalls()), ShouldEqual, 0)
+			So(len(mockProducer.SendWithKeyCalls()), ShouldEqual, 0)
+		}
+
+		Convey("When a valid file is validated, its content is reported and nothing is sent", func() {
+			csvContent = validCsvContent + "94.1,Apr-12,Apr-12,K02000001,United Kingdom,cpih1dim1T80000,Communication\n"
+
+			report, err := svc.Validate(ctx, event)
+			So(err, ShouldBeNil)
+			checksum := sha256.Sum256([]byte(csvContent))
+			So(report, ShouldResemble, &service.ValidationReport{
+				InstanceID:            validInstanceID,
+				FileURL:               validFileURL,
+				Valid:                 true,
+				Errors:                []service.ValidationError{},
+				FileChecksum:          hex.EncodeToString(checksum[:]),
+				S3ETag:                "etag",
+				HeaderNames:           []string{"V4_0", "Time", "Time", "UK-only", "Geography", "Cpih1dim1aggid", "Aggregate"},
+				NumberOfObservations:  2,
+				DimensionOptionCounts: map[string]int{"time": 2, "geography": 1, "aggregate": 1},
+				Conflicts: []service.OptionConflict{
+					{Dimension: "aggregate", Code: "cpih1dim1T80000", Labels: []string{"08 Communication", "Communication"}},
+					{Dimension: "geography", Code: "K02000001", Labels: []string{"          ", "United Kingdom"}},
+				},
+				Warnings: []string{
+					"option 'cpih1dim1T80000' of dimension 'aggregate' has 2 different labels, only '08 Communication' would be kept",
+					"option 'K02000001' of dimension 'geography' has 2 different labels, only '          ' would be kept",
+				},
+				Statistics: service.ValidationStatistics{RowsScanned: 2, Dimensions: 3, DimensionOptions: 4},
+			})
+			noneSent()
+
+			Convey("And the report is recorded with the job", func() {
+				status, _ := svc.Jobs.Get(validInstanceID)
+				So(status.Phase, ShouldEqual, jobs.PhaseCompleted)
+				So(status.Report, ShouldEqual, report)
+			})
+		})
+
+		Convey("When an invalid file is validated, the error is reported with its row and nothing is sent", func() {
+			csvContent = validCsvContent + "94.1,Month,Apr-12\n"
+
+			report, err := svc.Validate(ctx, event)
+			So(err, ShouldBeNil)
+			So(report.Valid, ShouldBeFalse)
+			So(report.Errors, ShouldResemble, []service.ValidationError{
+				{Class: service.ErrorClassCSV, Message: "record on line 4: wrong number of fields", Row: 4},
+			})
+			noneSent()
+		})
+
+		Convey("When a file which does not match the expected checksum is validated, the error is reported without the checksum of the file", func() {
+			event.ExpectedChecksum = "0000"
+
+			report, err := svc.Validate(ctx, event)
+			So(err, ShouldBeNil)
+			So(report.Valid, ShouldBeFalse)
+			So(report.Errors, ShouldHaveLength, 1)
+			So(report.Errors[0].Class, ShouldEqual, service.ErrorClassChecksum)
+			So(report.FileChecksum, ShouldBeEmpty)
+			noneSent()
+		})
+
+		Convey("When the file of an instance which is not extractable is validated, it is validated", func() {
+			mockDatasetClient.GetInstanceFunc = func(ctx context.Context, userAuthToken string, serviceAuthToken string, collectionID string, instanceID string, ifMatch string) (dataset.Instance, string, error) {
+				instance := testInstance
+				instance.State = dataset.StateCompleted.String()
+				return instance, "", nil
+			}
+
+			report, err := svc.Validate(ctx, event)
+			So(err, ShouldBeNil)
+			So(report.Valid, ShouldBeTrue)
+			So(report.NumberOfObservations, ShouldEqual, 1)
+			noneSent()
+		})
+
+		Convey("When the file cannot be read from S3, an error is returned rather than a report", func() {
+			mockS3Client.HeadFunc = func(ctx context.Context, key string) (*s3.HeadObjectOutput, error) {
+				return nil, errors.New("s3 unavailable")
+			}
+
+			report, err := svc.Validate(ctx, event)
+			So(report, ShouldBeNil)
+			So(err, ShouldNotBeNil)
+			status, _ := svc.Jobs.Get(validInstanceID)
+			So(status.Phase, ShouldEqual, jobs.PhaseFailed)
+		})
+
+		Convey("When an event with the dry run option and an invalid file is handled, no error is returned", func() {
+			csvContent = "no header\n"
+			event.ProcessingOptions = map[string]string{service.ProcessingOptionDryRun: "true"}
+
+			_, err := svc.HandleEvent(ctx, event)
+			So(err, ShouldBeNil)
+			status, _ := svc.Jobs.Get(validInstanceID)
+			So(status.Report.(*service.ValidationReport).Valid, ShouldBeFalse)
+			noneSent()
+		})
+	})
+}
+
+func TestValidateFile(t *testing.T) {
+	Convey("Given the dimensions of an instance", t, func() {
+		instance := dataset.Instance{Version: dataset.Version{Dimensions: []dataset.VersionDimension{
+			{Name: "time", ID: "mmm-yy"},
+			{Name: "geography", ID: "uk-only"},
+			{Name: "aggregate", ID: "cpih1dim1aggid"},
+		}}}
+		event := &service.InputFileAvailable{FileURL: "input.csv"}
+
+		Convey("When a valid file is validated, its report and options sorted by dimension and code are returned", func() {
+			report, options, err := service.ValidateFile(ctx, strings.NewReader(validCsvContent), event, instance)
+			So(err, ShouldBeNil)
+			So(report.Valid, ShouldBeTrue)
+			So(report.FileURL, ShouldEqual, "input.csv")
+			So(report.NumberOfObservations, ShouldEqual, 1)
+			So(options, ShouldResemble, []dataset.OptionPost{
+				{Name: "aggregate", Option: "cpih1dim1T80000", Label: "08 Communication", CodeList: "cpih1dim1aggid", Code: "cpih1dim1T80000"},
+				{Name: "geography", Option: "K02000001", Label: "          ", CodeList: "uk-only", Code: "K02000001"},
+				{Name: "time", Option: "Month", Label: "Mar-12", CodeList: "mmm-yy", Code: "Month"},
+			})
+		})
+
+		Convey("When a file is validated in strict mode without a column for a dimension, its error is reported", func() {
+			instance.Dimensions = append(instance.Dimensions, dataset.VersionDimension{Name: "sex", ID: "sex"})
+			event.ProcessingOptions = map[string]string{service.ProcessingOptionStrict: "true"}
+
+			report, options, err := service.ValidateFile(ctx, strings.NewReader(validCsvContent), event, instance)
+			So(err, ShouldBeNil)
+			So(report.Valid, ShouldBeFalse)
+			So(report.Errors, ShouldResemble, []service.ValidationError{
+				{Class: service.ErrorClassCSV, Message: "the csv file has no column for dimension 'sex'"},
+			})
+			So(options, ShouldBeEmpty)
+		})
+
+		Convey("When a file with a dimension missing from the instance is validated, its error is reported with its row", func() {
+			instance.Dimensions = instance.Dimensions[:2]
+
+			report, _, err := service.ValidateFile(ctx, strings.NewReader(validCsvContent), event, instance)
+			So(err, ShouldBeNil)
+			So(report.Errors, ShouldResemble, []service.ValidationError{
+				{Class: service.ErrorClassCSV, Message: "Failed to map dimension to code list, Aggregate", Row: 3},
+			})
+		})
+	})
+}
+
+func TestValidateUpload(t *testing.T) {
+	Convey("Given a service", t, func() {
+		mockDatasetClient := &mock.DatasetClientMock{GetInstanceFunc: mockGetInstanceFunc}
+		svc := &service.Service{AuthToken: validAuthToken, DatasetClient: mockDatasetClient}
+		event := &service.InputFileAvailable{InstanceID: validInstanceID, ProcessingOptions: map[string]string{service.ProcessingOptionDryRun: "true"}}
+
+		Convey("When a file is uploaded with the dimensions, it is validated against them without requesting the instance", func() {
+			dimensions, err := service.ParseDimensions("time=mmm-yy,geography=uk-only")
+			So(err, ShouldBeNil)
+
+			report, err := svc.ValidateUpload(ctx, strings.NewReader(validCsvContent), event, dimensions)
+			So(err, ShouldBeNil)
+			So(report.Valid, ShouldBeFalse)
+			So(report.Errors[0].Message, ShouldEqual, "Failed to map dimension to code list, Aggregate")
+			So(mockDatasetClient.GetInstanceCalls(), ShouldBeEmpty)
+		})
+
+		Convey("When a file is uploaded without dimensions, it is validated against those of the instance", func() {
+			report, err := svc.ValidateUpload(ctx, strings.NewReader(validCsvContent), event, nil)
+			So(err, ShouldBeNil)
+			So(report.Valid, ShouldBeTrue)
+			So(report.DimensionOptionCounts, ShouldResemble, map[string]int{"time": 1, "geography": 1, "aggregate": 1})
+			So(mockDatasetClient.GetInstanceCalls(), ShouldHaveLength, 1)
+		})
+
+		Convey("When the instance cannot be requested, a dataset API error is returned", func() {
+			event.InstanceID = "unknown"
+
+			report, err := svc.ValidateUpload(ctx, strings.NewReader(validCsvContent), event, nil)
+			So(report, ShouldBeNil)
+			var classifiedErr *service.ClassifiedError
+			So(errors.As(err, &classifiedErr), ShouldBeTrue)
+			So(classifiedErr.Class, ShouldEqual, service.ErrorClassDatasetAPI)
+		})
+	})
+}
+
+func TestParseDimensions(t *testing.T) {
+	Convey("Dimensions are parsed from a comma separated list of dimension=codelist", t, func() {
+		dimensions, err := service.ParseDimensions(" time = mmm-yy ,geography=uk-only")
+		So(err, ShouldBeNil)
+		So(dimensions, ShouldResemble, []dataset.VersionDimension{{Name: "time", ID: "mmm-yy"}, {Name: "geography", ID: "uk-only"}})
+	})
+
+	Convey("Dimensions are parsed from the JSON list of dimensions of an instance", t, func() {
+		dimensions, err := service.ParseDimensions(`[{"name": "time", "id": "mmm-yy", "label": "Time"}]`)
+		So(err, ShouldBeNil)
+		So(dimensions, ShouldResemble, []dataset.VersionDimension{{Name: "time", ID: "mmm-yy", Label: "Time"}})
+	})
+
+	Convey("An error is returned for a dimension without code list", t, func() {
+		_, err := service.ParseDimensions("time=mmm-yy,geography")
+		So(err, ShouldNotBeNil)
+	})
+}
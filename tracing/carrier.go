@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	kafka "github.com/ONSdigital/dp-kafka/v2"
+	"github.com/Shopify/sarama"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+var (
+	_ propagation.TextMapCarrier = MessageCarrier{}
+	_ propagation.TextMapCarrier = HeadersCarrier{}
+)
+
+// MessageCarrier adapts a consumed kafka message, so that trace context can be extracted from its headers
+type MessageCarrier struct {
+	Message kafka.Message
+}
+
+// Get returns the value of the message header for the provided key
+func (c MessageCarrier) Get(key string) string {
+	return c.Message.GetHeader(key)
+}
+
+// Set does nothing, as the headers of a consumed message cannot be modified
+func (c MessageCarrier) Set(key, value string) {}
+
+// Keys returns nil, as kafka.Message does not expose the list of its headers
+func (c MessageCarrier) Keys() []string {
+	return nil
+}
+
+// HeadersCarrier adapts the headers of a message to be produced, so that trace context can be injected into them
+type HeadersCarrier struct {
+	Headers *[]sarama.RecordHeader
+}
+
+// Get returns the value of the header for the provided key
+func (c HeadersCarrier) Get(key string) string {
+	for _, h := range *c.Headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Set adds the header, replacing any existing header with the same key
+func (c HeadersCarrier) Set(key, value string) {
+	for i, h := range *c.Headers {
+		if string(h.Key) == key {
+			(*c.Headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.Headers = append(*c.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+// Keys returns the keys of all the headers
+func (c HeadersCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c.Headers))
+	for _, h := range *c.Headers {
+		keys = append(keys, string(h.Key))
+	}
+	return keys
+}
@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ONSdigital/dp-dimension-extractor/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Config contains the configuration required to export traces.
+// Exporter is one of config.OTelExporterOTLP or config.OTelExporterStdout.
+// The stdout exporter writes spans to Writer, or to os.Stderr if it is nil, so that they are not mixed with the logs on stdout.
+type Config struct {
+	Exporter     string
+	OTLPEndpoint string
+	ServiceName  string
+	Version      string
+	Writer       io.Writer
+}
+
+// Init creates a tracer provider exporting spans as configured, and registers it globally along with
+// the W3C trace context and baggage propagators. The returned function flushes any pending span and
+// stops the tracer provider; it must be called on shutdown.
+func Init(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	exporter, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(cfg.ServiceName),
+			semconv.ServiceVersion(cfg.Version),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return tp.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, cfg Config) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case config.OTelExporterOTLP:
+		return otlptracehttp.New(ctx,
+			otlptracehttp.WithEndpoint(cfg.OTLPEndpoint),
+			otlptracehttp.WithInsecure(),
+		)
+	case config.OTelExporterStdout:
+		writer := cfg.Writer
+		if writer == nil {
+			writer = os.Stderr
+		}
+		return stdouttrace.New(stdouttrace.WithWriter(writer))
+	default:
+		return nil, fmt.Errorf("traces exporter not recognised: '%s' Valid exporters: %v", cfg.Exporter, []string{config.OTelExporterOTLP, config.OTelExporterStdout})
+	}
+}
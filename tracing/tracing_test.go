@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ONSdigital/dp-dimension-extractor/config"
+	"github.com/ONSdigital/dp-kafka/v2/kafkatest"
+	"github.com/Shopify/sarama"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ctx = context.Background()
+
+func TestInit(t *testing.T) {
+	Convey("Given a tracing config with an unknown exporter", t, func() {
+		cfg := Config{Exporter: "zipkin", ServiceName: "dp-dimension-extractor"}
+
+		Convey("When Init is called", func() {
+			shutdown, err := Init(ctx, cfg)
+
+			Convey("Then an error is returned", func() {
+				So(shutdown, ShouldBeNil)
+				So(err.Error(), ShouldEqual, "traces exporter not recognised: 'zipkin' Valid exporters: [otlp stdout]")
+			})
+		})
+	})
+
+	Convey("Given a tracing config with the stdout exporter", t, func() {
+		var spans bytes.Buffer
+		cfg := Config{Exporter: config.OTelExporterStdout, ServiceName: "dp-dimension-extractor", Version: "v1.0.0", Writer: &spans}
+
+		Convey("When Init is called", func() {
+			shutdown, err := Init(ctx, cfg)
+
+			Convey("Then a recording tracer provider and the W3C trace context propagator are registered", func() {
+				So(err, ShouldBeNil)
+				_, span := otel.Tracer("test").Start(ctx, "test")
+				So(span.IsRecording(), ShouldBeTrue)
+				span.End()
+				So(otel.GetTextMapPropagator().Fields(), ShouldContain, "traceparent")
+				So(shutdown(ctx), ShouldBeNil)
+			})
+
+			Convey("And spans are written to the configured writer", func() {
+				_, span := otel.Tracer("test").Start(ctx, "test")
+				span.End()
+				So(shutdown(ctx), ShouldBeNil)
+				So(spans.String(), ShouldContainSubstring, `"Name":"test"`)
+			})
+		})
+	})
+}
+
+func TestCarriers(t *testing.T) {
+	Convey("Given a span and the W3C trace context propagator", t, func() {
+		propagator := propagation.TraceContext{}
+		tp := sdktrace.NewTracerProvider()
+		spanCtx, span := tp.Tracer("test").Start(ctx, "test")
+		defer span.End()
+
+		Convey("When the trace context is injected into the headers of a message to be produced", func() {
+			headers := []sarama.RecordHeader{{Key: []byte("request-id"), Value: []byte("abcdef123456")}}
+			propagator.Inject(spanCtx, HeadersCarrier{Headers: &headers})
+
+			Convey("Then a traceparent header is added to the existing headers", func() {
+				So(len(headers), ShouldEqual, 2)
+				So(HeadersCarrier{Headers: &headers}.Keys(), ShouldResemble, []string{"request-id", "traceparent"})
+			})
+
+			Convey("And the trace context can be extracted from a consumed message with those headers", func() {
+				testHeaders := kafkatest.TestHeader{}
+				for _, h := range headers {
+					testHeaders[string(h.Key)] = string(h.Value)
+				}
+				msg := kafkatest.NewMessage(nil, 1, testHeaders)
+
+				extracted := trace.SpanContextFromContext(propagator.Extract(ctx, MessageCarrier{Message: msg}))
+				So(extracted.IsRemote(), ShouldBeTrue)
+				So(extracted.TraceID(), ShouldEqual, span.SpanContext().TraceID())
+				So(extracted.SpanID(), ShouldEqual, span.SpanContext().SpanID())
+			})
+		})
+
+		Convey("When a header is set twice", func() {
+			headers := []sarama.RecordHeader{}
+			carrier := HeadersCarrier{Headers: &headers}
+			carrier.Set("traceparent", "first")
+			carrier.Set("traceparent", "second")
+
+			Convey("Then only the last value is kept", func() {
+				So(len(headers), ShouldEqual, 1)
+				So(carrier.Get("traceparent"), ShouldEqual, "second")
+			})
+		})
+	})
+}